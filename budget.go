@@ -0,0 +1,184 @@
+package gofofa
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBudgetExceeded budget exceeded, use errors.Is to check a BudgetError
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget spending limit of client or one search call, zero means no limit
+type Budget struct {
+	MaxFCoin   int // max fcoin deducted, estimated by rows over free limit in DeductModeFCoin
+	MaxRows    int // max rows fetched
+	MaxQueries int // max api queries sent
+}
+
+// Spending spent quota of client or one search call
+type Spending struct {
+	FCoin   int `json:"fcoin"`
+	Rows    int `json:"rows"`
+	Queries int `json:"queries"`
+}
+
+// BudgetError search stopped by budget, partial results are returned with it
+type BudgetError struct {
+	Limit string // fcoin, rows or queries
+	Max   int    // max value of budget
+	Spent int    // spent value when stopped
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s: %s limit is %d, already spent %d", ErrBudgetExceeded, e.Limit, e.Max, e.Spent)
+}
+
+// Is make errors.Is(err, ErrBudgetExceeded) work
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// budgetState budget and spending of client, shared by concurrent searches
+type budgetState struct {
+	sync.Mutex
+	budget Budget
+	spent  Spending
+}
+
+// WithBudget set spending limit of client, all searches of the client share it
+func WithBudget(budget Budget) ClientOption {
+	return func(c *Client) error {
+		c.SetBudget(budget)
+		return nil
+	}
+}
+
+// SetBudget set spending limit of client, spending is not reset
+func (c *Client) SetBudget(budget Budget) {
+	c.budget.Lock()
+	defer c.budget.Unlock()
+	c.budget.budget = budget
+}
+
+// Spent quota spent by searches of client
+func (c *Client) Spent() Spending {
+	c.budget.Lock()
+	defer c.budget.Unlock()
+	return c.budget.spent
+}
+
+// budgetGuard check spending of one search call against client and call budget
+type budgetGuard struct {
+	c        *Client
+	budget   Budget   // call budget
+	spent    Spending // call spending
+	freeSize int      // free rows, -1 means fcoin not tracked
+}
+
+func (c *Client) newBudgetGuard(options ...SearchOptions) *budgetGuard {
+	g := &budgetGuard{
+		c:        c,
+		freeSize: -1,
+	}
+	if len(options) > 0 {
+		g.budget = options[0].Budget
+	}
+
+	// 只有扣F币模式才需要计算F币
+	if c.DeductMode == DeductModeFCoin {
+		c.budget.Lock()
+		maxFCoin := c.budget.budget.MaxFCoin
+		c.budget.Unlock()
+		if g.budget.MaxFCoin > 0 || maxFCoin > 0 {
+			g.freeSize = c.freeSize()
+		}
+	}
+	return g
+}
+
+// remainRows rows can be fetched, -1 means no limit
+func (g *budgetGuard) remainRows() int {
+	g.c.budget.Lock()
+	defer g.c.budget.Unlock()
+
+	remain := -1
+	if max := g.budget.MaxRows; max > 0 {
+		remain = max - g.spent.Rows
+	}
+	if max := g.c.budget.budget.MaxRows; max > 0 {
+		if r := max - g.c.budget.spent.Rows; remain == -1 || r < remain {
+			remain = r
+		}
+	}
+	if remain < -1 {
+		remain = 0
+	}
+	return remain
+}
+
+// fcoinOf fcoin of total rows fetched by the call
+func (g *budgetGuard) fcoinOf(rows int) int {
+	if g.freeSize < 0 || rows <= g.freeSize {
+		return 0
+	}
+	return fcoinOfRows(rows - g.freeSize)
+}
+
+// check before fetching one page of rows, rows is the size of the page
+func (g *budgetGuard) check(rows int) error {
+	g.c.budget.Lock()
+	defer g.c.budget.Unlock()
+
+	clientBudget := g.c.budget.budget
+	clientSpent := g.c.budget.spent
+
+	if max := g.budget.MaxQueries; max > 0 && g.spent.Queries+1 > max {
+		return &BudgetError{Limit: "queries", Max: max, Spent: g.spent.Queries}
+	}
+	if max := clientBudget.MaxQueries; max > 0 && clientSpent.Queries+1 > max {
+		return &BudgetError{Limit: "queries", Max: max, Spent: clientSpent.Queries}
+	}
+
+	// 整页都会扣除，不能超出
+	if max := g.budget.MaxRows; max > 0 && g.spent.Rows+rows > max {
+		return &BudgetError{Limit: "rows", Max: max, Spent: g.spent.Rows}
+	}
+	if max := clientBudget.MaxRows; max > 0 && clientSpent.Rows+rows > max {
+		return &BudgetError{Limit: "rows", Max: max, Spent: clientSpent.Rows}
+	}
+
+	fcoin := g.fcoinOf(g.spent.Rows+rows) - g.spent.FCoin
+	if max := g.budget.MaxFCoin; max > 0 && g.spent.FCoin+fcoin > max {
+		return &BudgetError{Limit: "fcoin", Max: max, Spent: g.spent.FCoin}
+	}
+	if max := clientBudget.MaxFCoin; max > 0 && clientSpent.FCoin+fcoin > max {
+		return &BudgetError{Limit: "fcoin", Max: max, Spent: clientSpent.FCoin}
+	}
+	return nil
+}
+
+// spend record rows fetched by one api query
+func (g *budgetGuard) spend(rows int) {
+	g.c.budget.Lock()
+	defer g.c.budget.Unlock()
+
+	fcoin := g.fcoinOf(g.spent.Rows+rows) - g.spent.FCoin
+	g.spent.Queries++
+	g.spent.Rows += rows
+	g.spent.FCoin += fcoin
+	g.c.budget.spent.Queries++
+	g.c.budget.spent.Rows += rows
+	g.c.budget.spent.FCoin += fcoin
+}
+
+// rowsExceeded error of rows budget
+func (g *budgetGuard) rowsExceeded() error {
+	g.c.budget.Lock()
+	defer g.c.budget.Unlock()
+
+	if max := g.budget.MaxRows; max > 0 && g.spent.Rows >= max {
+		return &BudgetError{Limit: "rows", Max: max, Spent: g.spent.Rows}
+	}
+	return &BudgetError{Limit: "rows", Max: g.c.budget.budget.MaxRows, Spent: g.c.budget.spent.Rows}
+}
@@ -0,0 +1,169 @@
+package gofofa
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestClient_Budget(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(queryHander))
+	defer ts.Close()
+
+	var cli *Client
+	var err error
+	var account accountInfo
+	var res [][]string
+	var budgetErr *BudgetError
+
+	account = validAccounts[1]
+
+	// 客户端行数预算
+	cli, err = NewClient(WithURL(ts.URL+"?email="+account.Email+"&key="+account.Key), WithBudget(Budget{MaxRows: 25}))
+	assert.Nil(t, err)
+	var fetched [][]string
+	err = cli.DumpSearch("port=80", -1, 10, []string{"ip", "port"}, func(rows [][]string, allSize int) error {
+		fetched = append(fetched, rows...)
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, "rows", budgetErr.Limit)
+	assert.Equal(t, 25, budgetErr.Max)
+	assert.Equal(t, 25, len(fetched))
+	assert.Equal(t, 3, cli.Spent().Queries)
+
+	// 预算用完，不再请求
+	res, err = cli.HostSearch("port=50000", 10, []string{"ip"})
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.Nil(t, res)
+	assert.Equal(t, 3, cli.Spent().Queries)
+
+	// 单次调用行数预算
+	cli, err = NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	res, err = cli.HostSearch("port=50000", 100, []string{"ip"}, SearchOptions{Budget: Budget{MaxRows: 5}})
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.Equal(t, 5, len(res))
+	res, err = cli.HostSearch("port=50000", 100, []string{"ip"}, SearchOptions{Budget: Budget{MaxRows: 20}})
+	assert.Nil(t, err)
+	assert.Equal(t, 9, len(res))
+
+	// 单次调用请求次数预算
+	fetched = nil
+	err = cli.DumpSearch("port=80", -1, 10, []string{"ip", "port"}, func(rows [][]string, allSize int) error {
+		fetched = append(fetched, rows...)
+		return nil
+	}, SearchOptions{Budget: Budget{MaxQueries: 2}})
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, "queries", budgetErr.Limit)
+	assert.Equal(t, 20, len(fetched))
+	assert.Contains(t, err.Error(), "queries limit is 2")
+
+	// F币预算
+	account = validAccounts[4]
+	cli, err = NewClient(WithURL(ts.URL+"?email="+account.Email+"&key="+account.Key), WithBudget(Budget{MaxFCoin: 1}))
+	assert.Nil(t, err)
+	cli.DeductMode = DeductModeFCoin
+	fetched = nil
+	err = cli.DumpSearch("port=80", 30, 10, []string{"ip", "port"}, func(rows [][]string, allSize int) error {
+		fetched = append(fetched, rows...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 30, len(fetched))
+	assert.Equal(t, Spending{FCoin: 1, Rows: 30, Queries: 3}, cli.Spent())
+	err = cli.DumpSearch("port=80", 30, 10, []string{"ip", "port"}, func(rows [][]string, allSize int) error {
+		return nil
+	})
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, "fcoin", budgetErr.Limit)
+
+	// 调整预算
+	cli.SetBudget(Budget{MaxFCoin: 2})
+	err = cli.DumpSearch("port=80", 10, 10, []string{"ip", "port"}, func(rows [][]string, allSize int) error {
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, cli.Spent().FCoin)
+}
+
+func TestClient_BudgetPageSize(t *testing.T) {
+	// 按请求的size返回数据，记录每次请求的size
+	var lock sync.Mutex
+	var sizes []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/search/all" && r.URL.Path != "/api/v1/search/next" {
+			queryHander(w, r)
+			return
+		}
+		size, _ := strconv.Atoi(r.FormValue("size"))
+		lock.Lock()
+		sizes = append(sizes, size)
+		lock.Unlock()
+		results := make([][]string, size)
+		for i := range results {
+			results[i] = []string{"1.1.1.1", "80"}
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"error": false, "size": 5000, "page": 1, "next": "n", "results": results,
+		})
+		w.Write(b)
+	}))
+	defer ts.Close()
+	account := validAccounts[1]
+
+	// 每次请求的size都不能让已扣除的行数超出预算
+	checkSizes := func(maxRows int) {
+		total := 0
+		for _, size := range sizes {
+			total += size
+			assert.LessOrEqual(t, total, maxRows, sizes)
+		}
+	}
+
+	for _, maxRows := range []int{1500, 1501, 2999, 10} {
+		// 翻页取所有数据
+		sizes = nil
+		cli, err := NewClient(WithURL(ts.URL+"?email="+account.Email+"&key="+account.Key),
+			WithBudget(Budget{MaxRows: maxRows}))
+		assert.Nil(t, err)
+		res, err := cli.HostSearch("port=80", -1, []string{"ip", "port"})
+		assert.True(t, errors.Is(err, ErrBudgetExceeded))
+		assert.LessOrEqual(t, cli.Spent().Rows, maxRows)
+		assert.Equal(t, cli.Spent().Rows, len(res))
+		assert.Greater(t, len(res), maxRows-maxRows/1000-1)
+		checkSizes(maxRows)
+
+		// next翻页
+		sizes = nil
+		cli, err = NewClient(WithURL(ts.URL+"?email="+account.Email+"&key="+account.Key),
+			WithBudget(Budget{MaxRows: maxRows}))
+		assert.Nil(t, err)
+		fetched := 0
+		err = cli.DumpSearch("port=80", -1, 1000, []string{"ip", "port"}, func(rows [][]string, allSize int) error {
+			fetched += len(rows)
+			return nil
+		})
+		assert.True(t, errors.Is(err, ErrBudgetExceeded))
+		assert.Equal(t, maxRows, cli.Spent().Rows)
+		assert.Equal(t, maxRows, fetched)
+		checkSizes(maxRows)
+	}
+
+	// 预算不限制数量时，后面的页也不能超出
+	sizes = nil
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	cli.DeductMode = DeductModeFCoin
+	res, err := cli.HostSearch("port=80", 1500, []string{"ip", "port"}, SearchOptions{Budget: Budget{MaxRows: 1800}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1500, len(res))
+	assert.Equal(t, 1500, cli.Spent().Rows)
+	checkSizes(1800)
+}
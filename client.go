@@ -36,6 +36,7 @@ type Client struct {
 	logger     *logrus.Logger
 	ctx        context.Context // use to cancel requests
 
+	budget       budgetState              // spending limit of client
 	onResults    func(results [][]string) // when fetch results callback
 	accountDebug bool                     // 调试账号明文信息
 	traceId      bool                     // 报错信息返回 trace id
@@ -13,7 +13,8 @@ var (
 )
 
 var (
-	fofaURL string // fofa url
+	fofaURL      string // fofa url
+	accountDebug bool   // print account in error log
 )
 
 // GlobalCommands global commands
@@ -27,6 +28,24 @@ var GlobalCommands = []*cli.Command{
 	hostCmd,
 	dumpCmd,
 	domainsCmd,
+	subdomainsCmd,
+	diffCmd,
+	watchCmd,
+	storeCmd,
+	localCmd,
+	pipelineCmd,
+	probeCmd,
+	fingerprintCmd,
+	jarmCmd,
+	pivotCmd,
+}
+
+// offlineCommands commands work on local data, no need fofa client
+var offlineCommands = map[string]bool{
+	"local":    true,
+	"store":    true,
+	"pipeline": true, // client is created only if needed
+	"probe":    true,
 }
 
 // IsValidCommand valid command name
@@ -110,24 +129,33 @@ func BeforAction(context *cli.Context) error {
 	if context.Bool("verbose") {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
-	var accountDebug bool
 	if context.Bool("accountDebug") {
 		accountDebug = true
 	}
 
+	if offlineCommands[context.Args().First()] {
+		return nil
+	}
+
 	//// icon no need client
 	//if isSubCmd(os.Args[1:], "icon") {
 	//	return nil
 	//}
 
-	fofaCli, err = gofofa.NewClient(gofofa.WithURL(fofaURL), gofofa.WithAccountDebug(accountDebug))
+	fofaCli, err = newFofaClient()
+	return err
+}
+
+// newFofaClient generate fofa client of global options
+func newFofaClient() (*gofofa.Client, error) {
+	client, err := gofofa.NewClient(gofofa.WithURL(fofaURL), gofofa.WithAccountDebug(accountDebug))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(deductMode) > 0 {
-		fofaCli.DeductMode = gofofa.ParseDeductMode(deductMode)
+		client.DeductMode = gofofa.ParseDeductMode(deductMode)
 	}
 
-	return nil
+	return client, nil
 }
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	diffKeys     string // key fields of asset
+	diffIgnore   string // fields not compared
+	diffQuery    string // query of live mode
+	diffBaseline string // baseline file of live mode
+)
+
+// diff subcommand
+var diffCmd = &cli.Command{
+	Name:                   "diff",
+	Usage:                  "compare two snapshots of query results, or fresh results of query with a baseline",
+	UsageText:              "fofa diff [options] old.jsonl new.jsonl\nfofa diff [options] --query '<query>' --baseline old.jsonl",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "key",
+			Aliases:     []string{"k"},
+			Value:       "ip,port",
+			Usage:       "key fields to identify an asset",
+			Destination: &diffKeys,
+		},
+		&cli.StringFlag{
+			Name:        "ignore",
+			Value:       "lastupdatetime",
+			Usage:       "fields not compared",
+			Destination: &diffIgnore,
+		},
+		&cli.StringFlag{
+			Name:        "query",
+			Aliases:     []string{"q"},
+			Usage:       "live mode, fetch fresh results of query and compare to baseline",
+			Destination: &diffQuery,
+		},
+		&cli.StringFlag{
+			Name:        "baseline",
+			Aliases:     []string{"b"},
+			Usage:       "baseline json file of live mode",
+			Destination: &diffBaseline,
+		},
+		&cli.StringFlag{
+			Name:        "fields",
+			Aliases:     []string{"f"},
+			Usage:       "fields of live mode, default is fields of baseline",
+			Destination: &fieldString,
+		},
+		&cli.IntFlag{
+			Name:        "size",
+			Aliases:     []string{"s"},
+			Value:       -1,
+			Usage:       "size of live mode, -1 means all",
+			Destination: &size,
+		},
+		&cli.IntFlag{
+			Name:        "batchSize",
+			Aliases:     []string{"bs"},
+			Value:       1000,
+			Usage:       "the amount of data contained in each batch of live mode",
+			Destination: &batchSize,
+		},
+		&cli.BoolFlag{
+			Name:        "full",
+			Value:       false,
+			Usage:       "search result for over a year",
+			Destination: &full,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "text",
+			Usage:       "can be text/json/csv",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+	},
+	Action: diffAction,
+}
+
+// readJSONRecordsFile read json records file written by json format
+func readJSONRecordsFile(filename string) ([]map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := outformats.NewJSONReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read %s failed: %w", filename, err)
+	}
+	return records, nil
+}
+
+// recordsFields all fields of records in order
+func recordsFields(records []map[string]string) []string {
+	fieldMap := make(map[string]bool)
+	for _, record := range records {
+		for k := range record {
+			fieldMap[k] = true
+		}
+	}
+	var fields []string
+	for k := range fieldMap {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// fetchRecords dump all results of query as records
+func fetchRecords(ctx context.Context, query string, fields []string) (records []map[string]string, err error) {
+	log.Println("dump data of query:", query)
+	err = stream.FromDumpSearch(fofaCli, query, size, batchSize, fields, nil, gofofa.SearchOptions{
+		Full: full,
+	}).Run(ctx, func(rows [][]string) error {
+		for _, row := range rows {
+			record := make(map[string]string, len(fields))
+			for i, f := range fields {
+				if i < len(row) {
+					record[f] = row[i]
+				}
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return
+}
+
+// diffAction diff action
+func diffAction(ctx *cli.Context) error {
+	keys := strings.Split(diffKeys, ",")
+	var ignore []string
+	if len(diffIgnore) > 0 {
+		ignore = strings.Split(diffIgnore, ",")
+	}
+
+	var oldRecords, newRecords []map[string]string
+	var err error
+	if len(diffQuery) > 0 {
+		// live mode
+		if len(diffBaseline) == 0 {
+			return errors.New("baseline file cannot be empty in live mode")
+		}
+		if oldRecords, err = readJSONRecordsFile(diffBaseline); err != nil {
+			return err
+		}
+
+		var fields []string
+		if len(fieldString) > 0 {
+			fields = strings.Split(fieldString, ",")
+		} else {
+			fields = recordsFields(oldRecords)
+		}
+		for _, k := range keys {
+			if !hashField(fields, k) {
+				fields = append(fields, k)
+			}
+		}
+		if newRecords, err = fetchRecords(ctx.Context, diffQuery, fields); err != nil {
+			return err
+		}
+	} else {
+		if ctx.NArg() != 2 {
+			return errors.New("need old and new json file, or use --query and --baseline")
+		}
+		if oldRecords, err = readJSONRecordsFile(ctx.Args().Get(0)); err != nil {
+			return err
+		}
+		if newRecords, err = readJSONRecordsFile(ctx.Args().Get(1)); err != nil {
+			return err
+		}
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		var f *os.File
+		if f, err = os.Create(outFile); err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+
+	diff := gofofa.DiffAssets(oldRecords, newRecords, keys, ignore)
+	log.Printf("%d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed))
+
+	switch format {
+	case "text":
+		for _, record := range diff.Added {
+			fmt.Fprintln(outTo, "+", gofofa.AssetKey(record, keys))
+		}
+		for _, record := range diff.Removed {
+			fmt.Fprintln(outTo, "-", gofofa.AssetKey(record, keys))
+		}
+		for _, change := range diff.Changed {
+			for _, f := range change.Fields {
+				fmt.Fprintf(outTo, "~ %s %s: %q -> %q\n", change.Key, f, change.Old[f], change.New[f])
+			}
+		}
+	case "json":
+		encoder := json.NewEncoder(outTo)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	case "csv":
+		return outformats.NewCSVWriter(outTo).WriteAll(diffRecords(diff))
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	return nil
+}
+
+// diffRecords diff to csv records: change,key,field,old,new
+func diffRecords(diff gofofa.AssetDiff) [][]string {
+	records := [][]string{{"change", "key", "field", "old", "new"}}
+	for _, record := range diff.Added {
+		records = append(records, []string{"added", gofofa.AssetKey(record, diff.Keys), "", "", ""})
+	}
+	for _, record := range diff.Removed {
+		records = append(records, []string{"removed", gofofa.AssetKey(record, diff.Keys), "", "", ""})
+	}
+	for _, change := range diff.Changed {
+		for _, f := range change.Fields {
+			records = append(records, []string{"changed", change.Key, f, change.Old[f], change.New[f]})
+		}
+	}
+	return records
+}
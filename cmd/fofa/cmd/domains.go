@@ -11,17 +11,25 @@ import (
 	"os"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 var (
-	withCount = false
+	withCount         = false
+	domainsDepth      int    // rounds of recursive expansion
+	domainsPivots     string // pivots of recursive expansion
+	domainsMaxDomains int    // stop recursive expansion when so many domains are found
 )
 
+// expandedDomainFields columns of recursive mode
+var expandedDomainFields = []string{"domain", "depth", "count", "seed", "pivot", "value", "provenance"}
+
 // domains subcommand
 var domainsCmd = &cli.Command{
 	Name:                   "domains",
 	Usage:                  "extend domains from a domain",
+	UsageText:              "fofa domains [options] <domain> [domain...]",
 	UseShortOptionHandling: true,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
@@ -61,6 +69,42 @@ var domainsCmd = &cli.Command{
 			Usage:       "domain with count",
 			Destination: &withCount,
 		},
+		&cli.BoolFlag{
+			Name:        "dryRun",
+			Aliases:     []string{"dry-run"},
+			Value:       false,
+			Usage:       "print the estimated rows and quota cost, then exit without fetching",
+			Destination: &dryRun,
+		},
+		&cli.IntFlag{
+			Name:        "depth",
+			Usage:       "recursive mode if set, rounds of pivoting through --pivots of found domains",
+			Destination: &domainsDepth,
+		},
+		&cli.StringFlag{
+			Name:        "pivots",
+			Value:       strings.Join(gofofa.DefaultDomainPivots, ","),
+			Usage:       "pivots of recursive mode, can be certs_domains/cert_org/icon_hash/ip",
+			Destination: &domainsPivots,
+		},
+		&cli.IntFlag{
+			Name:        "maxDomains",
+			Aliases:     []string{"max-domains"},
+			Usage:       "stop recursive mode when so many domains are found, 0 means no limit",
+			Destination: &domainsMaxDomains,
+		},
+		&cli.IntFlag{
+			Name:        "maxQueries",
+			Aliases:     []string{"max-queries"},
+			Usage:       "stop recursive mode when so many api queries are sent, 0 means no limit",
+			Destination: &maxQueries,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "format of recursive mode, can be csv/json/xml",
+			Destination: &format,
+		},
 	},
 	Action: DomainsAction,
 }
@@ -94,6 +138,18 @@ func DomainsAction(ctx *cli.Context) error {
 	if len(domain) == 0 {
 		return errors.New("domain cannot be empty")
 	}
+	if domainsDepth > 0 {
+		return expandDomainsAction(ctx)
+	}
+
+	query := `domain="` + domain + `" && status_code="200" && cert.is_valid=true && cert.is_match=true`
+	fields := []string{"certs_domains"}
+	if dryRun {
+		return printCostEstimate([]string{query}, fields, gofofa.SearchOptions{
+			Full:     full,
+			UniqByIP: uniqByIP,
+		})
+	}
 
 	// gen output
 	var outTo io.Writer
@@ -110,7 +166,7 @@ func DomainsAction(ctx *cli.Context) error {
 	}
 
 	// do search
-	res, err := fofaCli.HostSearch(`domain="`+domain+`" && status_code="200" && cert.is_valid=true && cert.is_match=true`, size, []string{"certs_domains"}, gofofa.SearchOptions{
+	res, err := fofaCli.HostSearch(query, size, fields, gofofa.SearchOptions{
 		Full:     full,
 		UniqByIP: uniqByIP,
 	})
@@ -156,3 +212,50 @@ func DomainsAction(ctx *cli.Context) error {
 	}
 	return nil
 }
+
+// expandDomainsAction recursive mode of domains, every arg is a seed
+func expandDomainsAction(ctx *cli.Context) error {
+	if dryRun {
+		return errors.New("--dryRun does not work with --depth")
+	}
+
+	res, err := fofaCli.ExpandDomains(ctx.Context, ctx.Args().Slice(), gofofa.ExpandDomainsOptions{
+		Depth:      domainsDepth,
+		Size:       size,
+		Pivots:     strings.Split(domainsPivots, ","),
+		MaxDomains: domainsMaxDomains,
+		MaxQueries: maxQueries,
+		Full:       full,
+		UniqByIP:   uniqByIP,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("%d domains found by %d queries", len(res.Domains), res.Queries)
+	if len(res.Stopped) > 0 {
+		log.Println("expansion stopped early:", res.Stopped)
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, expandedDomainFields)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(res.Domains))
+	for _, d := range res.Domains {
+		rows = append(rows, []string{d.Domain, strconv.Itoa(d.Depth), strconv.Itoa(d.Count),
+			d.Seed, d.Pivot, d.Value, d.Provenance})
+	}
+	return closeOutWriter(writer, writer.WriteAll(rows))
+}
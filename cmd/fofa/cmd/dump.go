@@ -5,8 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/LubyRuffy/gofofa"
-	"github.com/LubyRuffy/gofofa/pkg/outformats"
-	"github.com/sirupsen/logrus"
+	"github.com/LubyRuffy/gofofa/pkg/probe"
+	"github.com/LubyRuffy/gofofa/pkg/store"
+	"github.com/LubyRuffy/gofofa/stream"
 	"github.com/urfave/cli/v2"
 	"io"
 	"log"
@@ -19,7 +20,7 @@ var dumpCmd = &cli.Command{
 	Name:                   "dump",
 	Usage:                  "fofa dump data",
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{
 			Name:        "fields",
 			Aliases:     []string{"f"},
@@ -30,14 +31,20 @@ var dumpCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:        "format",
 			Value:       "csv",
-			Usage:       "can be csv/json/xml",
+			Usage:       "can be csv/json/xml/tmpl",
 			Destination: &format,
 		},
+		&cli.StringFlag{
+			Name:        "outTemplate",
+			Aliases:     []string{"out-template"},
+			Usage:       "text/template of --format tmpl, like '{{.ip}}:{{.port}} {{.title}}'",
+			Destination: &outTemplate,
+		},
 		&cli.BoolFlag{
 			Name:        "json",
 			Aliases:     []string{"j"},
 			Usage:       "output use json format",
-			Destination: &json,
+			Destination: &jsonOutput,
 		},
 		&cli.StringFlag{
 			Name:        "outFile",
@@ -66,10 +73,15 @@ var dumpCmd = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "urlPrefix",
-			Value:       "http://",
-			Usage:       "prefix of url, default is http://, can be redis:// and so on ",
+			Value:       "",
+			Usage:       "prefix of url for fixUrl, like redis://, not set means scheme is decided by protocol with schemeMap",
 			Destination: &urlPrefix,
 		},
+		&cli.StringFlag{
+			Name:        "schemeMap",
+			Usage:       "json file of protocol to url scheme map for fixUrl, like {\"elastic\":\"https\"}",
+			Destination: &schemeMapFile,
+		},
 		&cli.BoolFlag{
 			Name:        "full",
 			Value:       false,
@@ -83,7 +95,37 @@ var dumpCmd = &cli.Command{
 			Usage:       "the amount of data contained in each batch",
 			Destination: &batchSize,
 		},
-	},
+		&cli.BoolFlag{
+			Name:        "dryRun",
+			Aliases:     []string{"dry-run"},
+			Value:       false,
+			Usage:       "print the estimated rows and quota cost, then exit without fetching",
+			Destination: &dryRun,
+		},
+		&cli.IntFlag{
+			Name:        "maxFCoin",
+			Aliases:     []string{"max-fcoin"},
+			Usage:       "stop when fcoin deducted would exceed it, 0 means no limit",
+			Destination: &maxFCoin,
+		},
+		&cli.IntFlag{
+			Name:        "maxRows",
+			Aliases:     []string{"max-rows"},
+			Usage:       "stop when rows fetched would exceed it, 0 means no limit",
+			Destination: &maxRows,
+		},
+		&cli.IntFlag{
+			Name:        "maxQueries",
+			Aliases:     []string{"max-queries"},
+			Usage:       "stop when api queries would exceed it, 0 means no limit",
+			Destination: &maxQueries,
+		},
+		&cli.StringFlag{
+			Name:        "store",
+			Usage:       "also upsert results into local asset store file, see fofa store",
+			Destination: &storeFile,
+		},
+	}, append(enrichFlags(), append(probeFlags(), xmlFlags()...)...)...),
 	Action: DumpAction,
 }
 
@@ -125,6 +167,33 @@ func DumpAction(ctx *cli.Context) error {
 		return errors.New("fofa fields cannot be empty")
 	}
 
+	schemeMap, err := loadSchemeMap()
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return printCostEstimate(queries, fields, gofofa.SearchOptions{
+			Full: full,
+		})
+	}
+
+	setBudget()
+
+	enricher, err := openEnricher()
+	if err != nil {
+		return err
+	}
+	if enricher != nil {
+		defer enricher.Close()
+		fields = enrichQueryFields(enricher, fields)
+	}
+	fields = probeQueryFields(fields)
+	var prober *probe.Prober
+	if probeEnabled {
+		prober = newProber(schemeMap)
+	}
+
 	// gen output
 	var outTo io.Writer
 	if len(outFile) > 0 {
@@ -139,48 +208,45 @@ func DumpAction(ctx *cli.Context) error {
 		outTo = os.Stdout
 	}
 
-	if json {
+	if jsonOutput {
 		format = "json"
 	}
 	// gen writer
-	var writer outformats.OutWriter
-	if hasBodyField(fields) && format == "csv" {
-		logrus.Warnln("fields contains body, so change format to json")
-		writer = outformats.NewJSONWriter(outTo, fields)
-	} else {
-		switch format {
-		case "csv":
-			writer = outformats.NewCSVWriter(outTo)
-		case "json":
-			writer = outformats.NewJSONWriter(outTo, fields)
-		case "xml":
-			writer = outformats.NewXMLWriter(outTo, fields)
-		default:
-			return fmt.Errorf("unknown format: %s", format)
+	writer, err := newOutWriter(outTo, probeOutFields(prober, enrichOutFields(enricher, fields)))
+	if err != nil {
+		return err
+	}
+
+	var assetStore *store.Store
+	if len(storeFile) > 0 {
+		if assetStore, err = openStore(""); err != nil {
+			return err
 		}
+		defer assetStore.Close()
 	}
 
 	// do search
 	for _, query := range queries {
 		log.Println("dump data of query:", query)
 
-		fetchedSize := 0
-		err := fofaCli.DumpSearch(query, size, batchSize, fields, func(res [][]string, allSize int) (err error) {
-			fetchedSize += len(res)
-			log.Printf("size: %d/%d, %.2f%%", fetchedSize, allSize, 100*float32(fetchedSize)/float32(allSize))
-			// output
-			err = writer.WriteAll(res)
-			return err
+		s := stream.FromDumpSearch(fofaCli, query, size, batchSize, fields, func(fetched int, total int) {
+			log.Printf("size: %d/%d, %.2f%%", fetched, total, 100*float32(fetched)/float32(total))
 		}, gofofa.SearchOptions{
 			FixUrl:    fixUrl,
 			UrlPrefix: urlPrefix,
+			SchemeMap: schemeMap,
 			Full:      full,
 		})
+		_, err := probeStream(prober, enrichStream(enricher, teeStore(s, assetStore, query))).
+			WriteTo(ctx.Context, writer)
 		if err != nil {
+			if errors.Is(err, gofofa.ErrBudgetExceeded) {
+				return closeOutWriter(writer, err)
+			}
 			log.Println("fetch error:", err)
 			//return err
 		}
 	}
 
-	return nil
+	return closeOutWriter(writer, nil)
 }
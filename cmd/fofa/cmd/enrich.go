@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/LubyRuffy/gofofa/pkg/mmdb"
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+var (
+	enrichMmdb   cli.StringSlice // name=file.mmdb of enrich databases
+	enrichFields string          // enrich columns like geo.city,asn.org
+)
+
+// enrichFlags flags of mmdb enrichment, shared by search and dump
+func enrichFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "enrichMmdb",
+			Aliases:     []string{"enrich-mmdb"},
+			Usage:       "local mmdb file to enrich rows by ip, like geo=GeoLite2-City.mmdb, can be repeated",
+			Destination: &enrichMmdb,
+		},
+		&cli.StringFlag{
+			Name:        "enrichFields",
+			Aliases:     []string{"enrich-fields"},
+			Usage:       "columns appended by enrichMmdb, like geo.city,geo.country,asn.org",
+			Destination: &enrichFields,
+		},
+	}
+}
+
+// openEnricher open enricher of --enrichMmdb, nil if not set
+func openEnricher() (*mmdb.Enricher, error) {
+	if len(enrichMmdb.Value()) == 0 {
+		if len(enrichFields) > 0 {
+			return nil, errors.New("enrichFields needs enrichMmdb")
+		}
+		return nil, nil
+	}
+	if len(enrichFields) == 0 {
+		return nil, errors.New("enrichMmdb needs enrichFields")
+	}
+	dbs, err := mmdb.ParseDatabases(enrichMmdb.Value())
+	if err != nil {
+		return nil, err
+	}
+	return mmdb.Open(dbs, strings.Split(enrichFields, ","))
+}
+
+// enrichQueryFields add ip to fields if enricher is set
+func enrichQueryFields(enricher *mmdb.Enricher, fields []string) []string {
+	if enricher == nil || hashField(fields, "ip") {
+		return fields
+	}
+	logrus.Warnln("enrich needs ip field, so add it to fields")
+	return append(fields, "ip")
+}
+
+// enrichStream append enrich columns to stream, nil enricher is ignored
+func enrichStream(enricher *mmdb.Enricher, s *stream.Stream) *stream.Stream {
+	if enricher == nil {
+		return s
+	}
+	return enricher.Stream(s)
+}
+
+// enrichOutFields fields of writer after enrichment
+func enrichOutFields(enricher *mmdb.Enricher, fields []string) []string {
+	if enricher == nil {
+		return fields
+	}
+	return append(append([]string{}, fields...), enricher.Columns()...)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// fingerprintFields fields of fingerprint output
+var fingerprintFields = []string{"name", "query", "count"}
+
+// fingerprint subcommand
+var fingerprintCmd = &cli.Command{
+	Name:                   "fingerprint",
+	Usage:                  "fetch site once and print fofa queries of its icon_hash/title/server/cert/headers/js files with counts",
+	UsageText:              "fofa fingerprint [options] <url>",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+		&cli.DurationFlag{
+			Name:        "timeout",
+			Value:       10 * time.Second,
+			Usage:       "timeout of each request",
+			Destination: &iconTimeout,
+		},
+		&cli.Int64Flag{
+			Name:        "maxSize",
+			Aliases:     []string{"max-size"},
+			Value:       2 * 1024 * 1024,
+			Usage:       "max bytes of downloaded content",
+			Destination: &iconMaxSize,
+		},
+		&cli.StringSliceFlag{
+			Name:        "header",
+			Aliases:     []string{"H"},
+			Usage:       "header of each request, like 'Cookie: a=b', can be repeated",
+			Destination: &iconHeaders,
+		},
+		&cli.BoolFlag{
+			Name:        "insecure",
+			Aliases:     []string{"k"},
+			Usage:       "skip tls verify",
+			Destination: &iconInsecure,
+		},
+	},
+	Action: fingerprintAction,
+}
+
+// fingerprintAction fingerprint action
+func fingerprintAction(ctx *cli.Context) error {
+	siteURL := ctx.Args().First()
+	if len(siteURL) == 0 {
+		return errors.New("fofa fingerprint needs url")
+	}
+
+	hasher, err := newIconHasher()
+	if err != nil {
+		return err
+	}
+	f, err := gofofa.FingerprintSite(ctx.Context, siteURL, hasher)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: status %d, icon %s", f.URL, f.StatusCode, f.IconURL)
+
+	// 每个查询的数量，失败的留空
+	count := func(query string) string {
+		n, err := fofaCli.HostSize(query)
+		if err != nil {
+			log.Println("count of", query, "failed:", err)
+			return ""
+		}
+		return fmt.Sprint(n)
+	}
+	var rows [][]string
+	for _, q := range f.Queries() {
+		rows = append(rows, []string{q.Name, q.Query, count(q.Query)})
+	}
+	if combined := f.CombinedQuery(); len(combined) > 0 {
+		rows = append(rows, []string{"combined", combined, count(combined)})
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		var of *os.File
+		if of, err = os.Create(outFile); err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = of
+		defer of.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, fingerprintFields)
+	if err != nil {
+		return err
+	}
+	return closeOutWriter(writer, writer.WriteAll(rows))
+}
@@ -1,18 +1,39 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/LubyRuffy/gofofa"
 	"github.com/urfave/cli/v2"
+	"os"
 	"strings"
 )
 
+var (
+	hostDetail bool
+)
+
 // host subcommand
 var hostCmd = &cli.Command{
 	Name:                   "host",
 	Usage:                  "fofa host",
 	UseShortOptionHandling: true,
-	Action:                 hostAction,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "detail",
+			Value:       false,
+			Usage:       "fetch port-level details, such as banner, products and update time of each port",
+			Destination: &hostDetail,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "text",
+			Usage:       "can be text/json",
+			Destination: &format,
+		},
+	},
+	Action: hostAction,
 }
 
 // hostAction stats action
@@ -22,13 +43,38 @@ func hostAction(ctx *cli.Context) error {
 	if len(host) == 0 {
 		return errors.New("fofa host cannot be empty")
 	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format: %s", format)
+	}
 
 	// do search
-	res, err := fofaCli.HostStats(host)
+	var res interface{}
+	var err error
+	if hostDetail {
+		res, err = fofaCli.HostDetail(ctx.Context, host)
+	} else {
+		res, err = fofaCli.HostStats(host)
+	}
 	if err != nil {
 		return err
 	}
 
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(res)
+	}
+
+	if hostDetail {
+		printHostDetail(res.(gofofa.HostDetailData))
+	} else {
+		printHostStats(res.(gofofa.HostStatsData))
+	}
+
+	return nil
+}
+
+func printHostStats(res gofofa.HostStatsData) {
 	fmt.Println("Host:\t\t", res.Host)
 	fmt.Println("IP:\t\t", res.IP)
 	fmt.Println("ASN:\t\t", res.ASN)
@@ -40,6 +86,27 @@ func hostAction(ctx *cli.Context) error {
 	fmt.Println("Categories:\t", strings.Join(res.Categories, ","))
 	fmt.Println("Products:\t", strings.Join(res.Products, ","))
 	fmt.Println("UpdateTime:\t", res.UpdateTime)
+}
 
-	return nil
+func printHostDetail(res gofofa.HostDetailData) {
+	fmt.Println("Host:\t\t", res.Host)
+	fmt.Println("IP:\t\t", res.IP)
+	fmt.Println("ASN:\t\t", res.ASN)
+	fmt.Println("ORG:\t\t", res.ORG)
+	fmt.Println("Country:\t", res.Country)
+	fmt.Println("CountryCode:\t", res.CountryCode)
+	fmt.Println("UpdateTime:\t", res.UpdateTime)
+	for _, port := range res.Ports {
+		var products []string
+		for _, p := range port.Products {
+			products = append(products, fmt.Sprintf("%s(%s)", p.Product, p.Category))
+		}
+		fmt.Printf("Port:\t\t %d/%s\t%s\t%s\t%s\n", port.Port, port.BaseProtocol, port.Protocol,
+			strings.Join(products, ","), port.UpdateTime)
+		if len(port.Banner) > 0 {
+			for _, line := range strings.Split(strings.TrimSpace(port.Banner), "\n") {
+				fmt.Println("\t\t", strings.TrimRight(line, "\r"))
+			}
+		}
+	}
 }
@@ -1,23 +1,55 @@
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/stream"
 	"github.com/pkg/browser"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
-	openBrowser bool
+	openBrowser   bool
+	iconTimeout   time.Duration   // timeout of each request
+	iconMaxSize   int64           // max bytes of downloaded content
+	iconHeaders   cli.StringSlice // headers of each request
+	iconInsecure  bool            // skip tls verify
+	iconAll       bool            // find all icons of url
+	iconSearch    bool            // search icon hash at fofa
+	iconStats     bool            // stats of icon hash at fofa
+	iconStatsSize int             // aggs size of stats
+	iconFromFile  string          // json results with body field
+	iconFetch     bool            // fetch remote icons of results
 )
 
+// iconBatchFields fields of batch mode
+var iconBatchFields = []string{"input", "icon_url", "hash", "error"}
+
+// iconAllFields fields of --all
+var iconAllFields = []string{"hash", "query", "count", "icons"}
+
+// iconStatsFields fields of --stats
+var iconStatsFields = []string{"country", "org", "port"}
+
+// iconClusterFields fields of --fromResults
+var iconClusterFields = []string{"hash", "count", "query", "icon_url", "hosts"}
+
 // icon subcommand
 var iconCmd = &cli.Command{
 	Name:                   "icon",
 	Usage:                  "fofa icon search",
+	UsageText:              "fofa icon [options] <url or file>\n   fofa icon [options] --search --fields ip,port,title <url or file>\n   fofa icon [options] --inFile urls.txt\n   fofa icon [options] --fromResults dump.json",
 	UseShortOptionHandling: true,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
@@ -26,6 +58,107 @@ var iconCmd = &cli.Command{
 			Usage:       "open fofa website once find the favicon",
 			Destination: &openBrowser,
 		},
+		&cli.BoolFlag{
+			Name:        "all",
+			Usage:       "find all icons of url, such as apple-touch-icon and manifest icons, print each hash with query and count",
+			Destination: &iconAll,
+		},
+		&cli.BoolFlag{
+			Name:        "search",
+			Usage:       "search icon_hash at fofa, results are written with --fields/--size/--format/--outFile, hash is logged to stderr",
+			Destination: &iconSearch,
+		},
+		&cli.BoolFlag{
+			Name:        "stats",
+			Usage:       "print country/org/port distribution of icon_hash at fofa, to stderr if --search is set",
+			Destination: &iconStats,
+		},
+		&cli.IntFlag{
+			Name:        "statsSize",
+			Aliases:     []string{"stats-size"},
+			Value:       5,
+			Usage:       "aggs size of --stats",
+			Destination: &iconStatsSize,
+		},
+		&cli.StringFlag{
+			Name:        "fields",
+			Aliases:     []string{"f"},
+			Value:       "ip,port",
+			Usage:       "fields of --search, visit fofa website for more info",
+			Destination: &fieldString,
+		},
+		&cli.IntFlag{
+			Name:        "size",
+			Aliases:     []string{"s"},
+			Value:       100,
+			Usage:       "size of --search",
+			Destination: &size,
+		},
+		&cli.BoolFlag{
+			Name:        "full",
+			Usage:       "search result for over a year",
+			Destination: &full,
+		},
+		&cli.StringFlag{
+			Name:        "fromResults",
+			Aliases:     []string{"from-results"},
+			Usage:       "json results of search/dump with host,body fields, icons of body are hashed and rows are clustered by hash",
+			Destination: &iconFromFile,
+		},
+		&cli.BoolFlag{
+			Name:        "fetch",
+			Usage:       "fetch remote icons of --fromResults, default only inline data:image icons are hashed offline",
+			Destination: &iconFetch,
+		},
+		&cli.StringFlag{
+			Name:        "inFile",
+			Aliases:     []string{"i"},
+			Usage:       "urls or files line by line, - means stdin, default is stdin if no url arg",
+			Destination: &inFile,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "format of batch mode, --search and --fromResults, can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+		&cli.IntFlag{
+			Name:        "workers",
+			Value:       10,
+			Usage:       "number of workers of batch mode",
+			Destination: &workers,
+		},
+		&cli.DurationFlag{
+			Name:        "timeout",
+			Value:       10 * time.Second,
+			Usage:       "timeout of each request",
+			Destination: &iconTimeout,
+		},
+		&cli.Int64Flag{
+			Name:        "maxSize",
+			Aliases:     []string{"max-size"},
+			Value:       2 * 1024 * 1024,
+			Usage:       "max bytes of downloaded content",
+			Destination: &iconMaxSize,
+		},
+		&cli.StringSliceFlag{
+			Name:        "header",
+			Aliases:     []string{"H"},
+			Usage:       "header of each request, like 'Cookie: a=b', can be repeated",
+			Destination: &iconHeaders,
+		},
+		&cli.BoolFlag{
+			Name:        "insecure",
+			Aliases:     []string{"k"},
+			Usage:       "skip tls verify",
+			Destination: &iconInsecure,
+		},
 	},
 	Action: iconAction,
 }
@@ -48,24 +181,86 @@ var iconCmd = &cli.Command{
 //	return err
 //}
 
+// newIconHasher create icon hasher of flags
+func newIconHasher() (*gofofa.IconHasher, error) {
+	options := []gofofa.IconHasherOption{
+		gofofa.WithIconTimeout(iconTimeout),
+		gofofa.WithIconMaxSize(iconMaxSize),
+		gofofa.WithIconInsecure(iconInsecure),
+		gofofa.WithIconConcurrency(workers),
+	}
+	for _, header := range iconHeaders.Value() {
+		k, v, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header: %s, should be 'key: value'", header)
+		}
+		options = append(options, gofofa.WithIconHeader(strings.TrimSpace(k), strings.TrimSpace(v)))
+	}
+	return gofofa.NewIconHasher(options...), nil
+}
+
 // iconAction icon action
 // url can be: local file; remote favicon url; remote homepage;
 func iconAction(ctx *cli.Context) error {
+	hasher, err := newIconHasher()
+	if err != nil {
+		return err
+	}
+
 	// valid same config
 	url := ctx.Args().First()
-	if len(url) == 0 {
-		return errors.New("url cannot be empty")
+	if len(iconFromFile) > 0 {
+		if len(url) > 0 || len(inFile) > 0 || iconAll || iconSearch || iconStats {
+			return errors.New("--fromResults works alone")
+		}
+		return iconFromResultsAction(ctx, hasher)
+	}
+	single := len(url) > 0 && len(inFile) == 0
+	if (iconSearch || iconStats) && (!single || iconAll) {
+		return errors.New("--search and --stats work with single url or file without --all")
+	}
+	if !single {
+		if iconAll {
+			return errors.New("--all works with single url")
+		}
+		return iconBatchAction(ctx, hasher)
+	}
+	if iconAll {
+		return iconAllAction(ctx, hasher, url)
 	}
 
 	logrus.Debug("open url: ", url)
 
 	// do search
-	hash, err := gofofa.IconHash(url)
+	hash, _, err := hasher.Hash(ctx.Context, url)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(hash)
+	// 搜索时stdout只输出结果
+	statsTo := io.Writer(os.Stdout)
+	if iconSearch {
+		log.Println("icon hash of", url, "is", hash)
+		statsTo = os.Stderr
+	} else {
+		fmt.Println(hash)
+	}
+
+	if iconStats {
+		res, err := fofaCli.StatsDetail(iconQuery(hash), iconStatsSize, iconStatsFields, gofofa.SearchOptions{
+			Full: full,
+		})
+		if err != nil {
+			return err
+		}
+		printStats(statsTo, res)
+	}
+
+	if iconSearch {
+		if err = writeHostSearch(ctx.Context, iconQuery(hash)); err != nil {
+			return err
+		}
+	}
 
 	if openBrowser {
 		openURL := fofaCli.Server + "/result?qbase64=" + base64.StdEncoding.EncodeToString([]byte("icon_hash="+hash))
@@ -81,3 +276,230 @@ func iconAction(ctx *cli.Context) error {
 
 	return nil
 }
+
+// iconBatchAction hash urls or files of inFile/stdin, emit input,icon_url,hash,error rows
+func iconBatchAction(ctx *cli.Context, hasher *gofofa.IconHasher) error {
+	var in io.Reader = os.Stdin
+	if len(inFile) > 0 && inFile != "-" {
+		f, err := os.Open(inFile)
+		if err != nil {
+			return fmt.Errorf("open inFile %s failed: %w", inFile, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, iconBatchFields)
+	if err != nil {
+		return err
+	}
+
+	// 分批计算，保持输入的顺序
+	inputs := stream.FromLines(in, "input").Batch(100)
+	failed := 0
+	n, err := stream.New(iconBatchFields, func(c context.Context, emit stream.EmitFunc) error {
+		return inputs.Run(c, func(rows [][]string) error {
+			urls := make([]string, 0, len(rows))
+			for _, row := range rows {
+				urls = append(urls, row[0])
+			}
+			out := make([][]string, 0, len(rows))
+			for _, r := range hasher.HashMany(c, urls) {
+				var errString string
+				if r.Err != nil {
+					errString = r.Err.Error()
+					failed++
+				}
+				out = append(out, []string{r.Input, r.IconURL, r.Hash, errString})
+			}
+			return emit(out)
+		})
+	}).WriteTo(ctx.Context, writer)
+	if err = closeOutWriter(writer, err); err != nil {
+		return err
+	}
+
+	log.Printf("%d icons hashed, %d failed", n-failed, failed)
+	return nil
+}
+
+// iconQuery fofa query of icon hash
+func iconQuery(hash string) string {
+	return fmt.Sprintf("icon_hash=%q", hash)
+}
+
+// iconAllAction hash all icons of url, emit hash,query,count,icons rows of distinct hashes
+func iconAllAction(ctx *cli.Context, hasher *gofofa.IconHasher, url string) error {
+	results, err := hasher.HashAll(ctx.Context, url)
+	if err != nil {
+		return err
+	}
+
+	// 相同hash的图标合并
+	icons := make(map[string][]string)
+	for _, r := range results {
+		iconURL := r.URL
+		if strings.HasPrefix(iconURL, "data:") {
+			iconURL, _, _ = strings.Cut(iconURL, ",")
+		}
+		if r.Err != nil {
+			logrus.Debugf("icon %s (%s) failed: %v", iconURL, r.Source, r.Err)
+			continue
+		}
+		icons[r.Hash] = append(icons[r.Hash], iconURL+" ("+r.Source+")")
+	}
+
+	var rows [][]string
+	for _, hash := range gofofa.DistinctIconHashes(results) {
+		query := iconQuery(hash)
+		var count string
+		if n, err := fofaCli.HostSize(query); err != nil {
+			log.Println("count of", query, "failed:", err)
+		} else {
+			count = fmt.Sprint(n)
+		}
+		rows = append(rows, []string{hash, query, count, strings.Join(icons[hash], " ")})
+	}
+	log.Printf("%d icons found, %d distinct hashes", len(results), len(rows))
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, iconAllFields)
+	if err != nil {
+		return err
+	}
+	return closeOutWriter(writer, writer.WriteAll(rows))
+}
+
+// iconCluster rows of same icon hash
+type iconCluster struct {
+	hash    string
+	iconURL string // first icon url of the hash
+	hosts   []string
+}
+
+// iconFromResultsAction hash icons of body field of json results, emit hash,count,query,icon_url,hosts rows of clusters
+// inline icons are hashed offline, remote icons are fetched only with --fetch
+func iconFromResultsAction(ctx *cli.Context, hasher *gofofa.IconHasher) error {
+	s, err := stream.FromJSONFile(iconFromFile, nil)
+	if err != nil {
+		return fmt.Errorf("read %s failed: %w", iconFromFile, err)
+	}
+	fields := s.Fields()
+	hostIndex := fieldIndex(fields, "host")
+	bodyIndex := fieldIndex(fields, "body")
+	if hostIndex < 0 || bodyIndex < 0 {
+		return errors.New("results should have host and body fields, such as dump with --fields host,body --format json")
+	}
+	protocolIndex := fieldIndex(fields, "protocol")
+	certIndex := fieldIndex(fields, "cert")
+	field := func(row []string, index int) string {
+		if index < 0 {
+			return ""
+		}
+		return row[index]
+	}
+
+	clusters := make(map[string]*iconCluster)
+	var total, noBody, notFetched, failed int
+	err = s.Parallel(workers, []string{"host", "icon_url", "hash"}, func(c context.Context, row []string) ([][]string, error) {
+		if len(row[bodyIndex]) == 0 {
+			return [][]string{{row[hostIndex], "", ""}}, nil
+		}
+		pageURL := gofofa.DefaultSchemeMap.URL(row[hostIndex], field(row, protocolIndex), field(row, certIndex))
+		hash, iconURL, err := hasher.HashBody(c, []byte(row[bodyIndex]), pageURL, iconFetch)
+		if err != nil {
+			if !errors.Is(err, gofofa.ErrIconNotFetched) {
+				logrus.Debugf("icon of %s failed: %v", row[hostIndex], err)
+			}
+			hash = ""
+			if len(iconURL) == 0 {
+				iconURL = "-"
+			}
+		}
+		return [][]string{{row[hostIndex], iconURL, hash}}, nil
+	}).Run(ctx.Context, func(rows [][]string) error {
+		for _, row := range rows {
+			total++
+			host, iconURL, hash := row[0], row[1], row[2]
+			switch {
+			case len(iconURL) == 0:
+				noBody++
+			case len(hash) > 0:
+				if clusters[hash] == nil {
+					clusters[hash] = &iconCluster{hash: hash, iconURL: iconURL}
+				}
+				clusters[hash].hosts = append(clusters[hash].hosts, host)
+			case !iconFetch && iconURL != "-":
+				notFetched++
+			default:
+				failed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 数量多的在前
+	sorted := make([]*iconCluster, 0, len(clusters))
+	for _, c := range clusters {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].hosts) != len(sorted[j].hosts) {
+			return len(sorted[i].hosts) > len(sorted[j].hosts)
+		}
+		return sorted[i].hash < sorted[j].hash
+	})
+	rows := make([][]string, 0, len(sorted))
+	for _, c := range sorted {
+		rows = append(rows, []string{c.hash, strconv.Itoa(len(c.hosts)), iconQuery(c.hash), c.iconURL, strings.Join(c.hosts, " ")})
+	}
+	log.Printf("%d rows, %d clusters, %d without body, %d remote icons not fetched, %d failed",
+		total, len(rows), noBody, notFetched, failed)
+	if notFetched > 0 {
+		log.Println("remote icons are not fetched, use --fetch to hash them")
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, iconClusterFields)
+	if err != nil {
+		return err
+	}
+	return closeOutWriter(writer, writer.WriteAll(rows))
+}
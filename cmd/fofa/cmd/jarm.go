@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/urfave/cli/v2"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	jarmTimeout time.Duration // timeout of each client hello
+	jarmCount   bool          // count jarm query at fofa
+	jarmSearch  bool          // search jarm query at fofa
+)
+
+// jarm subcommand
+var jarmCmd = &cli.Command{
+	Name:                   "jarm",
+	Usage:                  "calc jarm tls fingerprint of host:port, then count or search it at fofa",
+	UsageText:              "fofa jarm [options] <host:port>",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:        "timeout",
+			Value:       5 * time.Second,
+			Usage:       "timeout of each client hello",
+			Destination: &jarmTimeout,
+		},
+		&cli.BoolFlag{
+			Name:        "count",
+			Usage:       "print count of jarm=\"<hash>\" at fofa",
+			Destination: &jarmCount,
+		},
+		&cli.BoolFlag{
+			Name:        "search",
+			Usage:       "search jarm=\"<hash>\" at fofa, hash is logged to stderr",
+			Destination: &jarmSearch,
+		},
+		&cli.StringFlag{
+			Name:        "fields",
+			Aliases:     []string{"f"},
+			Value:       "ip,port",
+			Usage:       "fields of search",
+			Destination: &fieldString,
+		},
+		&cli.IntFlag{
+			Name:        "size",
+			Aliases:     []string{"s"},
+			Value:       100,
+			Usage:       "size of search",
+			Destination: &size,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "format of search, can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+	},
+	Action: jarmAction,
+}
+
+// jarmAction jarm action
+func jarmAction(ctx *cli.Context) error {
+	hostport := ctx.Args().First()
+	if len(hostport) == 0 {
+		return errors.New("fofa jarm needs host:port")
+	}
+
+	hash, err := gofofa.JARM(ctx.Context, hostport, gofofa.WithJARMTimeout(jarmTimeout))
+	if err != nil {
+		return err
+	}
+	if !jarmSearch {
+		fmt.Println(hash)
+	} else {
+		log.Println("jarm of", hostport, "is", hash)
+	}
+	if !jarmSearch && !jarmCount {
+		return nil
+	}
+
+	// 全0说明不是tls服务，没必要查询
+	if strings.Trim(hash, "0") == "" {
+		return fmt.Errorf("no tls handshake of %s succeeded", hostport)
+	}
+	query := fmt.Sprintf("jarm=%q", hash)
+
+	if jarmCount {
+		count, err := fofaCli.HostSize(query)
+		if err != nil {
+			return err
+		}
+		if !jarmSearch {
+			fmt.Println(count)
+			return nil
+		}
+		log.Printf("%s: %d results", query, count)
+	}
+
+	return writeHostSearch(ctx.Context, query)
+}
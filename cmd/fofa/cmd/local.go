@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa/pkg/fofaquery"
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	localInFiles cli.StringSlice // json files written by search/dump
+)
+
+// local subcommand
+var localCmd = &cli.Command{
+	Name:                   "local",
+	Usage:                  "evaluate fofa query against local json results, no quota spent",
+	UsageText:              "fofa local [options] --in dump.json '<query>'",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "in",
+			Aliases:     []string{"inFile", "i"},
+			Usage:       "json file written by search/dump --format json, - means stdin, can be repeated",
+			Destination: &localInFiles,
+		},
+		&cli.StringFlag{
+			Name:        "fields",
+			Aliases:     []string{"f"},
+			Usage:       "output fields, default is fields of first record",
+			Destination: &fieldString,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+	},
+	Action: localAction,
+}
+
+// localAction local action
+func localAction(ctx *cli.Context) error {
+	query := ctx.Args().First()
+	if len(query) == 0 {
+		return errors.New("fofa query cannot be empty")
+	}
+	inFiles := localInFiles.Value()
+	if len(inFiles) == 0 {
+		return errors.New("need json file, use --in")
+	}
+
+	expr, err := fofaquery.Parse(query)
+	if err != nil {
+		return fmt.Errorf("parse query failed: %w", err)
+	}
+
+	var fields []string
+	if len(fieldString) > 0 {
+		fields = strings.Split(fieldString, ",")
+	}
+
+	// 读取输出字段和查询用到的字段
+	withQueryFields := func(fields []string) []string {
+		readFields := append([]string{}, fields...)
+		for _, f := range expr.Fields() {
+			if !hashField(readFields, f) {
+				readFields = append(readFields, f)
+			}
+		}
+		return readFields
+	}
+	var readFields []string
+	if len(fields) > 0 {
+		readFields = withQueryFields(fields)
+	}
+
+	var streams []*stream.Stream
+	for _, filename := range inFiles {
+		s, err := stream.FromJSONFile(filename, readFields)
+		if err != nil {
+			return fmt.Errorf("read %s failed: %w", filename, err)
+		}
+		if readFields == nil {
+			// 第一条记录确定输出字段，并检查查询的字段是否存在
+			fields = s.Fields()
+			readFields = withQueryFields(fields)
+			if padding := len(readFields) - len(fields); padding > 0 {
+				log.Printf("[WARNING] fields %s of query are not in records", strings.Join(readFields[len(fields):], ","))
+				mapped := s.Map(func(row []string) ([]string, error) {
+					return append(row, make([]string, padding)...), nil
+				})
+				s = stream.New(readFields, func(ctx context.Context, emit stream.EmitFunc) error {
+					return mapped.Run(ctx, emit)
+				})
+			}
+		}
+		streams = append(streams, s)
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		var f *os.File
+		if f, err = os.Create(outFile); err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, fields)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	record := make(map[string]string, len(readFields))
+	matched, err := stream.Concat(streams...).
+		Filter(func(row []string) bool {
+			total++
+			for i, f := range readFields {
+				record[f] = row[i]
+			}
+			return expr.Match(record)
+		}).
+		Map(func(row []string) ([]string, error) {
+			return row[:len(fields)], nil
+		}).
+		WriteTo(ctx.Context, writer)
+	if err = closeOutWriter(writer, err); err != nil {
+		return err
+	}
+
+	log.Printf("%d/%d records matched", matched, total)
+	return nil
+}
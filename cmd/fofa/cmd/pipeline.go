@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/pipeline"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// pipeline subcommand
+var pipelineCmd = &cli.Command{
+	Name:  "pipeline",
+	Usage: "run .fofapipe scripts",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "run",
+			Usage:     "run .fofapipe script, records of last step are written to stdout unless it is Output",
+			UsageText: "fofa pipeline run [options] file.fofapipe",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "fields",
+					Aliases:     []string{"f"},
+					Usage:       "output fields, default is fields of first record",
+					Destination: &fieldString,
+				},
+				&cli.StringFlag{
+					Name:        "format",
+					Value:       "csv",
+					Usage:       "can be csv/json/xml",
+					Destination: &format,
+				},
+				&cli.StringFlag{
+					Name:        "outFile",
+					Aliases:     []string{"o"},
+					Usage:       "if not set, wirte to stdout",
+					Destination: &outFile,
+				},
+			},
+			Action: pipelineRunAction,
+		},
+	},
+}
+
+// pipelineRunAction run pipeline file
+func pipelineRunAction(ctx *cli.Context) error {
+	filename := ctx.Args().First()
+	if len(filename) == 0 {
+		return errors.New("need .fofapipe file")
+	}
+	if format != "csv" && format != "json" && format != "xml" {
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	p, err := pipeline.ParseFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var client *gofofa.Client
+	if p.HasStep("FetchFofa") {
+		if client, err = newFofaClient(); err != nil {
+			return err
+		}
+	}
+
+	// 最后一步是Output时不再输出
+	var writer *pipeline.Writer
+	var sink func(*pipeline.Record) error
+	steps := p.Steps()
+	if steps[len(steps)-1] != "Output" {
+		var outTo io.Writer
+		if len(outFile) > 0 {
+			var f *os.File
+			if f, err = os.Create(outFile); err != nil {
+				return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+			}
+			outTo = f
+			defer f.Close()
+		} else {
+			outTo = os.Stdout
+		}
+
+		var fields []string
+		if len(fieldString) > 0 {
+			fields = strings.Split(fieldString, ",")
+		}
+		writer = pipeline.NewWriter(outTo, format, fields)
+		sink = writer.Write
+	}
+
+	runCtx, stop := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if client != nil {
+		client.SetContext(runCtx)
+	}
+
+	stats, err := p.Run(runCtx, client, sink)
+	for _, s := range stats {
+		log.Println(s)
+	}
+	if writer != nil {
+		if errClose := writer.Close(); err == nil {
+			err = errClose
+		}
+	}
+	return err
+}
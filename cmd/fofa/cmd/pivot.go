@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+)
+
+var (
+	pivotSeeds    cli.StringSlice // seeds like ip:1.2.3.4
+	pivotDepth    int             // rounds of crawling
+	pivotMaxNodes int             // stop when so many nodes are found
+)
+
+// pivot subcommand
+var pivotCmd = &cli.Command{
+	Name:                   "pivot",
+	Usage:                  "crawl relations of ip, cert, domain and icon_hash at fofa, export graph as dot/graphml/json",
+	UsageText:              "fofa pivot [options] --seed ip:1.2.3.4 [--seed domain:example.com]",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "seed",
+			Usage:       "start node, can be ip:<ip>, domain:<domain>, cert:<subject cn> or icon_hash:<hash>, can be repeated",
+			Destination: &pivotSeeds,
+		},
+		&cli.IntFlag{
+			Name:        "depth",
+			Value:       1,
+			Usage:       "rounds of crawling",
+			Destination: &pivotDepth,
+		},
+		&cli.IntFlag{
+			Name:        "size",
+			Aliases:     []string{"s"},
+			Value:       100,
+			Usage:       "size of each query",
+			Destination: &size,
+		},
+		&cli.IntFlag{
+			Name:        "maxQueries",
+			Aliases:     []string{"max-queries"},
+			Value:       50,
+			Usage:       "query budget of the crawl, 0 means no limit",
+			Destination: &maxQueries,
+		},
+		&cli.IntFlag{
+			Name:        "maxNodes",
+			Aliases:     []string{"max-nodes"},
+			Usage:       "stop when so many nodes are found, 0 means no limit",
+			Destination: &pivotMaxNodes,
+		},
+		&cli.BoolFlag{
+			Name:        "full",
+			Usage:       "search result for over a year",
+			Destination: &full,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "dot",
+			Usage:       "format of graph, can be dot/graphml/json",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+	},
+	Action: pivotAction,
+}
+
+// pivotAction pivot action
+func pivotAction(ctx *cli.Context) error {
+	seeds := append(pivotSeeds.Value(), ctx.Args().Slice()...)
+	if len(seeds) == 0 {
+		return errors.New("fofa pivot needs --seed")
+	}
+
+	var write func(g gofofa.PivotGraph, w io.Writer) error
+	switch format {
+	case "dot":
+		write = gofofa.PivotGraph.WriteDOT
+	case "graphml":
+		write = gofofa.PivotGraph.WriteGraphML
+	case "json":
+		write = gofofa.PivotGraph.WriteJSON
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	g, err := fofaCli.Pivot(ctx.Context, seeds, gofofa.PivotOptions{
+		Depth:      pivotDepth,
+		Size:       size,
+		MaxNodes:   pivotMaxNodes,
+		MaxQueries: maxQueries,
+		Full:       full,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("%d nodes, %d links by %d queries", len(g.Nodes), len(g.Links), g.Queries)
+	if len(g.Stopped) > 0 {
+		log.Println("crawl stopped early:", g.Stopped)
+	}
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	return write(g, outTo)
+}
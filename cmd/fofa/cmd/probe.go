@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/probe"
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	probeEnabled     bool            // probe results of search/dump
+	probeConcurrency int             // targets probed concurrently
+	probeTimeout     time.Duration   // timeout of each target
+	probeRate        float64         // max targets per second
+	probeInFiles     cli.StringSlice // input files of probe
+	probeInFormat    string          // format of input files
+)
+
+// probeFlags flags of probing results, shared by search and dump
+func probeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "probe",
+			Usage:       "check liveness of results, alive/live_status_code/live_title/latency/error are appended, see fofa probe",
+			Destination: &probeEnabled,
+		},
+		&cli.IntFlag{
+			Name:        "probeConcurrency",
+			Aliases:     []string{"probe-concurrency"},
+			Value:       20,
+			Usage:       "targets probed concurrently",
+			Destination: &probeConcurrency,
+		},
+		&cli.DurationFlag{
+			Name:        "probeTimeout",
+			Aliases:     []string{"probe-timeout"},
+			Value:       5 * time.Second,
+			Usage:       "timeout of each target",
+			Destination: &probeTimeout,
+		},
+		&cli.Float64Flag{
+			Name:        "probeRate",
+			Aliases:     []string{"probe-rate"},
+			Usage:       "max targets probed per second, 0 means no limit",
+			Destination: &probeRate,
+		},
+	}
+}
+
+// probe subcommand
+var probeCmd = &cli.Command{
+	Name:                   "probe",
+	Usage:                  "check liveness of host or ip,port rows by tcp connect, tls handshake or http get",
+	UsageText:              "fofa probe [options] [--in results.csv]",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "in",
+			Aliases:     []string{"inFile", "i"},
+			Usage:       "file written by search/dump, - means stdin, can be repeated, default is stdin",
+			Destination: &probeInFiles,
+		},
+		&cli.StringFlag{
+			Name:        "inFormat",
+			Value:       "csv",
+			Usage:       "format of input, can be csv/json",
+			Destination: &probeInFormat,
+		},
+		&cli.StringFlag{
+			Name:        "fields",
+			Aliases:     []string{"f"},
+			Usage:       "fields of input, default is ip,port for csv and fields of first record for json",
+			Destination: &fieldString,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+		&cli.IntFlag{
+			Name:        "concurrency",
+			Aliases:     []string{"c"},
+			Value:       20,
+			Usage:       "targets probed concurrently",
+			Destination: &probeConcurrency,
+		},
+		&cli.DurationFlag{
+			Name:        "timeout",
+			Value:       5 * time.Second,
+			Usage:       "timeout of each target",
+			Destination: &probeTimeout,
+		},
+		&cli.Float64Flag{
+			Name:        "rate",
+			Usage:       "max targets probed per second, 0 means no limit",
+			Destination: &probeRate,
+		},
+		&cli.StringFlag{
+			Name:        "schemeMap",
+			Usage:       "json file of protocol to url scheme map, like {\"elastic\":\"https\"}",
+			Destination: &schemeMapFile,
+		},
+	},
+	Action: probeAction,
+}
+
+// newProber create prober of flags
+func newProber(schemeMap gofofa.SchemeMap) *probe.Prober {
+	return probe.New(probe.Options{
+		Concurrency: probeConcurrency,
+		Timeout:     probeTimeout,
+		Rate:        probeRate,
+		SchemeMap:   schemeMap,
+	})
+}
+
+// probeQueryFields add host to fields if probe is enabled and no target field
+func probeQueryFields(fields []string) []string {
+	if !probeEnabled || hashField(fields, "host") || (hashField(fields, "ip") && hashField(fields, "port")) {
+		return fields
+	}
+	logrus.Warnln("probe needs host or ip,port field, so add host to fields")
+	return append(fields, "host")
+}
+
+// probeStream probe rows of stream, nil prober is ignored
+func probeStream(prober *probe.Prober, s *stream.Stream) *stream.Stream {
+	if prober == nil {
+		return s
+	}
+	return prober.Stream(s)
+}
+
+// probeOutFields fields of writer after probing
+func probeOutFields(prober *probe.Prober, fields []string) []string {
+	if prober == nil {
+		return fields
+	}
+	return append(append([]string{}, fields...), probe.Columns...)
+}
+
+// probeAction probe action
+func probeAction(ctx *cli.Context) error {
+	inFiles := probeInFiles.Value()
+	if len(inFiles) == 0 {
+		inFiles = []string{"-"}
+	}
+
+	var fields []string
+	if len(fieldString) > 0 {
+		fields = strings.Split(fieldString, ",")
+	}
+
+	var streams []*stream.Stream
+	for _, filename := range inFiles {
+		var s *stream.Stream
+		var err error
+		switch probeInFormat {
+		case "csv":
+			if fields == nil {
+				fields = []string{"ip", "port"}
+			}
+			s, err = stream.FromCSVFile(filename, fields)
+		case "json":
+			s, err = stream.FromJSONFile(filename, fields)
+		default:
+			return fmt.Errorf("unknown input format: %s", probeInFormat)
+		}
+		if err != nil {
+			return fmt.Errorf("read %s failed: %w", filename, err)
+		}
+		if fields == nil {
+			fields = s.Fields()
+		}
+		streams = append(streams, s)
+	}
+	if !hashField(fields, "host") && !hashField(fields, "ip") {
+		return errors.New("input should have host or ip field")
+	}
+
+	schemeMap, err := loadSchemeMap()
+	if err != nil {
+		return err
+	}
+	prober := newProber(schemeMap)
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		var f *os.File
+		if f, err = os.Create(outFile); err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, probeOutFields(prober, fields))
+	if err != nil {
+		return err
+	}
+
+	alive := 0
+	total, err := prober.Stream(stream.Concat(streams...)).
+		Map(func(row []string) ([]string, error) {
+			if row[len(fields)] == "true" {
+				alive++
+			}
+			return row, nil
+		}).
+		WriteTo(ctx.Context, writer)
+	if err = closeOutWriter(writer, err); err != nil {
+		return err
+	}
+
+	log.Printf("%d/%d targets alive", alive, total)
+	return nil
+}
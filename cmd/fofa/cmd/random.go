@@ -4,17 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"github.com/LubyRuffy/gofofa"
-	"github.com/LubyRuffy/gofofa/pkg/outformats"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	"math/rand"
 	"os"
 	"strings"
 	"time"
 )
 
 var (
-	sleepMS int
+	sleepMS          int
+	randomSeed       int64  // seed of random timestamps
+	randomEnd        string // end date of random timestamps
+	randomDistinctBy string // fields identifying a row
+	randomStratify   string // stats fields of stratified sampling
+	randomStatsSize  int    // top values of each stratify field
+	randomMaxTries   int    // queries tried for one new row
 )
 
 // random subcommand
@@ -22,7 +26,7 @@ var randomCmd = &cli.Command{
 	Name:                   "random",
 	Usage:                  "fofa random data generator",
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{
 			Name:        "fields",
 			Aliases:     []string{"f"},
@@ -33,9 +37,15 @@ var randomCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:        "format",
 			Value:       "json",
-			Usage:       "can be csv/json/xml",
+			Usage:       "can be csv/json/xml/tmpl",
 			Destination: &format,
 		},
+		&cli.StringFlag{
+			Name:        "outTemplate",
+			Aliases:     []string{"out-template"},
+			Usage:       "text/template of --format tmpl, like '{{.ip}}:{{.port}} {{.title}}'",
+			Destination: &outTemplate,
+		},
 		&cli.IntFlag{
 			Name:        "size",
 			Aliases:     []string{"s"},
@@ -57,17 +67,56 @@ var randomCmd = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "urlPrefix",
-			Value:       "http://",
-			Usage:       "prefix of url, default is http://, can be redis:// and so on ",
+			Value:       "",
+			Usage:       "prefix of url for fixUrl, like redis://, not set means scheme is decided by protocol with schemeMap",
 			Destination: &urlPrefix,
 		},
+		&cli.StringFlag{
+			Name:        "schemeMap",
+			Usage:       "json file of protocol to url scheme map for fixUrl, like {\"elastic\":\"https\"}",
+			Destination: &schemeMapFile,
+		},
 		&cli.BoolFlag{
 			Name:        "full",
 			Value:       false,
 			Usage:       "search result for over a year",
 			Destination: &full,
 		},
-	},
+		&cli.Int64Flag{
+			Name:        "seed",
+			Usage:       "seed of random timestamps for reproducible sample, 0 means random",
+			Destination: &randomSeed,
+		},
+		&cli.StringFlag{
+			Name:        "end",
+			Usage:       "timestamps are drawn from the year before this date, like 2024-01-01, default is now, or today when --seed is set",
+			Destination: &randomEnd,
+		},
+		&cli.StringFlag{
+			Name:        "distinctBy",
+			Aliases:     []string{"distinct-by"},
+			Usage:       "fields identifying a row, rows seen before are skipped, like ip or ip,port",
+			Destination: &randomDistinctBy,
+		},
+		&cli.StringFlag{
+			Name:        "stratify",
+			Usage:       "stats fields like country,protocol, size is split by their proportions at fofa",
+			Destination: &randomStratify,
+		},
+		&cli.IntFlag{
+			Name:        "statsSize",
+			Value:       10,
+			Usage:       "top values of each stratify field",
+			Destination: &randomStatsSize,
+		},
+		&cli.IntFlag{
+			Name:        "maxTries",
+			Aliases:     []string{"max-tries"},
+			Value:       10,
+			Usage:       "queries tried for one new row",
+			Destination: &randomMaxTries,
+		},
+	}, xmlFlags()...),
 	Action: randomAction,
 }
 
@@ -83,6 +132,11 @@ func randomAction(ctx *cli.Context) error {
 	if len(fields) == 0 {
 		return errors.New("fofa fields cannot be empty")
 	}
+
+	schemeMap, err := loadSchemeMap()
+	if err != nil {
+		return err
+	}
 	hostIndex := -1
 	if ctx.Bool("verbose") {
 		if !hashField(fields, "host") {
@@ -92,62 +146,76 @@ func randomAction(ctx *cli.Context) error {
 		hostIndex = fieldIndex(fields, "host")
 	}
 
-	// gen writer
-	outTo := os.Stdout
-	var writer outformats.OutWriter
-	if hasBodyField(fields) && format == "csv" {
-		logrus.Warnln("fields contains body, so change format to json")
-		writer = outformats.NewJSONWriter(outTo, fields)
-	} else {
-		switch format {
-		case "csv":
-			writer = outformats.NewCSVWriter(outTo)
-		case "json":
-			writer = outformats.NewJSONWriter(outTo, fields)
-		case "xml":
-			writer = outformats.NewXMLWriter(outTo, fields)
-		default:
-			return fmt.Errorf("unknown format: %s", format)
+	options := gofofa.SampleOptions{
+		Seed:     randomSeed,
+		MaxTries: randomMaxTries,
+		Interval: time.Duration(sleepMS) * time.Millisecond,
+		Search: gofofa.SearchOptions{
+			FixUrl:    fixUrl,
+			UrlPrefix: urlPrefix,
+			SchemeMap: schemeMap,
+			Full:      full,
+		},
+	}
+	if len(randomEnd) > 0 {
+		if options.End, err = time.ParseInLocation("2006-01-02", randomEnd, time.Local); err != nil {
+			return fmt.Errorf("invalid end date %s: %w", randomEnd, err)
 		}
 	}
+	if len(randomDistinctBy) > 0 {
+		options.DistinctBy = strings.Split(randomDistinctBy, ",")
+	}
+	sampler := fofaCli.NewSampler(fields, options)
+	if randomSeed != 0 {
+		logrus.Infoln("seed:", randomSeed, "end:", sampler.End().Format("2006-01-02 15:04:05"))
+	}
 
-	// do search
-	for i := 0; i < size || size == -1; i++ {
-		newQuery := query
-		if !strings.HasPrefix(newQuery, "host=") && !strings.HasPrefix(query, "ip=") {
-			max := time.Now()
-			min := max.AddDate(-1, 0, 0)
-			delta := max.Unix() - min.Unix()
-			sec := rand.Int63n(delta) + min.Unix()
-			ts := time.Unix(sec, 0).Format("2006-01-02 15:04:05")
-			newQuery = newQuery + ` && before="` + ts + `"`
+	// 分层抽样，每层按比例分配数量
+	strata := []gofofa.SampleStratum{{Query: query, Size: size}}
+	if len(randomStratify) > 0 {
+		if size < 1 {
+			return errors.New("--stratify needs size greater than 0")
 		}
-
-		res, err := fofaCli.HostSearch(newQuery, 1, fields, gofofa.SearchOptions{
-			FixUrl:    fixUrl,
-			UrlPrefix: urlPrefix,
-			Full:      full,
-		})
+		strata, err = fofaCli.SampleStrata(query, strings.Split(randomStratify, ","), randomStatsSize,
+			gofofa.SearchOptions{Full: full})
 		if err != nil {
 			return err
 		}
+		gofofa.AllocateSample(strata, size)
+	}
 
-		if ctx.Bool("verbose") {
-			logrus.Debugln("host:", res[0][hostIndex])
-		}
+	// gen writer
+	outTo := os.Stdout
+	writer, err := newOutWriter(outTo, fields)
+	if err != nil {
+		return err
+	}
 
-		// output
-		if err = writer.WriteAll(res); err != nil {
-			return err
+	// do search
+	for _, stratum := range strata {
+		if len(stratum.Values) > 0 {
+			logrus.Debugln("stratum:", stratum.Values, "count:", stratum.Count, "size:", stratum.Size)
 		}
+		for i := 0; i < stratum.Size || stratum.Size == -1; i++ {
+			row, err := sampler.Next(ctx.Context, stratum.Query)
+			if errors.Is(err, gofofa.ErrNoNewSample) {
+				logrus.Warnf("no new sample of %s after %d tries, %d rows drawn", stratum.Query, randomMaxTries, i)
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if ctx.Bool("verbose") {
+				logrus.Debugln("host:", row[hostIndex])
+			}
 
-		// 不是最后一次
-		if i < size-1 {
-			if sleepMS > 0 {
-				time.Sleep(time.Duration(sleepMS))
+			// output
+			if err = writer.WriteAll([][]string{row}); err != nil {
+				return err
 			}
 		}
 	}
 
-	return nil
+	return closeOutWriter(writer, nil)
 }
@@ -1,12 +1,14 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/LubyRuffy/gofofa"
 	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"github.com/LubyRuffy/gofofa/pkg/probe"
+	"github.com/LubyRuffy/gofofa/pkg/store"
+	"github.com/LubyRuffy/gofofa/stream"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/time/rate"
@@ -15,7 +17,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 )
 
 var (
@@ -29,11 +30,17 @@ var (
 	urlPrefix     string // each host fix as url, like 1.1.1.1,80 will change to http://1.1.1.1
 	full          bool   // search result for over a year
 	batchSize     int    // amount of data contained in each batch, only for dump
-	json          bool   // out format as json for short
+	jsonOutput    bool   // out format as json for short
 	uniqByIP      bool   // group by ip
 	workers       int    // number of workers
 	ratePerSecond int    // fofa request per second
 	template      string // template in pipeline mode
+	outTemplate   string // text/template of tmpl format
+	schemeMapFile string // json file of protocol to url scheme map
+	dryRun        bool   // just estimate cost, no data fetched
+	maxFCoin      int    // max fcoin of command
+	maxRows       int    // max rows of command
+	maxQueries    int    // max api queries of command
 )
 
 // search subcommand
@@ -41,7 +48,7 @@ var searchCmd = &cli.Command{
 	Name:                   "search",
 	Usage:                  "fofa host search",
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{
 			Name:        "fields",
 			Aliases:     []string{"f"},
@@ -52,7 +59,7 @@ var searchCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:        "format",
 			Value:       "csv",
-			Usage:       "can be csv/json/xml",
+			Usage:       "can be csv/json/xml/tmpl",
 			Destination: &format,
 		},
 		&cli.StringFlag{
@@ -83,9 +90,14 @@ var searchCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:        "urlPrefix",
 			Value:       "",
-			Usage:       "prefix of url, default is http://, can be redis:// and so on ",
+			Usage:       "prefix of url for fixUrl, like redis://, not set means scheme is decided by protocol with schemeMap",
 			Destination: &urlPrefix,
 		},
+		&cli.StringFlag{
+			Name:        "schemeMap",
+			Usage:       "json file of protocol to url scheme map for fixUrl, like {\"elastic\":\"https\"}",
+			Destination: &schemeMapFile,
+		},
 		&cli.BoolFlag{
 			Name:        "full",
 			Value:       false,
@@ -116,7 +128,43 @@ var searchCmd = &cli.Command{
 			Usage:       "template in pipeline mode",
 			Destination: &template,
 		},
-	},
+		&cli.StringFlag{
+			Name:        "outTemplate",
+			Aliases:     []string{"out-template"},
+			Usage:       "text/template of --format tmpl, like '{{.ip}}:{{.port}} {{.title}}'",
+			Destination: &outTemplate,
+		},
+		&cli.BoolFlag{
+			Name:        "dryRun",
+			Aliases:     []string{"dry-run"},
+			Value:       false,
+			Usage:       "print the estimated rows and quota cost, then exit without fetching",
+			Destination: &dryRun,
+		},
+		&cli.IntFlag{
+			Name:        "maxFCoin",
+			Aliases:     []string{"max-fcoin"},
+			Usage:       "stop when fcoin deducted would exceed it, 0 means no limit",
+			Destination: &maxFCoin,
+		},
+		&cli.IntFlag{
+			Name:        "maxRows",
+			Aliases:     []string{"max-rows"},
+			Usage:       "stop when rows fetched would exceed it, 0 means no limit",
+			Destination: &maxRows,
+		},
+		&cli.IntFlag{
+			Name:        "maxQueries",
+			Aliases:     []string{"max-queries"},
+			Usage:       "stop when api queries would exceed it, 0 means no limit",
+			Destination: &maxQueries,
+		},
+		&cli.StringFlag{
+			Name:        "store",
+			Usage:       "also upsert results into local asset store file, see fofa store",
+			Destination: &storeFile,
+		},
+	}, append(enrichFlags(), append(probeFlags(), xmlFlags()...)...)...),
 	Action: SearchAction,
 }
 
@@ -142,6 +190,92 @@ func hasBodyField(fields []string) bool {
 	return hashField(fields, "body")
 }
 
+// newOutWriter generate writer of format
+func newOutWriter(outTo io.Writer, fields []string) (outformats.OutWriter, error) {
+	if hasBodyField(fields) && format == "csv" {
+		logrus.Warnln("fields contains body, so change format to json")
+		return outformats.NewJSONWriter(outTo, fields), nil
+	}
+
+	switch format {
+	case "csv":
+		return outformats.NewCSVWriter(outTo), nil
+	case "json":
+		return outformats.NewJSONWriter(outTo, fields), nil
+	case "xml":
+		return newXMLWriter(outTo, fields)
+	case "tmpl":
+		if len(outTemplate) == 0 {
+			return nil, errors.New("format tmpl needs --outTemplate")
+		}
+		return outformats.NewTemplateWriter(outTo, fields, outTemplate)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// applyTemplate fill value into query template of pipeline mode, {} is replaced by quoted value
+func applyTemplate(template string, value string) string {
+	return strings.ReplaceAll(template, "{}", strconv.Quote(value))
+}
+
+// loadSchemeMap load custom scheme map of fixUrl if set
+func loadSchemeMap() (gofofa.SchemeMap, error) {
+	if len(schemeMapFile) == 0 {
+		return nil, nil
+	}
+	return gofofa.LoadSchemeMap(schemeMapFile)
+}
+
+// printCostEstimate estimate cost of queries and print to stdout
+func printCostEstimate(queries []string, fields []string, options gofofa.SearchOptions) error {
+	for _, query := range queries {
+		ce, err := fofaCli.EstimateCost(query, size, fields, options)
+		if err != nil {
+			return err
+		}
+		fmt.Println(ce)
+	}
+	return nil
+}
+
+// setBudget set spending limit of fofa client for the whole command
+func setBudget() {
+	if maxFCoin > 0 || maxRows > 0 || maxQueries > 0 {
+		fofaCli.SetBudget(gofofa.Budget{
+			MaxFCoin:   maxFCoin,
+			MaxRows:    maxRows,
+			MaxQueries: maxQueries,
+		})
+	}
+}
+
+// writeHostSearch search query with fields/size/full flags, write results to outFile or stdout
+func writeHostSearch(ctx context.Context, query string) error {
+	fields := strings.Split(fieldString, ",")
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+	writer, err := newOutWriter(outTo, fields)
+	if err != nil {
+		return err
+	}
+	_, err = stream.FromHostSearch(fofaCli, query, size, fields, gofofa.SearchOptions{
+		Full: full,
+	}).WriteTo(ctx, writer)
+	return closeOutWriter(writer, err)
+}
+
 // SearchAction search action
 func SearchAction(ctx *cli.Context) error {
 	// valid same config
@@ -162,6 +296,37 @@ func SearchAction(ctx *cli.Context) error {
 		return errors.New("fofa fields cannot be empty")
 	}
 
+	schemeMap, err := loadSchemeMap()
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		if query == "" {
+			return errors.New("fofa query cannot be empty in dry run mode")
+		}
+		return printCostEstimate([]string{query}, fields, gofofa.SearchOptions{
+			Full:     full,
+			UniqByIP: uniqByIP,
+		})
+	}
+
+	setBudget()
+
+	enricher, err := openEnricher()
+	if err != nil {
+		return err
+	}
+	if enricher != nil {
+		defer enricher.Close()
+		fields = enrichQueryFields(enricher, fields)
+	}
+	fields = probeQueryFields(fields)
+	var prober *probe.Prober
+	if probeEnabled {
+		prober = newProber(schemeMap)
+	}
+
 	// gen output
 	var outTo io.Writer
 	if len(outFile) > 0 {
@@ -177,82 +342,48 @@ func SearchAction(ctx *cli.Context) error {
 	}
 
 	// gen writer
-	var writer outformats.OutWriter
-	if hasBodyField(fields) && format == "csv" {
-		logrus.Warnln("fields contains body, so change format to json")
-		writer = outformats.NewJSONWriter(outTo, fields)
-	} else {
-		switch format {
-		case "csv":
-			writer = outformats.NewCSVWriter(outTo)
-		case "json":
-			writer = outformats.NewJSONWriter(outTo, fields)
-		case "xml":
-			writer = outformats.NewXMLWriter(outTo, fields)
-		default:
-			return fmt.Errorf("unknown format: %s", format)
-		}
+	writer, err := newOutWriter(outTo, probeOutFields(prober, enrichOutFields(enricher, fields)))
+	if err != nil {
+		return err
 	}
 
-	writeQuery := func(query string) error {
-		log.Println("query fofa of:", query)
-		// do search
-		res, err := fofaCli.HostSearch(query, size, fields, gofofa.SearchOptions{
-			FixUrl:    fixUrl,
-			UrlPrefix: urlPrefix,
-			Full:      full,
-			UniqByIP:  uniqByIP,
-		})
-		if err != nil {
-			return err
-		}
-
-		// output
-		if err = writer.WriteAll(res); err != nil {
+	var assetStore *store.Store
+	if len(storeFile) > 0 {
+		if assetStore, err = openStore(""); err != nil {
 			return err
 		}
+		defer assetStore.Close()
+	}
 
-		return nil
+	options := gofofa.SearchOptions{
+		FixUrl:    fixUrl,
+		UrlPrefix: urlPrefix,
+		SchemeMap: schemeMap,
+		Full:      full,
+		UniqByIP:  uniqByIP,
 	}
 
 	if query != "" {
-		return writeQuery(query)
-	} else {
-		// 并发模式
-		wg := sync.WaitGroup{}
-		queries := make(chan string, workers)
-		limiter := rate.NewLimiter(rate.Limit(ratePerSecond), 5)
-
-		worker := func(queries <-chan string, wg *sync.WaitGroup) {
-			for q := range queries {
-				tmpQuery := strings.ReplaceAll(template, "{}",
-					strconv.Quote(q))
-				if err := limiter.Wait(context.Background()); err != nil {
-					fmt.Println("Error: ", err)
-				}
-				if err := writeQuery(tmpQuery); err != nil {
-					log.Println(err)
-				}
-				wg.Done()
-			}
-		}
-		for w := 0; w < workers; w++ {
-			go worker(queries, &wg)
-		}
-
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() { // internally, it advances token based on sperator
-			line := scanner.Text()
-			wg.Add(1)
-			queries <- line
-		}
-
-		if err := scanner.Err(); err != nil {
-			log.Println(err)
-		}
-
-		wg.Wait()
+		log.Println("query fofa of:", query)
+		// 超出预算时输出已经取到的数据
+		s := teeStore(stream.FromHostSearch(fofaCli, query, size, fields, options), assetStore, query)
+		_, err = probeStream(prober, enrichStream(enricher, s)).WriteTo(ctx.Context, writer)
+		return closeOutWriter(writer, err)
 	}
 
-	return nil
+	// 并发模式
+	s := stream.FromLines(os.Stdin, "query").
+		Throttle(rate.Limit(ratePerSecond), 5).
+		Parallel(workers, fields, func(c context.Context, row []string) ([][]string, error) {
+			query := applyTemplate(template, row[0])
+			log.Println("query fofa of:", query)
+			res, err := teeStore(stream.FromHostSearch(fofaCli, query, size, fields, options), assetStore, query).
+				Collect(c)
+			if err != nil {
+				log.Println(err)
+			}
+			return res, nil
+		})
+	_, err = probeStream(prober, enrichStream(enricher, s)).WriteTo(ctx.Context, writer)
+	return closeOutWriter(writer, err)
 }
@@ -1,14 +1,24 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+var (
+	withDistinct bool
+)
+
 // stats subcommand
 var statsCmd = &cli.Command{
 	Name:                   "stats",
@@ -29,6 +39,30 @@ var statsCmd = &cli.Command{
 			Usage:       "aggs size",
 			Destination: &size,
 		},
+		&cli.BoolFlag{
+			Name:        "distinct",
+			Value:       false,
+			Usage:       "print distinct counts, such as ip and domain",
+			Destination: &withDistinct,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "text",
+			Usage:       "can be text/json/csv",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+		&cli.BoolFlag{
+			Name:        "full",
+			Value:       false,
+			Usage:       "search result for over a year",
+			Destination: &full,
+		},
 	},
 	Action: statsAction,
 }
@@ -45,20 +79,91 @@ func statsAction(ctx *cli.Context) error {
 		return errors.New("fofa fields cannot be empty")
 	}
 
+	// gen output
+	outTo := os.Stdout
+	if len(outFile) > 0 {
+		var f *os.File
+		var err error
+		if f, err = os.Create(outFile); err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	}
+
 	// do search
-	res, err := fofaCli.Stats(query, size, fields)
+	res, err := fofaCli.StatsDetail(query, size, fields, gofofa.SearchOptions{
+		Full: full,
+	})
 	if err != nil {
 		return err
 	}
+	if !withDistinct {
+		res.Distinct = nil
+	}
 
-	for _, obj := range res {
-		color.New(color.FgBlue).Fprintln(os.Stdout, "=== ", obj.Name)
+	switch format {
+	case "text":
+		printStats(outTo, res)
+	case "json":
+		encoder := json.NewEncoder(outTo)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(res)
+	case "csv":
+		return outformats.NewCSVWriter(outTo).WriteAll(statsRecords(res))
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	return nil
+}
+
+// sortedDistinct distinct names in order
+func sortedDistinct(distinct map[string]int) []string {
+	var names []string
+	for name := range distinct {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// statsRecords stats to csv records: field,name,count
+// distinct counts use distinct as field
+func statsRecords(res gofofa.StatsResult) [][]string {
+	records := [][]string{{"field", "name", "count"}}
+	for _, obj := range res.Aggs {
 		for _, item := range obj.Items {
-			color.New(color.FgHiGreen).Fprint(os.Stdout, item.Name)
-			fmt.Print("\t")
-			color.New(color.FgHiYellow).Fprintln(os.Stdout, item.Count)
+			records = append(records, []string{obj.Name, item.Name, strconv.Itoa(item.Count)})
 		}
 	}
+	for _, name := range sortedDistinct(res.Distinct) {
+		records = append(records, []string{"distinct", name, strconv.Itoa(res.Distinct[name])})
+	}
+	return records
+}
 
-	return nil
+func printStats(w io.Writer, res gofofa.StatsResult) {
+	for _, obj := range res.Aggs {
+		color.New(color.FgBlue).Fprintln(w, "=== ", obj.Name)
+		for _, item := range obj.Items {
+			color.New(color.FgHiGreen).Fprint(w, item.Name)
+			fmt.Fprint(w, "\t")
+			color.New(color.FgHiYellow).Fprintln(w, item.Count)
+		}
+	}
+
+	if len(res.Distinct) > 0 {
+		color.New(color.FgBlue).Fprintln(w, "=== ", "distinct")
+		for _, name := range sortedDistinct(res.Distinct) {
+			color.New(color.FgHiGreen).Fprint(w, name)
+			fmt.Fprint(w, "\t")
+			color.New(color.FgHiYellow).Fprintln(w, res.Distinct[name])
+		}
+	}
+
+	if !res.LastUpdateTime.IsZero() {
+		color.New(color.FgBlue).Fprintln(w, "=== ", "lastupdatetime")
+		fmt.Fprintln(w, res.LastUpdateTime)
+	}
 }
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa/pkg/store"
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	storeFile   string          // store db file
+	storeKeys   string          // key fields of asset when store is created
+	storeQuery  string          // source query of imported rows
+	storeTags   cli.StringSlice // filter of tags
+	storeSource string          // filter of source query
+	storeSince  string          // filter of last seen
+	storeWhere  cli.StringSlice // filter of field=value
+)
+
+var storeDBFlag = &cli.StringFlag{
+	Name:        "db",
+	Value:       "fofa_assets.db",
+	Usage:       "local asset store file",
+	Destination: &storeFile,
+}
+
+// store subcommand
+var storeCmd = &cli.Command{
+	Name:  "store",
+	Usage: "local asset inventory, upsert rows of search/dump, track first/last seen, tags and notes",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "import",
+			Usage:     "import json file written by search/dump --format json",
+			UsageText: "fofa store import [options] results.json",
+			Flags: []cli.Flag{
+				storeDBFlag,
+				&cli.StringFlag{
+					Name:        "key",
+					Aliases:     []string{"k"},
+					Usage:       "key fields of asset when store is created, default is ip,port",
+					Destination: &storeKeys,
+				},
+				&cli.StringFlag{
+					Name:        "query",
+					Aliases:     []string{"q"},
+					Usage:       "source query of rows",
+					Destination: &storeQuery,
+				},
+			},
+			Action: storeImportAction,
+		},
+		{
+			Name:      "query",
+			Usage:     "query assets in store",
+			UsageText: "fofa store query [options]",
+			Flags: []cli.Flag{
+				storeDBFlag,
+				&cli.StringSliceFlag{
+					Name:        "tag",
+					Aliases:     []string{"t"},
+					Usage:       "assets with the tag, can be repeated",
+					Destination: &storeTags,
+				},
+				&cli.StringFlag{
+					Name:        "source",
+					Usage:       "assets whose source query contains it",
+					Destination: &storeSource,
+				},
+				&cli.StringFlag{
+					Name:        "since",
+					Usage:       "assets last seen since, can be duration like 24h or date like 2022-01-01",
+					Destination: &storeSince,
+				},
+				&cli.StringSliceFlag{
+					Name:        "where",
+					Aliases:     []string{"w"},
+					Usage:       "assets whose field equals value, like port=80, can be repeated",
+					Destination: &storeWhere,
+				},
+				&cli.StringFlag{
+					Name:        "fields",
+					Aliases:     []string{"f"},
+					Value:       "key,first_seen,last_seen,tags,note",
+					Usage:       "asset fields, and key/first_seen/last_seen/queries/tags/note",
+					Destination: &fieldString,
+				},
+				&cli.StringFlag{
+					Name:        "format",
+					Value:       "csv",
+					Usage:       "can be csv/json/xml",
+					Destination: &format,
+				},
+				&cli.StringFlag{
+					Name:        "outFile",
+					Aliases:     []string{"o"},
+					Usage:       "if not set, wirte to stdout",
+					Destination: &outFile,
+				},
+			},
+			Action: storeQueryAction,
+		},
+		{
+			Name:      "tag",
+			Usage:     "add tags to asset",
+			UsageText: "fofa store tag [options] <key> <tag>...",
+			Flags:     []cli.Flag{storeDBFlag},
+			Action: storeKeyAction(func(s *store.Store, key string, args []string) error {
+				return s.Tag(key, args...)
+			}),
+		},
+		{
+			Name:      "untag",
+			Usage:     "remove tags from asset",
+			UsageText: "fofa store untag [options] <key> <tag>...",
+			Flags:     []cli.Flag{storeDBFlag},
+			Action: storeKeyAction(func(s *store.Store, key string, args []string) error {
+				return s.Untag(key, args...)
+			}),
+		},
+		{
+			Name:      "note",
+			Usage:     "set note of asset, empty note to clear",
+			UsageText: "fofa store note [options] <key> [note]",
+			Flags:     []cli.Flag{storeDBFlag},
+			Action: storeKeyAction(func(s *store.Store, key string, args []string) error {
+				return s.SetNote(key, strings.Join(args, " "))
+			}),
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete asset",
+			UsageText: "fofa store delete [options] <key>",
+			Flags:     []cli.Flag{storeDBFlag},
+			Action: storeKeyAction(func(s *store.Store, key string, args []string) error {
+				return s.Delete(key)
+			}),
+		},
+	},
+}
+
+// openStore open store of --db, keys is used when store is created
+func openStore(keys string) (*store.Store, error) {
+	var keyFields []string
+	if len(keys) > 0 {
+		keyFields = strings.Split(keys, ",")
+	}
+	s, err := store.Open(storeFile, keyFields)
+	if err != nil {
+		return nil, fmt.Errorf("open store %s failed: %w", storeFile, err)
+	}
+	return s, nil
+}
+
+// storeRows upsert rows of query into store, nil store is ignored
+func storeRows(s *store.Store, query string, fields []string, rows [][]string) error {
+	if s == nil || len(rows) == 0 {
+		return nil
+	}
+	added, updated, err := s.Upsert(query, fields, rows)
+	if err != nil {
+		return fmt.Errorf("store rows failed: %w", err)
+	}
+	log.Printf("store: %d added, %d updated", added, updated)
+	return nil
+}
+
+// storeWriter outformats.OutWriter which upserts rows of query into store
+type storeWriter struct {
+	store  *store.Store
+	query  string
+	fields []string
+}
+
+// WriteAll upsert rows
+func (w *storeWriter) WriteAll(rows [][]string) error {
+	return storeRows(w.store, w.query, w.fields, rows)
+}
+
+// teeStore upsert rows of stream into store, nil store is ignored
+func teeStore(s *stream.Stream, assetStore *store.Store, query string) *stream.Stream {
+	if assetStore == nil {
+		return s
+	}
+	return s.Tee(&storeWriter{store: assetStore, query: query, fields: s.Fields()})
+}
+
+// storeKeyAction action of subcommands with asset key as first arg
+func storeKeyAction(f func(s *store.Store, key string, args []string) error) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		key := ctx.Args().First()
+		if len(key) == 0 {
+			return errors.New("asset key cannot be empty")
+		}
+		s, err := openStore("")
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		return f(s, key, ctx.Args().Tail())
+	}
+}
+
+// storeImportAction import json records file into store
+func storeImportAction(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return errors.New("need json file to import")
+	}
+
+	assetStore, err := openStore(storeKeys)
+	if err != nil {
+		return err
+	}
+	defer assetStore.Close()
+
+	for _, filename := range ctx.Args().Slice() {
+		s, err := stream.FromJSONFile(filename, nil)
+		if err != nil {
+			return err
+		}
+		err = teeStore(s, assetStore, storeQuery).Run(ctx.Context, func(rows [][]string) error {
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("import %s failed: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// parseSince parse duration like 24h or date like 2022-01-01
+func parseSince(since string) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid since: %s", since)
+}
+
+// storeQueryAction query assets and export with outformats writers
+func storeQueryAction(ctx *cli.Context) error {
+	filter := store.Filter{
+		Tags:   storeTags.Value(),
+		Source: storeSource,
+	}
+	if len(storeSince) > 0 {
+		since, err := parseSince(storeSince)
+		if err != nil {
+			return err
+		}
+		filter.Since = since
+	}
+	for _, w := range storeWhere.Value() {
+		k, v, ok := strings.Cut(w, "=")
+		if !ok {
+			return fmt.Errorf("invalid where: %s, should be field=value", w)
+		}
+		if filter.Where == nil {
+			filter.Where = make(map[string]string)
+		}
+		filter.Where[k] = v
+	}
+
+	fields := strings.Split(fieldString, ",")
+
+	s, err := openStore("")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	assets, err := s.Query(filter)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d assets", len(assets))
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		var f *os.File
+		if f, err = os.Create(outFile); err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+
+	writer, err := newOutWriter(outTo, fields)
+	if err != nil {
+		return err
+	}
+	return closeOutWriter(writer, writer.WriteAll(store.Records(assets, fields)))
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	subdomainsDump bool // fetch with dump api
+	subdomainsTree bool // print as tree
+)
+
+// subdomainFields columns of flat list
+var subdomainFields = []string{"name", "wildcard", "count", "last_seen", "sources"}
+
+// subdomains subcommand
+var subdomainsCmd = &cli.Command{
+	Name:                   "subdomains",
+	Usage:                  "enumerate full hostnames under a domain from host, domain and certs_domains",
+	UsageText:              "fofa subdomains [options] <domain>",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:        "size",
+			Aliases:     []string{"s"},
+			Value:       1000,
+			Usage:       "rows to fetch, -1 means all with --dump",
+			Destination: &size,
+		},
+		&cli.BoolFlag{
+			Name:        "full",
+			Usage:       "search result for over a year",
+			Destination: &full,
+		},
+		&cli.BoolFlag{
+			Name:        "dump",
+			Usage:       "fetch with dump api, for large results",
+			Destination: &subdomainsDump,
+		},
+		&cli.IntFlag{
+			Name:        "batchSize",
+			Aliases:     []string{"bs"},
+			Value:       1000,
+			Usage:       "the amount of data contained in each batch of --dump",
+			Destination: &batchSize,
+		},
+		&cli.BoolFlag{
+			Name:        "tree",
+			Usage:       "print as tree of labels instead of flat list",
+			Destination: &subdomainsTree,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "format of flat list, can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "if not set, wirte to stdout",
+			Destination: &outFile,
+		},
+	},
+	Action: subdomainsAction,
+}
+
+// subdomainParent nearest parent of name in names, domain if there is none
+func subdomainParent(name string, domain string, names map[string]bool) string {
+	for {
+		i := strings.Index(name, ".")
+		if i < 0 {
+			return domain
+		}
+		name = name[i+1:]
+		if name == domain || names[name] {
+			return name
+		}
+	}
+}
+
+// writeSubdomainTree write names as tree, every name is under its nearest found parent
+func writeSubdomainTree(w io.Writer, domain string, subdomains []gofofa.Subdomain) {
+	names := make(map[string]bool)
+	for _, s := range subdomains {
+		names[s.Name] = true
+	}
+	children := make(map[string][]gofofa.Subdomain)
+	line := domain
+	for _, s := range subdomains {
+		if s.Name == domain {
+			line = fmt.Sprintf("%s\t%d\t%s", s.Name, s.Count, s.LastSeen)
+			continue
+		}
+		parent := subdomainParent(s.Name, domain, names)
+		children[parent] = append(children[parent], s)
+	}
+
+	fmt.Fprintln(w, line)
+	var walk func(parent string, prefix string)
+	walk = func(parent string, prefix string) {
+		for i, s := range children[parent] {
+			branch, indent := "├── ", "│   "
+			if i == len(children[parent])-1 {
+				branch, indent = "└── ", "    "
+			}
+			fmt.Fprintf(w, "%s%s%s\t%d\t%s\n", prefix, branch, s.Name, s.Count, s.LastSeen)
+			walk(s.Name, prefix+indent)
+		}
+	}
+	walk(domain, "")
+}
+
+// subdomainsAction subdomains action
+func subdomainsAction(ctx *cli.Context) error {
+	domain := strings.ToLower(strings.TrimSpace(ctx.Args().First()))
+	if len(domain) == 0 {
+		return errors.New("domain cannot be empty")
+	}
+	if ctx.Args().Len() > 1 {
+		return errors.New(fmt.Sprintln("there is args after domain:", ctx.Args().Get(1)))
+	}
+
+	subdomains, err := fofaCli.Subdomains(ctx.Context, domain, gofofa.SubdomainsOptions{
+		Size:      size,
+		Dump:      subdomainsDump,
+		BatchSize: batchSize,
+		Full:      full,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("%d names found under %s", len(subdomains), domain)
+
+	// gen output
+	var outTo io.Writer
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return fmt.Errorf("create outFile %s failed: %w", outFile, err)
+		}
+		outTo = f
+		defer f.Close()
+	} else {
+		outTo = os.Stdout
+	}
+
+	if subdomainsTree {
+		writeSubdomainTree(outTo, domain, subdomains)
+		return nil
+	}
+	writer, err := newOutWriter(outTo, subdomainFields)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(subdomains))
+	for _, s := range subdomains {
+		rows = append(rows, []string{s.Name, strconv.FormatBool(s.Wildcard), strconv.Itoa(s.Count),
+			s.LastSeen, strings.Join(s.Sources, " ")})
+	}
+	return closeOutWriter(writer, writer.WriteAll(rows))
+}
@@ -0,0 +1,451 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	watchQueriesFile string        // queries file, line by line
+	watchInterval    time.Duration // interval of rerun
+	watchOnce        bool          // run only one round
+	watchAfter       bool          // restrict query with after= last run
+	watchStateFile   string        // state file of seen rows
+	watchKeys        string        // key fields of row
+	watchWebhook     string        // webhook url
+	watchExec        string        // command for each new row
+	watchSkipFirst   bool          // don't emit rows of first run
+)
+
+// watch subcommand
+var watchCmd = &cli.Command{
+	Name:                   "watch",
+	Usage:                  "periodically rerun queries and emit only new rows",
+	UsageText:              "fofa watch [options] [query]",
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "queries",
+			Aliases:     []string{"inFile", "i"},
+			Usage:       "queries file, line by line",
+			Destination: &watchQueriesFile,
+		},
+		&cli.StringFlag{
+			Name:        "template",
+			Usage:       "query template of each line in queries file, like 'port=8443 && ip={}', not set means line is query",
+			Destination: &template,
+		},
+		&cli.DurationFlag{
+			Name:        "interval",
+			Value:       time.Hour,
+			Usage:       "interval between two rounds",
+			Destination: &watchInterval,
+		},
+		&cli.BoolFlag{
+			Name:        "once",
+			Usage:       "run only one round, useful in cron",
+			Destination: &watchOnce,
+		},
+		&cli.BoolFlag{
+			Name:        "after",
+			Usage:       "restrict query with after= date of last run",
+			Destination: &watchAfter,
+		},
+		&cli.StringFlag{
+			Name:        "state",
+			Value:       "fofa_watch_state.json",
+			Usage:       "state file of seen rows, survive restarts",
+			Destination: &watchStateFile,
+		},
+		&cli.StringFlag{
+			Name:        "key",
+			Aliases:     []string{"k"},
+			Value:       "ip,port",
+			Usage:       "key fields to identify a row",
+			Destination: &watchKeys,
+		},
+		&cli.BoolFlag{
+			Name:        "skipFirst",
+			Usage:       "record rows of first run of a query without emitting",
+			Destination: &watchSkipFirst,
+		},
+		&cli.StringFlag{
+			Name:        "fields",
+			Aliases:     []string{"f"},
+			Value:       "ip,port,host,title",
+			Usage:       "visit fofa website for more info",
+			Destination: &fieldString,
+		},
+		&cli.IntFlag{
+			Name:        "size",
+			Aliases:     []string{"s"},
+			Value:       100,
+			Usage:       "size of each query",
+			Destination: &size,
+		},
+		&cli.BoolFlag{
+			Name:        "full",
+			Value:       false,
+			Usage:       "search result for over a year",
+			Destination: &full,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "csv",
+			Usage:       "can be csv/json/xml",
+			Destination: &format,
+		},
+		&cli.StringFlag{
+			Name:        "outFile",
+			Aliases:     []string{"o"},
+			Usage:       "append new rows to file",
+			Destination: &outFile,
+		},
+		&cli.StringFlag{
+			Name:        "webhook",
+			Usage:       "post each new row as json to the url",
+			Destination: &watchWebhook,
+		},
+		&cli.StringFlag{
+			Name:        "exec",
+			Usage:       "run command for each new row, row json is written to stdin",
+			Destination: &watchExec,
+		},
+	},
+	Action: watchAction,
+}
+
+// watchRetry new row whose webhook or exec failed, delivered again next run
+type watchRetry struct {
+	Record  map[string]string `json:"record"`
+	Webhook bool              `json:"webhook,omitempty"` // webhook is not delivered yet
+	Exec    bool              `json:"exec,omitempty"`    // exec is not delivered yet
+}
+
+// watchQueryState state of one query
+type watchQueryState struct {
+	LastRun time.Time              `json:"last_run"`
+	Seen    map[string]int64       `json:"seen"`            // key => first seen unix time
+	Retry   map[string]*watchRetry `json:"retry,omitempty"` // key => row to deliver again
+}
+
+// watchState state of all queries
+type watchState struct {
+	Queries map[string]*watchQueryState `json:"queries"`
+}
+
+// loadWatchState load state file, empty state if not exists
+func loadWatchState(filename string) (*watchState, error) {
+	state := &watchState{Queries: make(map[string]*watchQueryState)}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parse state file %s failed: %w", filename, err)
+	}
+	if state.Queries == nil {
+		state.Queries = make(map[string]*watchQueryState)
+	}
+	return state, nil
+}
+
+// save write state file atomically
+func (s *watchState) save(filename string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmpFile := filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err = os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// readQueries read queries from args and queries file
+func readQueries(ctx *cli.Context) ([]string, error) {
+	var queries []string
+	if query := ctx.Args().First(); len(query) > 0 {
+		queries = append(queries, query)
+	}
+	if len(watchQueriesFile) > 0 {
+		f, err := os.Open(watchQueriesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if len(template) > 0 {
+				line = applyTemplate(template, line)
+			}
+			queries = append(queries, line)
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(queries) == 0 {
+		return nil, errors.New("fofa query cannot be empty, use args or --queries")
+	}
+	return queries, nil
+}
+
+// watchEmitter send new rows to sinks
+type watchEmitter struct {
+	fields     []string
+	writer     outformats.OutWriter // stdout or file
+	webhook    string
+	command    []string
+	httpClient *http.Client
+}
+
+// newRetry pending delivery of row to webhook and command
+func (e *watchEmitter) newRetry(query string, row []string) *watchRetry {
+	record := map[string]string{"query": query}
+	for i, f := range e.fields {
+		if i < len(row) {
+			record[f] = row[i]
+		}
+	}
+	return &watchRetry{
+		Record:  record,
+		Webhook: len(e.webhook) > 0,
+		Exec:    len(e.command) > 0,
+	}
+}
+
+// deliver post rows to webhook and pass them to command, delivered sinks are removed from retries,
+// returns count of rows failed
+func (e *watchEmitter) deliver(ctx context.Context, retries map[string]*watchRetry) int {
+	keys := make([]string, 0, len(retries))
+	for key := range retries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	failed := 0
+	for _, key := range keys {
+		r := retries[key]
+		data, _ := json.Marshal(r.Record)
+
+		// 没有配置的不再投递
+		r.Webhook = r.Webhook && len(e.webhook) > 0
+		r.Exec = r.Exec && len(e.command) > 0
+		if r.Webhook {
+			if err := e.post(ctx, data); err != nil {
+				log.Println("webhook failed:", err)
+			} else {
+				r.Webhook = false
+			}
+		}
+		if r.Exec {
+			cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+			cmd.Stdin = bytes.NewReader(data)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				log.Println("exec failed:", err)
+			} else {
+				r.Exec = false
+			}
+		}
+
+		if r.Webhook || r.Exec {
+			failed++
+		} else {
+			delete(retries, key)
+		}
+	}
+	return failed
+}
+
+func (e *watchEmitter) post(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.webhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook response status: %s", resp.Status)
+	}
+	return nil
+}
+
+// watchQuery run query once, emit new rows and update state
+// rows are seen after they are written, rows whose webhook or exec failed are kept in state and delivered again next run
+func watchQuery(ctx context.Context, query string, fields []string, keys []string,
+	qs *watchQueryState, emitter *watchEmitter) error {
+	now := time.Now()
+	firstRun := qs.LastRun.IsZero()
+
+	runQuery := query
+	if watchAfter && !firstRun {
+		runQuery = "(" + query + `) && after="` + qs.LastRun.Format("2006-01-02") + `"`
+	}
+	log.Println("watch query of:", runQuery)
+
+	total := 0
+	var newKeys []string
+	pending := make(map[string]bool)
+	newRows, err := stream.FromHostSearch(fofaCli, runQuery, size, fields, gofofa.SearchOptions{
+		Full: full,
+	}).Filter(func(row []string) bool {
+		total++
+		values := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if i := fieldIndex(fields, k); i >= 0 && i < len(row) {
+				values = append(values, row[i])
+			}
+		}
+		key := strings.Join(values, ",")
+		if _, ok := qs.Seen[key]; ok || pending[key] {
+			return false
+		}
+		pending[key] = true
+		newKeys = append(newKeys, key)
+		return true
+	}).Collect(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d rows, %d new", total, len(newRows))
+	emit := len(newRows) > 0 && !(firstRun && watchSkipFirst)
+	if emit && emitter.writer != nil {
+		if err = emitter.writer.WriteAll(newRows); err != nil {
+			return err
+		}
+	}
+
+	if qs.Retry == nil {
+		qs.Retry = make(map[string]*watchRetry)
+	}
+	for i, key := range newKeys {
+		qs.Seen[key] = now.Unix()
+		if emit && (len(emitter.webhook) > 0 || len(emitter.command) > 0) {
+			qs.Retry[key] = emitter.newRetry(query, newRows[i])
+		}
+	}
+	qs.LastRun = now
+
+	// 包括之前失败的
+	if failed := emitter.deliver(ctx, qs.Retry); failed > 0 {
+		log.Printf("%d rows failed to deliver, retry next run", failed)
+	}
+	return nil
+}
+
+// watchAction watch action
+func watchAction(ctx *cli.Context) error {
+	queries, err := readQueries(ctx)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Split(fieldString, ",")
+	keys := strings.Split(watchKeys, ",")
+	for _, k := range keys {
+		if !hashField(fields, k) {
+			fields = append(fields, k)
+		}
+	}
+
+	state, err := loadWatchState(watchStateFile)
+	if err != nil {
+		return err
+	}
+
+	// 持续追加输出，xml不能是单个文档
+	xmlStream = true
+	emitter := &watchEmitter{
+		fields:     fields,
+		webhook:    watchWebhook,
+		command:    strings.Fields(watchExec),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	if len(outFile) > 0 {
+		f, err := os.OpenFile(outFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("open outFile %s failed: %w", outFile, err)
+		}
+		defer f.Close()
+		if emitter.writer, err = newOutWriter(f, fields); err != nil {
+			return err
+		}
+	} else if len(emitter.webhook) == 0 && len(emitter.command) == 0 {
+		if emitter.writer, err = newOutWriter(os.Stdout, fields); err != nil {
+			return err
+		}
+	}
+
+	// 中断时保存状态退出
+	watchCtx, stop := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fofaCli.SetContext(watchCtx)
+
+	for {
+		for _, query := range queries {
+			qs, ok := state.Queries[query]
+			if !ok {
+				qs = &watchQueryState{Seen: make(map[string]int64)}
+				state.Queries[query] = qs
+			}
+
+			if err = watchQuery(watchCtx, query, fields, keys, qs, emitter); err != nil {
+				if watchCtx.Err() != nil {
+					return state.save(watchStateFile)
+				}
+				log.Println("watch query failed:", err)
+				continue
+			}
+			if err = state.save(watchStateFile); err != nil {
+				return err
+			}
+		}
+
+		if watchOnce {
+			return nil
+		}
+
+		select {
+		case <-watchCtx.Done():
+			return nil
+		case <-time.After(watchInterval):
+		}
+	}
+}
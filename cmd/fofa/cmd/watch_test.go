@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newWatchTestClient(t *testing.T) *gofofa.Client {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/info/my":
+			w.Write([]byte(`{"error":false,"email":"d@d.com","isvip":true,"vip_level":3}`))
+		case "/api/v1/search/all":
+			w.Write([]byte(`{"error":false,"size":2,"results":[["1.1.1.1","80"],["2.2.2.2","443"]]}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := gofofa.NewClient(gofofa.WithURL(ts.URL + "?email=d@d.com&key=44444&version=v1"))
+	assert.Nil(t, err)
+	return client
+}
+
+// failWriter writer always fails
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWatchState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	// 不存在时是空状态
+	state, err := loadWatchState(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(state.Queries))
+
+	lastRun := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	state.Queries["port=80"] = &watchQueryState{
+		LastRun: lastRun,
+		Seen:    map[string]int64{"1.1.1.1,80": 1641092645},
+		Retry: map[string]*watchRetry{
+			"1.1.1.1,80": {Record: map[string]string{"ip": "1.1.1.1", "port": "80"}, Webhook: true},
+		},
+	}
+	assert.Nil(t, state.save(filename))
+
+	loaded, err := loadWatchState(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(loaded.Queries))
+	assert.True(t, lastRun.Equal(loaded.Queries["port=80"].LastRun))
+	assert.Equal(t, state.Queries["port=80"].Seen, loaded.Queries["port=80"].Seen)
+	assert.Equal(t, state.Queries["port=80"].Retry, loaded.Queries["port=80"].Retry)
+}
+
+func TestWatchQuery(t *testing.T) {
+	fofaCli = newWatchTestClient(t)
+	size = 10
+	format = "csv"
+	watchAfter = false
+	watchSkipFirst = false
+	fields := []string{"ip", "port"}
+	keys := []string{"ip", "port"}
+	ctx := context.Background()
+
+	// 第一次webhook失败，之后成功
+	var lock sync.Mutex
+	var posted []string
+	fails := 1
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if fails > 0 {
+			fails--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var record map[string]string
+		json.NewDecoder(r.Body).Decode(&record)
+		posted = append(posted, record["ip"])
+	}))
+	defer hook.Close()
+
+	// 写失败，不记录
+	emitter := &watchEmitter{
+		fields:     fields,
+		writer:     outformats.NewCSVWriter(failWriter{}),
+		webhook:    hook.URL,
+		httpClient: hook.Client(),
+	}
+	qs := &watchQueryState{Seen: make(map[string]int64)}
+	err := watchQuery(ctx, "port=80", fields, keys, qs, emitter)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(qs.Seen))
+	assert.True(t, qs.LastRun.IsZero())
+	assert.Equal(t, 1, fails)
+
+	// 写成功就记录，webhook失败的下次重试
+	var buf bytes.Buffer
+	emitter.writer = outformats.NewCSVWriter(&buf)
+	err = watchQuery(ctx, "port=80", fields, keys, qs, emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1,80\n2.2.2.2,443\n", buf.String())
+	assert.Equal(t, []string{"2.2.2.2"}, posted)
+	assert.Equal(t, 2, len(qs.Seen))
+	assert.Equal(t, []string{"1.1.1.1,80"}, mapKeys(qs.Retry))
+	assert.False(t, qs.LastRun.IsZero())
+
+	// 不重复写，只重试失败的webhook
+	err = watchQuery(ctx, "port=80", fields, keys, qs, emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1,80\n2.2.2.2,443\n", buf.String())
+	assert.Equal(t, []string{"2.2.2.2", "1.1.1.1"}, posted)
+	assert.Equal(t, 0, len(qs.Retry))
+
+	// 都已经发出过
+	err = watchQuery(ctx, "port=80", fields, keys, qs, emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1,80\n2.2.2.2,443\n", buf.String())
+	assert.Equal(t, []string{"2.2.2.2", "1.1.1.1"}, posted)
+}
+
+func mapKeys(m map[string]*watchRetry) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+)
+
+var (
+	xmlStream bool   // one record element per line
+	xmlDTD    bool   // inline dtd
+	xmlXSD    string // xsd file written and referenced by document
+)
+
+// xmlFlags flags of xml format, shared by search, dump and random
+func xmlFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "xmlStream",
+			Aliases:     []string{"xml-stream"},
+			Usage:       "xml format writes one <result> element per line, without declaration and root",
+			Destination: &xmlStream,
+		},
+		&cli.BoolFlag{
+			Name:        "xmlDTD",
+			Aliases:     []string{"xml-dtd"},
+			Usage:       "xml document with inline dtd",
+			Destination: &xmlDTD,
+		},
+		&cli.StringFlag{
+			Name:        "xmlXSD",
+			Aliases:     []string{"xml-xsd"},
+			Usage:       "write xml schema to the file, and reference it in xml document",
+			Destination: &xmlXSD,
+		},
+	}
+}
+
+// newXMLWriter xml writer of --xmlStream/--xmlDTD/--xmlXSD, schema file is written if set
+func newXMLWriter(outTo io.Writer, fields []string) (outformats.OutWriter, error) {
+	options := outformats.XMLOptions{
+		Stream:         xmlStream,
+		DTD:            xmlDTD,
+		SchemaLocation: xmlXSD,
+	}
+	if len(xmlXSD) > 0 {
+		f, err := os.Create(xmlXSD)
+		if err != nil {
+			return nil, fmt.Errorf("create xsd file %s failed: %w", xmlXSD, err)
+		}
+		defer f.Close()
+		if err = outformats.WriteXSD(f, fields, options); err != nil {
+			return nil, err
+		}
+	}
+	return outformats.NewXMLWriter(outTo, fields, options), nil
+}
+
+// closeOutWriter finish output of writer, such as end tag of xml document, err is returned first
+func closeOutWriter(writer outformats.OutWriter, err error) error {
+	if closeErr := outformats.Close(writer); err == nil {
+		err = closeErr
+	}
+	return err
+}
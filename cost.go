@@ -0,0 +1,142 @@
+package gofofa
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// rowsPerFCoin rows can be fetched with one fcoin when over free limit
+	rowsPerFCoin = 10000
+	// rowsPerPage max rows of one api query
+	rowsPerPage = 1000
+)
+
+// CostEstimate quota cost of a search, generated without fetching any data
+type CostEstimate struct {
+	Query          string     `json:"query"`
+	Total          int        `json:"total"`            // matched count of query
+	Rows           int        `json:"rows"`             // rows would be fetched
+	FreeRows       int        `json:"free_rows"`        // rows in free limit, -1 means unknown vip level
+	FCoin          int        `json:"fcoin"`            // fcoin would be deducted
+	ApiData        int        `json:"api_data"`         // api data quota would be consumed, only for subscription
+	ApiQueries     int        `json:"api_queries"`      // api requests would be sent
+	DeductMode     DeductMode `json:"deduct_mode"`      // deduct mode of client
+	RemainFCoin    int        `json:"remain_fcoin"`     // fcoin of account
+	RemainApiData  int        `json:"remain_api_data"`  // available data amount of account
+	RemainApiQuery int        `json:"remain_api_query"` // available query of account
+	Insufficient   bool       `json:"insufficient"`     // account can not afford the search
+	Reason         string     `json:"reason,omitempty"` // why insufficient or truncated
+}
+
+func (ce CostEstimate) String() string {
+	d, _ := json.MarshalIndent(ce, "", "  ")
+	return string(d)
+}
+
+// isSubscription 订阅用户按 api 数据量扣除
+func (ai AccountInfo) isSubscription() bool {
+	switch ai.VIPLevel {
+	case VipLevelSubPersonal, VipLevelSubPro, VipLevelSubBuss:
+		return true
+	}
+	return false
+}
+
+// EstimateCost estimate how many rows the query will return and how much fcoin or api data it will consume,
+// under current DeductMode and vip level, no data is fetched except one count request
+// query fofa query string
+// size data size: -1 means all
+// fields of fofa host search
+// options for search
+func (c *Client) EstimateCost(query string, size int, fields []string, options ...SearchOptions) (ce CostEstimate, err error) {
+	var full bool
+	if len(options) > 0 {
+		full = options[0].Full
+	}
+
+	// 订阅用户通过 api 查询余额
+	account := c.Account
+	if account.isSubscription() {
+		if info, errInfo := c.AccountInfo(); errInfo == nil {
+			account = info
+		}
+	}
+
+	ce = CostEstimate{
+		Query:          query,
+		DeductMode:     c.DeductMode,
+		RemainFCoin:    account.FCoin,
+		RemainApiData:  account.RemainApiData,
+		RemainApiQuery: account.RemainApiQuery,
+	}
+
+	ce.Total, err = c.hostCount(query, fields, full)
+	if err != nil {
+		return
+	}
+
+	ce.Rows = ce.Total
+	if size >= 0 && size < ce.Total {
+		ce.Rows = size
+	}
+
+	if account.isSubscription() {
+		if account.RemainApiQuery > 0 {
+			ce.FreeRows = account.RemainApiData
+		}
+	} else {
+		ce.FreeRows = c.freeSize()
+	}
+
+	switch {
+	case ce.FreeRows == -1:
+		// unknown vip level, skip mode check
+	case ce.FreeRows == 0 && !account.isSubscription():
+		// 不是会员，全部扣F币
+		ce.FCoin = fcoinOfRows(ce.Rows)
+		if c.DeductMode != DeductModeFCoin {
+			ce.Insufficient = true
+			ce.Reason = "insufficient privileges, try to set mode to 1(DeductModeFCoin)"
+		} else if ce.FCoin > account.FCoin {
+			ce.Insufficient = true
+			ce.Reason = "insufficient fcoin"
+		}
+	case ce.Rows > ce.FreeRows:
+		// 超出免费额度
+		switch c.DeductMode {
+		case DeductModeFree:
+			ce.Rows = ce.FreeRows
+			ce.Reason = fmt.Sprintf("size is larger than your account free limit, just fetch %d instead", ce.FreeRows)
+		case DeductModeFCoin:
+			ce.FCoin = fcoinOfRows(ce.Rows - ce.FreeRows)
+			if ce.FCoin > account.FCoin {
+				ce.Insufficient = true
+				ce.Reason = "insufficient fcoin"
+			}
+		}
+	}
+
+	if account.isSubscription() {
+		ce.ApiData = ce.Rows
+		if ce.ApiData > ce.FreeRows {
+			ce.ApiData = ce.FreeRows
+		}
+	}
+
+	ce.ApiQueries = (ce.Rows + rowsPerPage - 1) / rowsPerPage
+	if ce.ApiQueries == 0 {
+		ce.ApiQueries = 1
+	}
+	if account.isSubscription() && ce.ApiQueries > ce.RemainApiQuery {
+		ce.Insufficient = true
+		ce.Reason = "insufficient api query"
+	}
+
+	return
+}
+
+// fcoinOfRows fcoin of rows over free limit
+func fcoinOfRows(rows int) int {
+	return (rows + rowsPerFCoin - 1) / rowsPerFCoin
+}
@@ -0,0 +1,104 @@
+package gofofa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_EstimateCost(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(queryHander))
+	defer ts.Close()
+
+	var cli *Client
+	var err error
+	var account accountInfo
+	var ce CostEstimate
+
+	// 注册用户，没有F币
+	account = validAccounts[0]
+	cli, err = NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ce, err = cli.EstimateCost("port=80", 10, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.Equal(t, 12345678, ce.Total)
+	assert.Equal(t, 10, ce.Rows)
+	assert.Equal(t, 1, ce.FCoin)
+	assert.True(t, ce.Insufficient)
+	assert.Contains(t, ce.Reason, "DeductModeFCoin")
+	cli.DeductMode = DeductModeFCoin
+	ce, err = cli.EstimateCost("port=80", 10, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.True(t, ce.Insufficient)
+	assert.Equal(t, "insufficient fcoin", ce.Reason)
+
+	// 注册用户，有F币
+	account = validAccounts[4]
+	cli, err = NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	cli.DeductMode = DeductModeFCoin
+	ce, err = cli.EstimateCost("port=80", 10, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.False(t, ce.Insufficient)
+	assert.Equal(t, 1, ce.FCoin)
+	assert.Equal(t, 10, ce.RemainFCoin)
+
+	// 普通会员，超出免费额度
+	account = validAccounts[1]
+	cli, err = NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ce, err = cli.EstimateCost("port=80", 10000, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, ce.FreeRows)
+	assert.Equal(t, 100, ce.Rows)
+	assert.Equal(t, 0, ce.FCoin)
+	assert.Equal(t, 1, ce.ApiQueries)
+	assert.False(t, ce.Insufficient)
+	assert.Contains(t, ce.Reason, "free limit")
+	cli.DeductMode = DeductModeFCoin
+	ce, err = cli.EstimateCost("port=80", 30100, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.Equal(t, 30100, ce.Rows)
+	assert.Equal(t, 3, ce.FCoin)
+	assert.Equal(t, 31, ce.ApiQueries)
+	assert.False(t, ce.Insufficient)
+
+	// 全部数据
+	ce, err = cli.EstimateCost("port=80", -1, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.Equal(t, 12345678, ce.Rows)
+	assert.True(t, ce.Insufficient)
+
+	// 订阅个人
+	account = validAccounts[5]
+	cli, err = NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ce, err = cli.EstimateCost("port=80", 1000, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, ce.FreeRows)
+	assert.Equal(t, 100, ce.Rows)
+	assert.Equal(t, 100, ce.ApiData)
+	assert.Equal(t, 10, ce.RemainApiQuery)
+	assert.False(t, ce.Insufficient)
+
+	// 未知等级
+	account = validAccounts[10]
+	cli, err = NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ce, err = cli.EstimateCost("port=80", 1000, []string{"ip", "port"})
+	assert.Nil(t, err)
+	assert.Equal(t, -1, ce.FreeRows)
+	assert.Equal(t, 1000, ce.Rows)
+	assert.Equal(t, 0, ce.FCoin)
+	assert.Contains(t, ce.String(), `"rows": 1000`)
+
+	// 没有权限的字段
+	_, err = cli.EstimateCost("port=80", 10, []string{"fid"})
+	assert.Contains(t, err.Error(), "没有权限搜索fid字段")
+
+	// 请求失败
+	cli.Server = "http://fofa.info:66666"
+	_, err = cli.EstimateCost("port=80", 10, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package gofofa
+
+import (
+	"sort"
+	"strings"
+)
+
+// AssetChange one asset changed between two snapshots
+type AssetChange struct {
+	Key    string            `json:"key"`
+	Fields []string          `json:"fields"` // changed fields
+	Old    map[string]string `json:"old"`
+	New    map[string]string `json:"new"`
+}
+
+// AssetDiff differences of two snapshots of query results
+type AssetDiff struct {
+	Keys    []string            `json:"keys"` // key fields of asset
+	Added   []map[string]string `json:"added"`
+	Removed []map[string]string `json:"removed"`
+	Changed []AssetChange       `json:"changed"`
+}
+
+// AssetKey key of record, values of key fields joined by comma
+func AssetKey(record map[string]string, keys []string) string {
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, record[k])
+	}
+	return strings.Join(values, ",")
+}
+
+// indexAssets records by key, first record wins if key duplicated
+func indexAssets(records []map[string]string, keys []string) (order []string, index map[string]map[string]string) {
+	index = make(map[string]map[string]string, len(records))
+	for _, record := range records {
+		key := AssetKey(record, keys)
+		if _, ok := index[key]; ok {
+			continue
+		}
+		index[key] = record
+		order = append(order, key)
+	}
+	return
+}
+
+// DiffAssets compare old and new snapshot of query results, assets are identified by key fields, such as ip,port
+// fields in ignore are not compared, such as lastupdatetime which changes every time
+// field only exists in one snapshot is not compared, so the snapshots can be fetched with different fields
+func DiffAssets(oldRecords, newRecords []map[string]string, keys []string, ignore []string) AssetDiff {
+	ignored := make(map[string]bool, len(ignore))
+	for _, f := range ignore {
+		ignored[f] = true
+	}
+
+	oldOrder, oldIndex := indexAssets(oldRecords, keys)
+	newOrder, newIndex := indexAssets(newRecords, keys)
+
+	diff := AssetDiff{Keys: keys}
+	for _, key := range newOrder {
+		newRecord := newIndex[key]
+		oldRecord, ok := oldIndex[key]
+		if !ok {
+			diff.Added = append(diff.Added, newRecord)
+			continue
+		}
+
+		var changed []string
+		for field, value := range newRecord {
+			if ignored[field] {
+				continue
+			}
+			if oldValue, ok := oldRecord[field]; ok && oldValue != value {
+				changed = append(changed, field)
+			}
+		}
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			diff.Changed = append(diff.Changed, AssetChange{
+				Key:    key,
+				Fields: changed,
+				Old:    oldRecord,
+				New:    newRecord,
+			})
+		}
+	}
+
+	for _, key := range oldOrder {
+		if _, ok := newIndex[key]; !ok {
+			diff.Removed = append(diff.Removed, oldIndex[key])
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,46 @@
+package gofofa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffAssets(t *testing.T) {
+	oldRecords := []map[string]string{
+		{"ip": "1.1.1.1", "port": "80", "title": "a", "lastupdatetime": "2022-01-01 00:00:00"},
+		{"ip": "1.1.1.1", "port": "443", "title": "b", "server": "nginx"},
+		{"ip": "2.2.2.2", "port": "80", "title": "c"},
+		{"ip": "2.2.2.2", "port": "80", "title": "duplicated"},
+	}
+	newRecords := []map[string]string{
+		{"ip": "1.1.1.1", "port": "80", "title": "a", "lastupdatetime": "2022-02-01 00:00:00"},
+		{"ip": "1.1.1.1", "port": "443", "title": "b2", "server": "apache", "product": "new field"},
+		{"ip": "3.3.3.3", "port": "22", "title": ""},
+	}
+
+	diff := DiffAssets(oldRecords, newRecords, []string{"ip", "port"}, []string{"lastupdatetime"})
+	assert.Equal(t, []string{"ip", "port"}, diff.Keys)
+	assert.Equal(t, 1, len(diff.Added))
+	assert.Equal(t, "3.3.3.3", diff.Added[0]["ip"])
+	assert.Equal(t, 1, len(diff.Removed))
+	assert.Equal(t, "c", diff.Removed[0]["title"])
+	assert.Equal(t, 1, len(diff.Changed))
+	assert.Equal(t, "1.1.1.1,443", diff.Changed[0].Key)
+	assert.Equal(t, []string{"server", "title"}, diff.Changed[0].Fields)
+	assert.Equal(t, "b", diff.Changed[0].Old["title"])
+	assert.Equal(t, "b2", diff.Changed[0].New["title"])
+
+	// 不忽略时间
+	diff = DiffAssets(oldRecords, newRecords, []string{"ip", "port"}, nil)
+	assert.Equal(t, 2, len(diff.Changed))
+	assert.Equal(t, []string{"lastupdatetime"}, diff.Changed[0].Fields)
+
+	// 相同
+	diff = DiffAssets(newRecords, newRecords, []string{"ip", "port"}, nil)
+	assert.Nil(t, diff.Added)
+	assert.Nil(t, diff.Removed)
+	assert.Nil(t, diff.Changed)
+
+	assert.Equal(t, "1.1.1.1,80", AssetKey(oldRecords[0], []string{"ip", "port"}))
+	assert.Equal(t, ",", AssetKey(oldRecords[0], []string{"host", "domain"}))
+}
@@ -0,0 +1,273 @@
+package gofofa
+
+import (
+	"context"
+	"errors"
+	"github.com/weppos/publicsuffix-go/publicsuffix"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// pivots of domain expansion
+const (
+	PivotSeed         = "seed"          // input domain
+	PivotCertsDomains = "certs_domains" // domains of certificates of hosts of the domain
+	PivotCertOrg      = "cert_org"      // same certificate subject organization
+	PivotIconHash     = "icon_hash"     // same icon hash
+	PivotIP           = "ip"            // same ip
+)
+
+// DefaultDomainPivots pivots used when ExpandDomainsOptions.Pivots is empty
+var DefaultDomainPivots = []string{PivotCertsDomains, PivotCertOrg, PivotIconHash, PivotIP}
+
+// domainPivotFields field of domain="x" results read by each pivot, and field of pivot query
+var domainPivotFields = map[string][2]string{
+	PivotCertsDomains: {"certs_domains", ""},
+	PivotCertOrg:      {"certs_subject_org", "cert.subject.org"},
+	PivotIconHash:     {"icon_hash", "icon_hash"},
+	PivotIP:           {"ip", "ip"},
+}
+
+// ExpandDomainsOptions options of ExpandDomains
+type ExpandDomainsOptions struct {
+	Depth      int      // rounds of pivoting, 1 means only pivots of seeds, default is 1
+	Size       int      // size of each query, default is 100
+	Pivots     []string // default is DefaultDomainPivots
+	MaxDomains int      // stop when so many domains are found including seeds, 0 means no limit
+	MaxQueries int      // stop when so many queries are sent, 0 means no limit
+	Full       bool     // search result for over a year
+	UniqByIP   bool     // group by ip
+}
+
+// ExpandedDomain domain found by ExpandDomains, with how it is found
+type ExpandedDomain struct {
+	Domain     string `json:"domain"`
+	Depth      int    `json:"depth"`      // 0 for seeds
+	Count      int    `json:"count"`      // rows containing the domain
+	Seed       string `json:"seed"`       // seed which the domain is expanded from
+	Pivot      string `json:"pivot"`      // pivot which found the domain first
+	Value      string `json:"value"`      // value of pivot, such as org, icon hash or ip, source domain of certs_domains
+	From       string `json:"from"`       // domain whose pivot found it
+	Provenance string `json:"provenance"` // chain from seed, like baidu.com > icon_hash="-1" > dwz.cn
+}
+
+// DomainExpansion result of ExpandDomains
+type DomainExpansion struct {
+	Domains []ExpandedDomain `json:"domains"` // in order of discovery, seeds first
+	Queries int              `json:"queries"` // queries sent
+	Stopped string           `json:"stopped"` // why expansion stopped early: max domains, max queries or budget, empty if finished
+}
+
+// errExpandStopped stop condition of expansion reached
+var errExpandStopped = errors.New("expansion stopped")
+
+// registrableDomain registrable domain of hostname, such as example.com of a.example.com, wildcard is allowed
+func registrableDomain(hostname string) string {
+	hostname = strings.TrimSpace(strings.ReplaceAll(hostname, "*", "www"))
+	if len(hostname) == 0 || net.ParseIP(strings.Trim(hostname, "[]")) != nil {
+		return ""
+	}
+	domain, err := publicsuffix.Domain(strings.ToLower(hostname))
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// domainExpander state of ExpandDomains
+type domainExpander struct {
+	c       *Client
+	ctx     context.Context
+	options ExpandDomainsOptions
+	res     DomainExpansion
+	index   map[string]int  // domain to index of res.Domains
+	queried map[string]bool // pivot queries already sent
+}
+
+// search send query unless stop condition is reached
+func (e *domainExpander) search(query string, fields []string) ([][]string, error) {
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if e.options.MaxQueries > 0 && e.res.Queries >= e.options.MaxQueries {
+		e.res.Stopped = "max queries"
+		return nil, errExpandStopped
+	}
+
+	e.res.Queries++
+	e.c.logger.Debugln("expand domains:", query)
+	res, err := e.c.HostSearch(query, e.options.Size, fields, SearchOptions{
+		Full:     e.options.Full,
+		UniqByIP: e.options.UniqByIP,
+	})
+	if errors.Is(err, ErrBudgetExceeded) {
+		e.res.Stopped = "budget"
+		return nil, errExpandStopped
+	}
+	return res, err
+}
+
+// found record domain found by pivot of from, returns index of new domain, -1 if already found
+func (e *domainExpander) found(domain string, from int, pivot string, value string, step string) (int, error) {
+	if i, ok := e.index[domain]; ok {
+		e.res.Domains[i].Count++
+		return -1, nil
+	}
+	if e.options.MaxDomains > 0 && len(e.res.Domains) >= e.options.MaxDomains {
+		e.res.Stopped = "max domains"
+		return -1, errExpandStopped
+	}
+
+	d := ExpandedDomain{
+		Domain:     domain,
+		Count:      1,
+		Seed:       domain,
+		Pivot:      pivot,
+		Value:      value,
+		Provenance: domain,
+	}
+	if from >= 0 {
+		parent := e.res.Domains[from]
+		d.Depth = parent.Depth + 1
+		d.Seed = parent.Seed
+		d.From = parent.Domain
+		d.Provenance = parent.Provenance + " > " + step + " > " + domain
+	}
+	e.index[domain] = len(e.res.Domains)
+	e.res.Domains = append(e.res.Domains, d)
+	return len(e.res.Domains) - 1, nil
+}
+
+// expand pivot one domain, returns indexes of new domains
+func (e *domainExpander) expand(from int) (next []int, err error) {
+	domain := e.res.Domains[from].Domain
+	add := func(names []string, pivot string, value string, step string) error {
+		// 一行里的域名只计数一次
+		seen := make(map[string]bool)
+		for _, name := range names {
+			d := registrableDomain(name)
+			if len(d) == 0 || seen[d] {
+				continue
+			}
+			seen[d] = true
+			i, err := e.found(d, from, pivot, value, step)
+			if err != nil {
+				return err
+			}
+			if i >= 0 {
+				next = append(next, i)
+			}
+		}
+		return nil
+	}
+
+	var fields []string
+	for _, pivot := range e.options.Pivots {
+		fields = append(fields, domainPivotFields[pivot][0])
+	}
+	rows, err := e.search(`domain=`+strconv.Quote(domain), fields)
+	if err != nil {
+		return next, err
+	}
+
+	// 收集各个pivot的值，按出现顺序
+	var values [][2]string
+	seen := make(map[[2]string]bool)
+	for _, row := range rows {
+		for i, pivot := range e.options.Pivots {
+			if pivot == PivotCertsDomains {
+				if err = add(strings.Split(row[i], ","), pivot, domain, PivotCertsDomains); err != nil {
+					return next, err
+				}
+				continue
+			}
+			v := [2]string{pivot, strings.TrimSpace(row[i])}
+			if len(v[1]) > 0 && !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+
+	for _, v := range values {
+		query := domainPivotFields[v[0]][1] + "=" + strconv.Quote(v[1])
+		if e.queried[query] {
+			continue
+		}
+		e.queried[query] = true
+		rows, err = e.search(query, []string{"domain"})
+		if err != nil {
+			return next, err
+		}
+		for _, row := range rows {
+			if err = add(row[:1], v[0], v[1], query); err != nil {
+				return next, err
+			}
+		}
+	}
+	return next, nil
+}
+
+// ExpandDomains find related domains of seeds recursively
+// each round queries domain="x" of new domains, then pivots through certs_domains,
+// certificate subject org, icon hash and ip of the results, found domains are expanded in next round
+// partial results are returned with nil error when MaxDomains, MaxQueries or client budget is reached
+func (c *Client) ExpandDomains(ctx context.Context, seeds []string, options ExpandDomainsOptions) (DomainExpansion, error) {
+	if options.Depth < 1 {
+		options.Depth = 1
+	}
+	if options.Size < 1 {
+		options.Size = 100
+	}
+	if len(options.Pivots) == 0 {
+		options.Pivots = DefaultDomainPivots
+	}
+	for _, pivot := range options.Pivots {
+		if _, ok := domainPivotFields[pivot]; !ok {
+			return DomainExpansion{}, errors.New("unknown pivot: " + pivot)
+		}
+	}
+
+	e := &domainExpander{
+		c:       c,
+		ctx:     ctx,
+		options: options,
+		index:   make(map[string]int),
+		queried: make(map[string]bool),
+	}
+	var frontier []int
+	for _, seed := range seeds {
+		// fofa的domain字段是主域名
+		if d := registrableDomain(seed); len(d) > 0 {
+			seed = d
+		}
+		seed = strings.ToLower(strings.TrimSpace(seed))
+		if len(seed) == 0 {
+			continue
+		}
+		i, err := e.found(seed, -1, PivotSeed, "", "")
+		if errors.Is(err, errExpandStopped) {
+			return e.res, nil
+		}
+		if i >= 0 {
+			frontier = append(frontier, i)
+		}
+	}
+
+	for depth := 1; depth <= options.Depth && len(frontier) > 0; depth++ {
+		var next []int
+		for _, i := range frontier {
+			found, err := e.expand(i)
+			next = append(next, found...)
+			if errors.Is(err, errExpandStopped) {
+				return e.res, nil
+			}
+			if err != nil {
+				return e.res, err
+			}
+		}
+		frontier = next
+	}
+	return e.res, nil
+}
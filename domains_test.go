@@ -0,0 +1,100 @@
+package gofofa
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistrableDomain(t *testing.T) {
+	assert.Equal(t, "example.com", registrableDomain("a.b.example.com"))
+	assert.Equal(t, "example.com", registrableDomain("*.example.com"))
+	assert.Equal(t, "example.com.cn", registrableDomain(" WWW.Example.com.cn "))
+	assert.Equal(t, "", registrableDomain("1.2.3.4"))
+	assert.Equal(t, "", registrableDomain("[2001:db8::1]"))
+	assert.Equal(t, "", registrableDomain("com"))
+	assert.Equal(t, "", registrableDomain(""))
+}
+
+func TestClient_ExpandDomains(t *testing.T) {
+	results := map[string]interface{}{
+		`domain="seed.com"`: [][]string{
+			{"seed.com,*.cdn-seed.net,1.2.3.4", "Seed Inc", "111", "1.1.1.1"},
+			{"", "", "111", "1.1.1.2"},
+		},
+		`cert.subject.org="Seed Inc"`: []string{"org-a.com", "seed.com"},
+		`icon_hash="111"`:             []string{"icon-b.com", ""},
+		`ip="1.1.1.1"`:                []string{"ip-c.org", "org-a.com"},
+		`domain="cdn-seed.net"`:       [][]string{{"deep.io", "", "", ""}},
+	}
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(bindQueryHandle("/api/v1/search/all", func(w http.ResponseWriter, r *http.Request) {
+		q, _ := base64.StdEncoding.DecodeString(r.FormValue("qbase64"))
+		queries = append(queries, string(q))
+		res, ok := results[string(q)]
+		if !ok {
+			res = []string{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"error": false, "size": 10, "page": 1, "results": res})
+		w.Write(b)
+	})))
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	res, err := cli.ExpandDomains(ctx, []string{"www.seed.com"}, ExpandDomainsOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", res.Stopped)
+	assert.Equal(t, 5, res.Queries)
+	assert.Equal(t, []ExpandedDomain{
+		{Domain: "seed.com", Count: 3, Seed: "seed.com", Pivot: PivotSeed, Provenance: "seed.com"},
+		{Domain: "cdn-seed.net", Depth: 1, Count: 1, Seed: "seed.com", Pivot: PivotCertsDomains, Value: "seed.com", From: "seed.com",
+			Provenance: "seed.com > certs_domains > cdn-seed.net"},
+		{Domain: "org-a.com", Depth: 1, Count: 2, Seed: "seed.com", Pivot: PivotCertOrg, Value: "Seed Inc", From: "seed.com",
+			Provenance: `seed.com > cert.subject.org="Seed Inc" > org-a.com`},
+		{Domain: "icon-b.com", Depth: 1, Count: 1, Seed: "seed.com", Pivot: PivotIconHash, Value: "111", From: "seed.com",
+			Provenance: `seed.com > icon_hash="111" > icon-b.com`},
+		{Domain: "ip-c.org", Depth: 1, Count: 1, Seed: "seed.com", Pivot: PivotIP, Value: "1.1.1.1", From: "seed.com",
+			Provenance: `seed.com > ip="1.1.1.1" > ip-c.org`},
+	}, res.Domains)
+
+	// 第二层
+	queries = nil
+	res, err = cli.ExpandDomains(ctx, []string{"seed.com"}, ExpandDomainsOptions{Depth: 2, Pivots: []string{PivotCertsDomains}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`domain="seed.com"`, `domain="cdn-seed.net"`}, queries)
+	assert.Equal(t, 3, len(res.Domains))
+	assert.Equal(t, "seed.com > certs_domains > cdn-seed.net > certs_domains > deep.io", res.Domains[2].Provenance)
+	assert.Equal(t, 2, res.Domains[2].Depth)
+	assert.Equal(t, "cdn-seed.net", res.Domains[2].From)
+
+	// 停止条件
+	res, err = cli.ExpandDomains(ctx, []string{"seed.com"}, ExpandDomainsOptions{Depth: 3, MaxDomains: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, "max domains", res.Stopped)
+	assert.Equal(t, 3, len(res.Domains))
+	res, err = cli.ExpandDomains(ctx, []string{"seed.com"}, ExpandDomainsOptions{Depth: 3, MaxQueries: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "max queries", res.Stopped)
+	assert.Equal(t, 2, res.Queries)
+	assert.Equal(t, 3, len(res.Domains))
+	cli.SetBudget(Budget{MaxQueries: cli.Spent().Queries + 1})
+	res, err = cli.ExpandDomains(ctx, []string{"seed.com"}, ExpandDomainsOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "budget", res.Stopped)
+	cli.SetBudget(Budget{})
+
+	_, err = cli.ExpandDomains(ctx, []string{"seed.com"}, ExpandDomainsOptions{Pivots: []string{"title"}})
+	assert.Error(t, err)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = cli.ExpandDomains(canceled, []string{"seed.com"}, ExpandDomainsOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
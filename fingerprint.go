@@ -0,0 +1,277 @@
+package gofofa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxFingerprintJSQueries max queries of js file names
+const maxFingerprintJSQueries = 5
+
+// commonHeaders headers which every site may have, not used as fingerprint
+var commonHeaders = map[string]bool{
+	"Accept-Ranges":                true,
+	"Access-Control-Allow-Origin":  true,
+	"Age":                          true,
+	"Alt-Svc":                      true,
+	"Cache-Control":                true,
+	"Connection":                   true,
+	"Content-Encoding":             true,
+	"Content-Language":             true,
+	"Content-Length":               true,
+	"Content-Security-Policy":      true,
+	"Content-Type":                 true,
+	"Date":                         true,
+	"Etag":                         true,
+	"Expires":                      true,
+	"Keep-Alive":                   true,
+	"Last-Modified":                true,
+	"Location":                     true,
+	"Pragma":                       true,
+	"Referrer-Policy":              true,
+	"Server":                       true,
+	"Set-Cookie":                   true,
+	"Strict-Transport-Security":    true,
+	"Transfer-Encoding":            true,
+	"Vary":                         true,
+	"Via":                          true,
+	"X-Content-Type-Options":       true,
+	"X-Frame-Options":              true,
+	"X-Xss-Protection":             true,
+	"Permissions-Policy":           true,
+	"Cross-Origin-Opener-Policy":   true,
+	"Cross-Origin-Resource-Policy": true,
+}
+
+// SiteFingerprint everything of a live site fofa can pivot on
+type SiteFingerprint struct {
+	URL         string
+	StatusCode  int
+	Title       string
+	Server      string
+	IconHash    string
+	IconURL     string
+	CertSubject string            // common name or organization of subject
+	CertIssuer  string            // common name or organization of issuer
+	CertSerial  string            // decimal serial number
+	Headers     map[string]string // distinctive headers, such as X-Powered-By
+	Cookies     []string          // names of cookies set
+	JSFiles     []string          // file names of scripts
+}
+
+// FingerprintQuery candidate fofa query of fingerprint
+type FingerprintQuery struct {
+	Name  string // icon_hash/title/server/cert.subject/cert.issuer/cert.serial/header/cookie/js
+	Query string
+}
+
+// fofaQuote quote value of fofa query
+func fofaQuote(field string, value string) string {
+	return field + "=" + strconv.Quote(value)
+}
+
+// Queries candidate fofa queries of fingerprint, in order of icon_hash, title, server, cert, headers, cookies, js
+func (f SiteFingerprint) Queries() []FingerprintQuery {
+	var queries []FingerprintQuery
+	add := func(name string, field string, value string) {
+		if len(value) > 0 {
+			queries = append(queries, FingerprintQuery{Name: name, Query: fofaQuote(field, value)})
+		}
+	}
+
+	add("icon_hash", "icon_hash", f.IconHash)
+	add("title", "title", f.Title)
+	add("server", "server", f.Server)
+	add("cert.subject", "cert.subject", f.CertSubject)
+	add("cert.issuer", "cert.issuer", f.CertIssuer)
+	add("cert.serial", "cert", f.CertSerial)
+
+	names := make([]string, 0, len(f.Headers))
+	for name := range f.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("header", "header", name+": "+f.Headers[name])
+	}
+	for _, cookie := range f.Cookies {
+		add("cookie", "header", cookie+"=")
+	}
+	for i, js := range f.JSFiles {
+		if i == maxFingerprintJSQueries {
+			break
+		}
+		add("js", "body", js)
+	}
+	return queries
+}
+
+// CombinedQuery suggested query to find look-alike deployments
+// icon_hash, title and server are combined, headers or js files are added when there are less than two of them
+func (f SiteFingerprint) CombinedQuery() string {
+	var parts []string
+	var extra []string
+	for _, q := range f.Queries() {
+		switch q.Name {
+		case "icon_hash", "title", "server":
+			parts = append(parts, q.Query)
+		case "header", "cookie", "js":
+			extra = append(extra, q.Query)
+		}
+	}
+	for i := 0; len(parts) < 2 && i < len(extra); i++ {
+		parts = append(parts, extra[i])
+	}
+	return strings.Join(parts, " && ")
+}
+
+// ExtractTitleFromHtml title of html, whitespaces are collapsed
+func ExtractTitleFromHtml(data []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if atom.Lookup(name) != atom.Title {
+				continue
+			}
+			if z.Next() != html.TextToken {
+				return ""
+			}
+			return strings.Join(strings.Fields(string(z.Text())), " ")
+		}
+	}
+}
+
+// ExtractJSFilesFromHtml file names of <script src>, duplicated names are removed
+func ExtractJSFilesFromHtml(data []byte) []string {
+	var files []string
+	seen := make(map[string]bool)
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return files
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := z.TagName()
+		if atom.Lookup(name) != atom.Script {
+			continue
+		}
+		for hasAttr {
+			var k, v []byte
+			k, v, hasAttr = z.TagAttr()
+			if string(k) != "src" {
+				continue
+			}
+			u, err := url.Parse(strings.TrimSpace(string(v)))
+			if err != nil || len(u.Path) == 0 {
+				continue
+			}
+			file := path.Base(u.Path)
+			if file == "/" || file == "." || seen[file] {
+				continue
+			}
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+}
+
+// fingerprintHeaders distinctive headers and cookie names of response
+func fingerprintHeaders(header http.Header) (headers map[string]string, cookies []string) {
+	headers = make(map[string]string)
+	for name, values := range header {
+		if commonHeaders[name] || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+	seen := make(map[string]bool)
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			cookies = append(cookies, c.Name)
+		}
+	}
+	return
+}
+
+// certName common name of name, or first organization
+func certName(cn string, orgs []string) string {
+	if len(cn) > 0 {
+		return cn
+	}
+	if len(orgs) > 0 {
+		return orgs[0]
+	}
+	return ""
+}
+
+// FingerprintSite fetch site once and compute icon hash, title, server, cert, headers and js files
+// hasher decides timeout, headers and tls verify of requests, nil means default
+func FingerprintSite(ctx context.Context, siteURL string, hasher *IconHasher) (f SiteFingerprint, err error) {
+	if hasher == nil {
+		hasher = defaultIconHasher
+	}
+	if !strings.Contains(siteURL, "://") {
+		return f, errors.New("site url is not valid url")
+	}
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return f, err
+	}
+
+	resp, data, err := hasher.get(ctx, siteURL)
+	if err != nil {
+		return f, err
+	}
+	if int64(len(data)) > hasher.maxSize {
+		data = data[:hasher.maxSize]
+	}
+
+	f.URL = siteURL
+	f.StatusCode = resp.StatusCode
+	f.Server = resp.Header.Get("Server")
+	f.Headers, f.Cookies = fingerprintHeaders(resp.Header)
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		cert := resp.TLS.PeerCertificates[0]
+		f.CertSubject = certName(cert.Subject.CommonName, cert.Subject.Organization)
+		f.CertIssuer = certName(cert.Issuer.CommonName, cert.Issuer.Organization)
+		f.CertSerial = cert.SerialNumber.String()
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(data)
+	}
+	if strings.Contains(contentType, "html") {
+		f.Title = ExtractTitleFromHtml(data)
+		f.JSFiles = ExtractJSFilesFromHtml(data)
+	}
+
+	// 图标相对于跳转之后的地址
+	if resp.Request != nil && resp.Request.URL != nil {
+		u = resp.Request.URL
+	}
+	// 图标找不到不影响其他指纹
+	f.IconHash, f.IconURL, err = hasher.hashPage(ctx, u, data, contentType)
+	if err != nil {
+		hasher.logger.Debug("icon of site not found:", err)
+		f.IconURL = ""
+	}
+	return f, ctx.Err()
+}
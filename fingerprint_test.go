@@ -0,0 +1,146 @@
+package gofofa
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestExtractTitleFromHtml(t *testing.T) {
+	assert.Equal(t, "Hello World", ExtractTitleFromHtml([]byte("<html><head><TITLE>\n Hello\n  World </TITLE></head>")))
+	assert.Equal(t, "", ExtractTitleFromHtml([]byte("<html><title></title></html>")))
+	assert.Equal(t, "", ExtractTitleFromHtml([]byte("<html></html>")))
+}
+
+func TestExtractJSFilesFromHtml(t *testing.T) {
+	assert.Equal(t, []string{"app.js", "vendor.min.js"}, ExtractJSFilesFromHtml([]byte(`<html>
+<script src="/static/app.js?v=1"></script>
+<script src="https://cdn.fofa.info/lib/vendor.min.js"></script>
+<script src="/other/app.js"></script>
+<script>var a = 1;</script>
+<script src="/"></script>
+</html>`)))
+	assert.Nil(t, ExtractJSFilesFromHtml([]byte("<html></html>")))
+}
+
+func TestSiteFingerprint_Queries(t *testing.T) {
+	f := SiteFingerprint{
+		IconHash:    "-247388890",
+		Title:       `Admin "Login"`,
+		Server:      "nginx",
+		CertSubject: "fofa.info",
+		CertSerial:  "123",
+		Headers:     map[string]string{"X-Powered-By": "PHP/7.4", "X-App": "demo"},
+		Cookies:     []string{"JSESSIONID"},
+		JSFiles:     []string{"1.js", "2.js", "3.js", "4.js", "5.js", "6.js"},
+	}
+	queries := f.Queries()
+	assert.Equal(t, []FingerprintQuery{
+		{Name: "icon_hash", Query: `icon_hash="-247388890"`},
+		{Name: "title", Query: `title="Admin \"Login\""`},
+		{Name: "server", Query: `server="nginx"`},
+		{Name: "cert.subject", Query: `cert.subject="fofa.info"`},
+		{Name: "cert.serial", Query: `cert="123"`},
+		{Name: "header", Query: `header="X-App: demo"`},
+		{Name: "header", Query: `header="X-Powered-By: PHP/7.4"`},
+		{Name: "cookie", Query: `header="JSESSIONID="`},
+		{Name: "js", Query: `body="1.js"`},
+		{Name: "js", Query: `body="2.js"`},
+		{Name: "js", Query: `body="3.js"`},
+		{Name: "js", Query: `body="4.js"`},
+		{Name: "js", Query: `body="5.js"`},
+	}, queries)
+	assert.Equal(t, `icon_hash="-247388890" && title="Admin \"Login\"" && server="nginx"`, f.CombinedQuery())
+
+	// 不足两个时补充header
+	f = SiteFingerprint{Server: "nginx", Headers: map[string]string{"X-App": "demo"}, JSFiles: []string{"app.js"}}
+	assert.Equal(t, `server="nginx" && header="X-App: demo"`, f.CombinedQuery())
+	assert.Equal(t, "", SiteFingerprint{}.CombinedQuery())
+}
+
+func TestFingerprintSite(t *testing.T) {
+	ico, _ := os.ReadFile("./data/favicon.ico")
+	requests := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			requests++
+			w.Header().Set("Server", "nginx/1.20")
+			w.Header().Set("X-Powered-By", "PHP/7.4")
+			w.Header().Set("Content-Type", "text/html")
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "abc"})
+			w.Write([]byte(`<html><head><title>Demo App</title><link rel="icon" href="/static/favicon.ico"></head>
+<body><script src="/static/main.js"></script></body></html>`))
+		case "/static/favicon.ico":
+			w.Header().Set("Content-Type", "image/x-icon")
+			w.Write(ico)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	// 证书校验失败
+	_, err := FingerprintSite(context.Background(), ts.URL, nil)
+	assert.Error(t, err)
+
+	f, err := FingerprintSite(context.Background(), ts.URL, NewIconHasher(WithIconInsecure(true)))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 200, f.StatusCode)
+	assert.Equal(t, "Demo App", f.Title)
+	assert.Equal(t, "nginx/1.20", f.Server)
+	assert.Equal(t, "-247388890", f.IconHash)
+	assert.Equal(t, ts.URL+"/static/favicon.ico", f.IconURL)
+	assert.Equal(t, "Acme Co", f.CertSubject)
+	assert.Equal(t, "Acme Co", f.CertIssuer)
+	assert.Equal(t, ts.Certificate().SerialNumber.String(), f.CertSerial)
+	assert.Equal(t, map[string]string{"X-Powered-By": "PHP/7.4"}, f.Headers)
+	assert.Equal(t, []string{"PHPSESSID"}, f.Cookies)
+	assert.Equal(t, []string{"main.js"}, f.JSFiles)
+	assert.Equal(t, `icon_hash="-247388890" && title="Demo App" && server="nginx/1.20"`, f.CombinedQuery())
+
+	// 没有图标不影响其他指纹
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<title>Plain</title>`))
+	}))
+	defer plain.Close()
+	f, err = FingerprintSite(context.Background(), plain.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "Plain", f.Title)
+	assert.Equal(t, "", f.IconHash)
+	assert.Equal(t, "", f.IconURL)
+	assert.Equal(t, "", f.CertSerial)
+
+	// 跳转之后，图标相对于最终地址
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/app/index.html", http.StatusFound)
+		case "/app/index.html":
+			w.Write([]byte(`<html><head><title>App</title><link rel="icon" href="favicon.ico"></head></html>`))
+		case "/app/favicon.ico":
+			w.Header().Set("Content-Type", "image/x-icon")
+			w.Write(ico)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer redirect.Close()
+	f, err = FingerprintSite(context.Background(), redirect.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, redirect.URL, f.URL)
+	assert.Equal(t, "App", f.Title)
+	assert.Equal(t, "-247388890", f.IconHash)
+	assert.Equal(t, redirect.URL+"/app/favicon.ico", f.IconURL)
+
+	_, err = FingerprintSite(context.Background(), "fofa.info", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,205 @@
+package gofofa
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SchemeMap fofa protocol => url scheme, such as ftp => ftp, elastic => http
+// empty scheme means infer from port and cert
+type SchemeMap map[string]string
+
+// DefaultSchemeMap scheme map of common fofa protocols
+var DefaultSchemeMap = SchemeMap{
+	"http":       "http",
+	"https":      "https",
+	"socks5":     "socks5",
+	"socks4":     "socks4",
+	"redis":      "redis",
+	"mongodb":    "mongodb",
+	"mysql":      "mysql",
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"mssql":      "mssql",
+	"oracle":     "oracle",
+	"ftp":        "ftp",
+	"ftps":       "ftps",
+	"sftp":       "sftp",
+	"ssh":        "ssh",
+	"telnet":     "telnet",
+	"rdp":        "rdp",
+	"vnc":        "vnc",
+	"smb":        "smb",
+	"ldap":       "ldap",
+	"ldaps":      "ldaps",
+	"rtsp":       "rtsp",
+	"sip":        "sip",
+	"mqtt":       "mqtt",
+	"amqp":       "amqp",
+	"memcache":   "memcache",
+	"zookeeper":  "zookeeper",
+	"rsync":      "rsync",
+	"git":        "git",
+	"elastic":    "", // http or https
+	"kubernetes": "", // http or https
+	"docker":     "", // http or https
+	"unknown":    "",
+}
+
+// defaultPorts default port of scheme, it will be stripped from url
+var defaultPorts = map[string]string{
+	"http":      "80",
+	"https":     "443",
+	"ftp":       "21",
+	"ftps":      "990",
+	"sftp":      "22",
+	"ssh":       "22",
+	"telnet":    "23",
+	"rdp":       "3389",
+	"vnc":       "5900",
+	"smb":       "445",
+	"ldap":      "389",
+	"ldaps":     "636",
+	"rtsp":      "554",
+	"sip":       "5060",
+	"mqtt":      "1883",
+	"amqp":      "5672",
+	"redis":     "6379",
+	"mongodb":   "27017",
+	"mysql":     "3306",
+	"postgres":  "5432",
+	"mssql":     "1433",
+	"oracle":    "1521",
+	"memcache":  "11211",
+	"zookeeper": "2181",
+	"rsync":     "873",
+	"git":       "9418",
+}
+
+// httpsPorts ports treated as https when the scheme can not be decided by protocol
+var httpsPorts = map[string]bool{
+	"443":  true,
+	"8443": true,
+}
+
+// Merge return a new map, values of other override values of m
+func (m SchemeMap) Merge(other SchemeMap) SchemeMap {
+	newMap := make(SchemeMap, len(m)+len(other))
+	for k, v := range m {
+		newMap[k] = v
+	}
+	for k, v := range other {
+		newMap[strings.ToLower(k)] = v
+	}
+	return newMap
+}
+
+// LoadSchemeMap load scheme map from json file, format: {"<protocol>":"<scheme>"}
+func LoadSchemeMap(filename string) (SchemeMap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m SchemeMap
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse scheme map %s failed: %w", filename, err)
+	}
+	return m, nil
+}
+
+// Scheme decide url scheme of protocol
+// if protocol is not in map or mapped to empty scheme, https is used when cert exists or port is 443/8443, otherwise http
+func (m SchemeMap) Scheme(protocol string, port string, hasCert bool) string {
+	if scheme := m[strings.ToLower(protocol)]; scheme != "" {
+		return scheme
+	}
+	if hasCert || httpsPorts[port] {
+		return "https"
+	}
+	return "http"
+}
+
+// URL build canonical url from fofa host field, ipv6 address is wrapped with brackets and default port is stripped
+// host can be: 1.1.1.1:81, [2001:db8::1]:443, example.com, https://example.com:8443
+func (m SchemeMap) URL(host string, protocol string, cert string) string {
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return host
+		}
+		if u.Port() != "" && defaultPorts[strings.ToLower(u.Scheme)] == u.Port() {
+			u.Host = joinHostPort(u.Hostname(), "")
+		}
+		return u.String()
+	}
+
+	hostname, port := splitHostPort(host)
+	scheme := m.Scheme(protocol, port, len(cert) > 0)
+	if defaultPorts[scheme] == port {
+		port = ""
+	}
+	return scheme + "://" + joinHostPort(hostname, port)
+}
+
+// splitHostPort split host into hostname and port, port is empty if not exists
+func splitHostPort(host string) (string, string) {
+	if hostname, port, err := net.SplitHostPort(host); err == nil {
+		return hostname, port
+	}
+	// no port, or ipv6 address without brackets
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), ""
+}
+
+// joinHostPort join hostname and port, ipv6 address is wrapped with brackets
+func joinHostPort(hostname string, port string) string {
+	if port == "" {
+		if strings.Contains(hostname, ":") {
+			return "[" + hostname + "]"
+		}
+		return hostname
+	}
+	return net.JoinHostPort(hostname, port)
+}
+
+// fixHostToUrl 替换host为url
+func fixHostToUrl(res [][]string, fields []string, hostIndex int, urlPrefix string, protocolIndex int, schemeMap SchemeMap) [][]string {
+	if schemeMap == nil {
+		schemeMap = DefaultSchemeMap
+	}
+
+	certIndex := -1
+	for index, f := range fields {
+		if f == "cert" {
+			certIndex = index
+			break
+		}
+	}
+
+	newRes := make([][]string, 0, len(res))
+	for _, row := range res {
+		newRow := make([]string, 0, len(fields))
+		for j, r := range row {
+			if j == hostIndex {
+				if urlPrefix != "" && !strings.Contains(r, "://") {
+					r = urlPrefix + r
+				} else {
+					var protocol, cert string
+					if protocolIndex != -1 && protocolIndex < len(row) {
+						protocol = row[protocolIndex]
+					}
+					if certIndex != -1 && certIndex < len(row) {
+						cert = row[certIndex]
+					}
+					r = schemeMap.URL(r, protocol, cert)
+				}
+			}
+			newRow = append(newRow, r)
+		}
+		newRes = append(newRes, newRow)
+	}
+	return newRes
+}
@@ -0,0 +1,76 @@
+package gofofa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemeMap_URL(t *testing.T) {
+	m := DefaultSchemeMap
+
+	// 协议映射
+	assert.Equal(t, "ftp://1.2.3.4", m.URL("1.2.3.4:21", "ftp", ""))
+	assert.Equal(t, "ssh://1.2.3.4:2222", m.URL("1.2.3.4:2222", "ssh", ""))
+	assert.Equal(t, "rdp://1.2.3.4", m.URL("1.2.3.4:3389", "rdp", ""))
+	assert.Equal(t, "postgres://1.2.3.4", m.URL("1.2.3.4:5432", "postgresql", ""))
+	assert.Equal(t, "socks5://1.1.1.1:1080", m.URL("1.1.1.1:1080", "socks5", ""))
+	assert.Equal(t, "redis://2.2.2.2:1080", m.URL("2.2.2.2:1080", "REDIS", ""))
+
+	// 端口推断
+	assert.Equal(t, "https://1.2.3.4", m.URL("1.2.3.4:443", "unknown", ""))
+	assert.Equal(t, "https://1.2.3.4:8443", m.URL("1.2.3.4:8443", "", ""))
+	assert.Equal(t, "http://1.2.3.4:9200", m.URL("1.2.3.4:9200", "elastic", ""))
+	assert.Equal(t, "http://1.2.3.4:8080", m.URL("1.2.3.4:8080", "not-exists", ""))
+	assert.Equal(t, "http://example.com", m.URL("example.com", "", ""))
+
+	// 证书推断
+	assert.Equal(t, "https://1.2.3.4:9200", m.URL("1.2.3.4:9200", "elastic", "Version: v3"))
+
+	// ipv6
+	assert.Equal(t, "https://[2001:db8::1]", m.URL("[2001:db8::1]:443", "https", ""))
+	assert.Equal(t, "http://[2001:db8::1]:8080", m.URL("[2001:db8::1]:8080", "http", ""))
+	assert.Equal(t, "http://[2001:db8::1]", m.URL("2001:db8::1", "", ""))
+
+	// 已经是url
+	assert.Equal(t, "https://1.2.3.4:8443", m.URL("https://1.2.3.4:8443", "https", ""))
+	assert.Equal(t, "https://1.2.3.4", m.URL("https://1.2.3.4:443", "https", ""))
+
+	// 自定义映射
+	custom := m.Merge(SchemeMap{"HTTP": "https", "unknown": "tcp"})
+	assert.Equal(t, "https://1.2.3.4:80", custom.URL("1.2.3.4:80", "http", ""))
+	assert.Equal(t, "tcp://1.2.3.4:443", custom.URL("1.2.3.4:443", "unknown", ""))
+	assert.Equal(t, "http", m["http"])
+}
+
+func TestFixHostToUrl(t *testing.T) {
+	fields := []string{"host", "protocol", "cert"}
+	res := fixHostToUrl([][]string{
+		{"1.1.1.1:443", "unknown", ""},
+		{"1.1.1.1:9200", "elastic", "cert"},
+		{"1.1.1.1:6379", "redis", ""},
+	}, fields, 0, "", 1, nil)
+	assert.Equal(t, "https://1.1.1.1", res[0][0])
+	assert.Equal(t, "https://1.1.1.1:9200", res[1][0])
+	assert.Equal(t, "redis://1.1.1.1", res[2][0])
+
+	// urlPrefix 优先
+	res = fixHostToUrl([][]string{{"1.1.1.1:6379", "redis", ""}}, fields, 0, "tcp://", 1, nil)
+	assert.Equal(t, "tcp://1.1.1.1:6379", res[0][0])
+}
+
+func TestLoadSchemeMap(t *testing.T) {
+	_, err := LoadSchemeMap("./data/not_exists.json")
+	assert.Error(t, err)
+
+	f := filepath.Join(t.TempDir(), "schemes.json")
+	assert.Nil(t, os.WriteFile(f, []byte(`{"elastic":"https"}`), 0644))
+	m, err := LoadSchemeMap(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "https", m["elastic"])
+
+	assert.Nil(t, os.WriteFile(f, []byte(`[]`), 0644))
+	_, err = LoadSchemeMap(f)
+	assert.Error(t, err)
+}
@@ -5,14 +5,17 @@ go 1.18
 require (
 	github.com/avast/retry-go v3.0.0+incompatible
 	github.com/fatih/color v1.13.0
+	github.com/oschwald/maxminddb-golang v1.10.0
 	github.com/pkg/browser v0.0.0-20210911075715-681adbf594b8
 	github.com/sirupsen/logrus v1.8.1
-	github.com/stretchr/testify v1.7.1
+	github.com/stretchr/testify v1.8.1
 	github.com/twmb/murmur3 v1.1.6
 	github.com/urfave/cli/v2 v2.6.0
 	github.com/vincent-petithory/dataurl v1.0.0
 	github.com/weppos/publicsuffix-go v0.30.1
+	go.etcd.io/bbolt v1.3.7
 	golang.org/x/net v0.12.0
+	golang.org/x/time v0.5.0
 )
 
 require (
@@ -26,7 +29,6 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
-	golang.org/x/time v0.5.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20200902074654-038fdea0a05b // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210106172901-c476de37821d // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -61,38 +61,12 @@ func (s *HostStatsData) SetTraceId(traceId string) {
 
 // SearchOptions options of search, for post processors
 type SearchOptions struct {
-	FixUrl    bool   // each host fix as url, like 1.1.1.1,80 will change to http://1.1.1.1, https://1.1.1.1:8443 will no change
-	UrlPrefix string // default is http://
-	Full      bool   // search result for over a year
-	UniqByIP  bool   // uniq by ip
-}
-
-// fixHostToUrl 替换host为url
-func fixHostToUrl(res [][]string, fields []string, hostIndex int, urlPrefix string, protocolIndex int) [][]string {
-
-	newRes := make([][]string, 0, len(res))
-	for _, row := range res {
-		newRow := make([]string, 0, len(fields))
-		for j, r := range row {
-			if j == hostIndex {
-				if !strings.Contains(r, "://") {
-					if urlPrefix != "" {
-						r = urlPrefix + r
-					} else if protocolIndex != -1 &&
-						(row[protocolIndex] == "socks5" || row[protocolIndex] == "redis" ||
-							row[protocolIndex] == "http" || row[protocolIndex] == "https" ||
-							row[protocolIndex] == "mongodb" || row[protocolIndex] == "mysql") {
-						r = row[protocolIndex] + "://" + r
-					} else {
-						r = "http://" + r
-					}
-				}
-			}
-			newRow = append(newRow, r)
-		}
-		newRes = append(newRes, newRow)
-	}
-	return newRes
+	FixUrl    bool      // each host fix as url, like 1.1.1.1,80 will change to http://1.1.1.1, https://1.1.1.1:8443 will no change
+	UrlPrefix string    // prefix of url when FixUrl set, empty means scheme is decided by SchemeMap
+	Full      bool      // search result for over a year
+	UniqByIP  bool      // uniq by ip
+	SchemeMap SchemeMap // protocol to url scheme when FixUrl set, merged with DefaultSchemeMap
+	Budget    Budget    // spending limit of this call, checked together with client budget
 }
 
 // fixUrlCheck 检查参数，构建新的field和记录相关字段的偏移
@@ -146,7 +120,8 @@ func (c *Client) fixUrlCheck(fields []string, options ...SearchOptions) (int, in
 func (c *Client) postProcess(res [][]string, fields []string,
 	hostIndex int, protocolIndex int, rawFieldSize int, options ...SearchOptions) [][]string {
 	if len(options) > 0 && options[0].FixUrl {
-		res = fixHostToUrl(res, fields, hostIndex, options[0].UrlPrefix, protocolIndex)
+		res = fixHostToUrl(res, fields, hostIndex, options[0].UrlPrefix, protocolIndex,
+			DefaultSchemeMap.Merge(options[0].SchemeMap))
 	}
 
 	// 返回用户指定的字段
@@ -200,6 +175,18 @@ func (c *Client) HostSearch(query string, size int, fields []string, options ...
 		}
 	}
 
+	// 预算限制取数量
+	guard := c.newBudgetGuard(options...)
+	budgetLimited := false
+	remain := guard.remainRows()
+	if remain != -1 && (size == -1 || size > remain) {
+		if remain == 0 {
+			return nil, guard.rowsExceeded()
+		}
+		size = remain
+		budgetLimited = true
+	}
+
 	page := 1
 	perPage := int(math.Min(float64(size), 1000)) // 最多一次取1000
 
@@ -208,6 +195,16 @@ func (c *Client) HostSearch(query string, size int, fields []string, options ...
 		perPage = 1000
 	}
 
+	// 有行数预算时，按页码翻页不能中途改变每页数量，平均分页保证最后一页不超出预算
+	if remain != -1 && size > perPage {
+		pages := (size + perPage - 1) / perPage
+		perPage = (size + pages - 1) / pages
+		if pages*perPage > remain {
+			// 不能整除时少取几条
+			perPage = size / pages
+		}
+	}
+
 	hostIndex, protocolIndex, fields, rawFieldSize, err := c.fixUrlCheck(fields, options...)
 	if err != nil {
 		return nil, err
@@ -241,6 +238,10 @@ func (c *Client) HostSearch(query string, size int, fields []string, options ...
 			}
 		}
 
+		if err = guard.check(perPage); err != nil {
+			break
+		}
+
 		var hr HostResults
 		err = retry.Do(
 			func() error {
@@ -282,6 +283,7 @@ func (c *Client) HostSearch(query string, size int, fields []string, options ...
 
 		var results [][]string
 		if v, ok := hr.Results.([]interface{}); ok {
+			guard.spend(len(v))
 			// 无数据
 			if len(v) == 0 {
 				break
@@ -322,6 +324,12 @@ func (c *Client) HostSearch(query string, size int, fields []string, options ...
 
 		// 数据填满了，完成
 		if size != -1 && size <= len(res) {
+			if budgetLimited {
+				res = res[:size]
+				if hr.Size > size {
+					err = guard.rowsExceeded()
+				}
+			}
 			break
 		}
 
@@ -339,17 +347,19 @@ func (c *Client) HostSearch(query string, size int, fields []string, options ...
 	return
 }
 
-// HostSize fetch query matched host count
-func (c *Client) HostSize(query string) (count int, err error) {
+// hostCount fetch matched count of query with fields and full option
+func (c *Client) hostCount(query string, fields []string, full bool) (count int, err error) {
 	var hr HostResults
-	err = c.Fetch("search/all",
-		map[string]string{
-			"qbase64": base64.StdEncoding.EncodeToString([]byte(query)),
-			"size":    "1",
-			"page":    "1",
-			"full":    "false", // 是否全部数据，非一年内
-		},
-		&hr)
+	params := map[string]string{
+		"qbase64": base64.StdEncoding.EncodeToString([]byte(query)),
+		"size":    "1",
+		"page":    "1",
+		"full":    strconv.FormatBool(full), // 是否全部数据，非一年内
+	}
+	if len(fields) > 0 {
+		params["fields"] = strings.Join(fields, ",")
+	}
+	err = c.Fetch("search/all", params, &hr)
 	if err != nil {
 		if c.traceId {
 			err = fmt.Errorf("[%s]%s", hr.TraceId, err.Error())
@@ -357,10 +367,25 @@ func (c *Client) HostSize(query string) (count int, err error) {
 		return
 	}
 
+	// 报错，退出
+	if len(hr.Errmsg) > 0 {
+		if c.traceId {
+			err = errors.New(hr.Errmsg + " trace id: " + hr.TraceId)
+		} else {
+			err = errors.New(hr.Errmsg)
+		}
+		return
+	}
+
 	count = hr.Size
 	return
 }
 
+// HostSize fetch query matched host count
+func (c *Client) HostSize(query string) (count int, err error) {
+	return c.hostCount(query, nil, false)
+}
+
 // HostStats fetch query matched host count
 func (c *Client) HostStats(host string) (data HostStatsData, err error) {
 	err = c.Fetch("host/"+host, nil, &data)
@@ -396,6 +421,20 @@ func (c *Client) DumpSearch(query string, allSize int, batchSize int, fields []s
 		return err
 	}
 
+	// 预算限制取数量
+	guard := c.newBudgetGuard(options...)
+	budgetLimited := false
+	if remain := guard.remainRows(); remain != -1 && (allSize <= 0 || allSize > remain) {
+		if remain == 0 {
+			return guard.rowsExceeded()
+		}
+		allSize = remain
+		budgetLimited = true
+	}
+	if allSize > 0 && perPage > allSize {
+		perPage = allSize
+	}
+
 	// 分页取数据
 	fetchedSize := 0
 	for {
@@ -409,6 +448,15 @@ func (c *Client) DumpSearch(query string, allSize int, batchSize int, fields []s
 			}
 		}
 
+		// 按next翻页，最后一页只取剩下的数量
+		pageSize := perPage
+		if allSize > 0 && allSize-fetchedSize < pageSize {
+			pageSize = allSize - fetchedSize
+		}
+		if err = guard.check(pageSize); err != nil {
+			return err
+		}
+
 		// 添加默认三次重试，防止大数据量拉取时的报错
 		var hr HostResults
 		err = retry.Do(
@@ -416,7 +464,7 @@ func (c *Client) DumpSearch(query string, allSize int, batchSize int, fields []s
 				err = c.Fetch("search/next",
 					map[string]string{
 						"qbase64": base64.StdEncoding.EncodeToString([]byte(query)),
-						"size":    strconv.Itoa(perPage),
+						"size":    strconv.Itoa(pageSize),
 						"fields":  strings.Join(fields, ","),
 						"full":    strconv.FormatBool(full), // 是否全部数据，非一年内
 						"next":    next,                     // 偏移
@@ -451,6 +499,7 @@ func (c *Client) DumpSearch(query string, allSize int, batchSize int, fields []s
 
 		var results [][]string
 		if v, ok := hr.Results.([]interface{}); ok {
+			guard.spend(len(v))
 			// 无数据
 			if len(v) == 0 {
 				break
@@ -470,6 +519,11 @@ func (c *Client) DumpSearch(query string, allSize int, batchSize int, fields []s
 			break
 		}
 
+		// 预算内的数据
+		if budgetLimited && fetchedSize+len(results) > allSize {
+			results = results[:allSize-fetchedSize]
+		}
+
 		// 后处理
 		results = c.postProcess(results, fields, hostIndex, protocolIndex, rawFieldSize, options...)
 
@@ -484,11 +538,14 @@ func (c *Client) DumpSearch(query string, allSize int, batchSize int, fields []s
 
 		// 数据填满了，完成
 		if allSize > 0 && allSize <= fetchedSize {
+			if budgetLimited && hr.Size > fetchedSize {
+				return guard.rowsExceeded()
+			}
 			break
 		}
 
 		// 数据已经没有了
-		if len(results) < perPage {
+		if len(results) < pageSize {
 			break
 		}
 
@@ -239,8 +239,8 @@ func TestClient_HostSearch_FixUrl(t *testing.T) {
 	assert.Equal(t, "https://3.3.3.3:1080", res[2][0])
 
 	// no url prefix and no protocol
-	hosts := fixHostToUrl([][]string{{"1.1.1.1:80", "1.1.1.1", "80"}}, []string{"host", "ip", "port"}, 0, "", -1)
-	assert.Equal(t, "http://1.1.1.1:80", hosts[0][0])
+	hosts := fixHostToUrl([][]string{{"1.1.1.1:80", "1.1.1.1", "80"}}, []string{"host", "ip", "port"}, 0, "", -1, nil)
+	assert.Equal(t, "http://1.1.1.1", hosts[0][0])
 
 	// todo：确保返回的字段数跟用户要求的一致
 
@@ -262,6 +262,10 @@ func TestClient_HostSize(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 12345678, count)
 
+	// 语法错误
+	count, err = cli.HostSize("aaa=bbb")
+	assert.EqualError(t, err, "[820000] FOFA Query Syntax Incorrect")
+
 	// 请求失败
 	cli = &Client{
 		Server:     "http://fofa.info:66666",
@@ -0,0 +1,100 @@
+package gofofa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// fofaTimeLayout time format of fofa api
+const fofaTimeLayout = "2006-01-02 15:04:05"
+
+// FofaTime time of fofa api, format: 2006-01-02 15:04:05, empty string is zero time
+type FofaTime struct {
+	time.Time
+}
+
+// UnmarshalJSON parse fofa time string
+func (t *FofaTime) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	v, err := time.Parse(fofaTimeLayout, s)
+	if err != nil {
+		return err
+	}
+	t.Time = v
+	return nil
+}
+
+// MarshalJSON format as fofa time string
+func (t FofaTime) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// String format as fofa time string, empty if zero
+func (t FofaTime) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(fofaTimeLayout)
+}
+
+// HostProduct product of one port
+type HostProduct struct {
+	Product      string `json:"product"`
+	Category     string `json:"category"`
+	Level        int    `json:"level"`          // 1: hardware, 2: system, 3: service, 4: support, 5: application
+	SortHardCode int    `json:"sort_hard_code"` // sort priority of product
+	Company      string `json:"company,omitempty"`
+}
+
+// HostPort detail of one port
+type HostPort struct {
+	Port         int           `json:"port"`
+	Protocol     string        `json:"protocol"`
+	BaseProtocol string        `json:"base_protocol"`
+	Banner       string        `json:"banner,omitempty"`
+	Products     []HostProduct `json:"products"`
+	UpdateTime   FofaTime      `json:"update_time"`
+}
+
+// HostDetailData /host api results with detail
+type HostDetailData struct {
+	Error       bool       `json:"error"`
+	Errmsg      string     `json:"errmsg,omitempty"`
+	TraceId     string     `json:"trace_id,omitempty"`
+	Host        string     `json:"host"`
+	IP          string     `json:"ip"`
+	ASN         int        `json:"asn"`
+	ORG         string     `json:"org"`
+	Country     string     `json:"country_name"`
+	CountryCode string     `json:"country_code"`
+	Ports       []HostPort `json:"ports"`
+	UpdateTime  FofaTime   `json:"update_time"`
+}
+
+func (s *HostDetailData) SetTraceId(traceId string) {
+	s.TraceId = traceId
+}
+
+// HostDetail fetch host data with port-level details, such as banner, products and update time of each port
+func (c *Client) HostDetail(ctx context.Context, host string) (data HostDetailData, err error) {
+	err = c.FetchContext(ctx, "host/"+host, map[string]string{
+		"detail": "true",
+	}, &data)
+	if err != nil {
+		return
+	}
+	if data.Error {
+		if c.traceId {
+			err = errors.New(data.Errmsg + " trace id: " + data.TraceId)
+		} else {
+			err = errors.New(data.Errmsg)
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package gofofa
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_HostDetail(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(bindQueryHandle("/api/v1/host/1.1.1.1", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("detail") != "true" {
+			w.Write([]byte(`{"error":true,"errmsg":"[-4] Params Error"}`))
+			return
+		}
+		w.Write([]byte(`{
+  "error": false,
+  "host": "1.1.1.1",
+  "ip": "1.1.1.1",
+  "asn": 6805,
+  "org": "Telefonica Germany",
+  "country_name": "Germany",
+  "country_code": "DE",
+  "ports": [
+    {
+      "port": 443,
+      "protocol": "https",
+      "base_protocol": "tcp",
+      "banner": "HTTP/1.1 200 OK",
+      "products": [
+        {"product": "Synology-WebStation", "category": "Other Software", "level": 5, "sort_hard_code": 2}
+      ],
+      "update_time": "2022-05-24 12:00:00"
+    },
+    {
+      "port": 5060,
+      "protocol": "sip",
+      "base_protocol": "udp",
+      "update_time": ""
+    }
+  ],
+  "update_time": "2022-05-24 12:00:00"
+}`))
+	})))
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+
+	data, err := cli.HostDetail(context.Background(), "1.1.1.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Germany", data.Country)
+	assert.Equal(t, 2, len(data.Ports))
+	assert.Equal(t, 443, data.Ports[0].Port)
+	assert.Equal(t, "HTTP/1.1 200 OK", data.Ports[0].Banner)
+	assert.Equal(t, "Synology-WebStation", data.Ports[0].Products[0].Product)
+	assert.Equal(t, "Other Software", data.Ports[0].Products[0].Category)
+	assert.Equal(t, 5, data.Ports[0].Products[0].Level)
+	assert.Equal(t, time.Date(2022, 5, 24, 12, 0, 0, 0, time.UTC), data.Ports[0].UpdateTime.Time)
+	assert.True(t, data.Ports[1].UpdateTime.IsZero())
+	assert.Equal(t, "2022-05-24 12:00:00", data.UpdateTime.String())
+
+	// 序列化时间
+	d, err := json.Marshal(data.Ports[1])
+	assert.Nil(t, err)
+	assert.Contains(t, string(d), `"update_time":""`)
+
+	// 取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cli.HostDetail(ctx, "1.1.1.1")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// 返回错误
+	_, err = cli.HostDetail(nil, "2.2.2.2")
+	assert.Error(t, err)
+
+	// 时间格式错误
+	var ft FofaTime
+	assert.Error(t, json.Unmarshal([]byte(`"2022/05/24"`), &ft))
+}
@@ -0,0 +1,278 @@
+package gofofa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vincent-petithory/dataurl"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// sources of icon candidates
+const (
+	IconSourceFile     = "file"     // local file
+	IconSourceURL      = "url"      // input url is an image
+	IconSourceManifest = "manifest" // icons of web manifest
+	IconSourceDefault  = "default"  // /favicon.ico
+)
+
+// iconRels rel of link which is an icon
+var iconRels = map[string]bool{
+	"icon":                         true,
+	"shortcut icon":                true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"mask-icon":                    true,
+	"fluid-icon":                   true,
+}
+
+// defaultManifests tried when no manifest link in html
+var defaultManifests = []string{"/manifest.json", "/site.webmanifest"}
+
+// IconCandidate icon url found of input
+type IconCandidate struct {
+	URL    string // absolute url, or data uri of inline icon
+	Source string // rel of link like icon/apple-touch-icon/mask-icon, or manifest/default/url/file
+	Sizes  string // sizes attribute of link or manifest
+}
+
+// IconCandidateHash hash result of icon candidate
+type IconCandidateHash struct {
+	IconCandidate
+	Hash string
+	Err  error
+}
+
+// ExtractIconCandidates all icon links and manifest links of html, hrefs are resolved against <base href> and pageURL
+func ExtractIconCandidates(data []byte, pageURL *url.URL) (icons []IconCandidate, manifests []string) {
+	base := pageURL
+	resolve := func(href string) (string, bool) {
+		href = strings.TrimSpace(href)
+		if len(href) == 0 {
+			return "", false
+		}
+		if strings.HasPrefix(href, "data:") {
+			return href, true
+		}
+		rel, err := url.Parse(href)
+		if err != nil {
+			return "", false
+		}
+		if base == nil {
+			return rel.String(), true
+		}
+		return base.ResolveReference(rel).String(), true
+	}
+
+	seenBase := false
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		attrs := make(map[string]string)
+		for hasAttr {
+			var k, v []byte
+			k, v, hasAttr = z.TagAttr()
+			attrs[strings.ToLower(string(k))] = string(v)
+		}
+
+		switch atom.Lookup(name) {
+		case atom.Base:
+			// 只有第一个base生效
+			if seenBase {
+				continue
+			}
+			seenBase = true
+			if href, ok := attrs["href"]; ok {
+				if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+					if base != nil {
+						u = base.ResolveReference(u)
+					}
+					base = u
+				}
+			}
+		case atom.Link:
+			rel := strings.Join(strings.Fields(strings.ToLower(attrs["rel"])), " ")
+			href, ok := resolve(attrs["href"])
+			if !ok {
+				continue
+			}
+			if rel == "manifest" {
+				manifests = append(manifests, href)
+				continue
+			}
+			if !iconRels[rel] && !strings.Contains(" "+rel+" ", " icon ") {
+				continue
+			}
+			icons = append(icons, IconCandidate{URL: href, Source: rel, Sizes: attrs["sizes"]})
+		case atom.Meta:
+			if strings.EqualFold(attrs["name"], "msapplication-TileImage") {
+				if href, ok := resolve(attrs["content"]); ok {
+					icons = append(icons, IconCandidate{URL: href, Source: "msapplication-TileImage"})
+				}
+			}
+		}
+	}
+}
+
+// ParseManifestIcons icons of web manifest, src is resolved against manifestURL
+func ParseManifestIcons(data []byte, manifestURL *url.URL) ([]IconCandidate, error) {
+	var manifest struct {
+		Icons []struct {
+			Src   string `json:"src"`
+			Sizes string `json:"sizes"`
+		} `json:"icons"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	var icons []IconCandidate
+	for _, icon := range manifest.Icons {
+		rel, err := url.Parse(strings.TrimSpace(icon.Src))
+		if err != nil || len(icon.Src) == 0 {
+			continue
+		}
+		icons = append(icons, IconCandidate{
+			URL:    manifestURL.ResolveReference(rel).String(),
+			Source: IconSourceManifest,
+			Sizes:  icon.Sizes,
+		})
+	}
+	return icons, nil
+}
+
+// Candidates every icon candidate of input, duplicated urls are removed
+// input can be local file, icon url or homepage, homepage candidates come from
+// link icons, <meta msapplication-TileImage>, web manifests and /favicon.ico
+func (h *IconHasher) Candidates(ctx context.Context, input string) ([]IconCandidate, error) {
+	if _, err := os.Stat(input); err == nil {
+		return []IconCandidate{{URL: input, Source: IconSourceFile}}, nil
+	}
+	if !strings.Contains(input, "://") {
+		return nil, errors.New("icon url is not valid url")
+	}
+	data, contentType, u, err := h.fetch(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if isImageContent(contentType) {
+		return []IconCandidate{{URL: input, Source: IconSourceURL}}, nil
+	}
+
+	var icons []IconCandidate
+	var manifests []string
+	if strings.Contains(contentType, "html") {
+		icons, manifests = ExtractIconCandidates(data, u)
+	}
+
+	// 页面没有声明manifest时尝试默认位置
+	defaultManifest := len(manifests) == 0
+	if defaultManifest {
+		for _, p := range defaultManifests {
+			manifests = append(manifests, u.Scheme+"://"+u.Host+p)
+		}
+	}
+	for _, m := range manifests {
+		data, _, mu, err := h.fetch(ctx, m)
+		if err != nil {
+			h.logger.Debug("fetch manifest failed:", err)
+			continue
+		}
+		manifestIcons, err := ParseManifestIcons(data, mu)
+		if err != nil {
+			if !defaultManifest {
+				h.logger.Debug("parse manifest failed:", err)
+			}
+			continue
+		}
+		icons = append(icons, manifestIcons...)
+	}
+
+	icons = append(icons, IconCandidate{URL: u.Scheme + "://" + u.Host + "/favicon.ico", Source: IconSourceDefault})
+
+	// 去重，保留先出现的来源
+	seen := make(map[string]bool)
+	candidates := make([]IconCandidate, 0, len(icons))
+	for _, icon := range icons {
+		if seen[icon.URL] {
+			continue
+		}
+		seen[icon.URL] = true
+		candidates = append(candidates, icon)
+	}
+	return candidates, ctx.Err()
+}
+
+// hashCandidate fetch and hash one candidate
+func (h *IconHasher) hashCandidate(ctx context.Context, c IconCandidate) (string, error) {
+	if c.Source == IconSourceFile {
+		return h.fileIconHash(c.URL)
+	}
+	if strings.HasPrefix(c.URL, "data:") {
+		dataURL, err := dataurl.DecodeString(c.URL)
+		if err != nil {
+			return "", err
+		}
+		if !isImageContent(dataURL.MediaType.ContentType()) {
+			return "", fmt.Errorf("content is not a image: %s", dataURL.MediaType.ContentType())
+		}
+		return mmh3Hash32(dataURL.Data), nil
+	}
+
+	data, contentType, _, err := h.fetch(ctx, c.URL)
+	if err != nil {
+		return "", err
+	}
+	if !isImageContent(contentType) {
+		return "", fmt.Errorf("content is not a image: %s", contentType)
+	}
+	return mmh3Hash32(data), nil
+}
+
+// HashAll find every icon candidate of input and hash each one concurrently, results are in order of candidates
+func (h *IconHasher) HashAll(ctx context.Context, input string) ([]IconCandidateHash, error) {
+	candidates, err := h.Candidates(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]IconCandidateHash, len(candidates))
+	done := h.each(ctx, len(candidates), func(i int) {
+		results[i].Hash, results[i].Err = h.hashCandidate(ctx, candidates[i])
+	})
+	for i := range results {
+		results[i].IconCandidate = candidates[i]
+		if !done[i] {
+			results[i].Err = ctx.Err()
+		}
+	}
+	return results, ctx.Err()
+}
+
+// DistinctIconHashes distinct hashes of results in order, failed results are skipped
+func DistinctIconHashes(results []IconCandidateHash) []string {
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.Err != nil || seen[r.Hash] {
+			continue
+		}
+		seen[r.Hash] = true
+		hashes = append(hashes, r.Hash)
+	}
+	return hashes
+}
@@ -0,0 +1,174 @@
+package gofofa
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/vincent-petithory/dataurl"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestExtractIconCandidates(t *testing.T) {
+	pageURL, _ := url.Parse("http://fofa.info/app/index.html")
+	icons, manifests := ExtractIconCandidates([]byte(`<html><head>
+<base href="/static/">
+<base href="/ignored/">
+<link rel="Shortcut  Icon" href="favicon.ico">
+<link rel="icon" sizes="32x32" href="icon-32.png">
+<link rel="apple-touch-icon" sizes="180x180" href="/apple.png">
+<link rel="mask-icon" href="https://cdn.fofa.info/mask.svg" color="#fff">
+<link rel="icon" href="data:image/png;base64,AAAA">
+<link rel="stylesheet" href="style.css">
+<link rel="icon" href="">
+<link rel="manifest" href="site.webmanifest">
+<meta name="msapplication-TileImage" content="/tile.png">
+</head></html>`), pageURL)
+	assert.Equal(t, []IconCandidate{
+		{URL: "http://fofa.info/static/favicon.ico", Source: "shortcut icon"},
+		{URL: "http://fofa.info/static/icon-32.png", Source: "icon", Sizes: "32x32"},
+		{URL: "http://fofa.info/apple.png", Source: "apple-touch-icon", Sizes: "180x180"},
+		{URL: "https://cdn.fofa.info/mask.svg", Source: "mask-icon"},
+		{URL: "data:image/png;base64,AAAA", Source: "icon"},
+		{URL: "http://fofa.info/tile.png", Source: "msapplication-TileImage"},
+	}, icons)
+	assert.Equal(t, []string{"http://fofa.info/static/site.webmanifest"}, manifests)
+
+	// 没有base
+	icons, manifests = ExtractIconCandidates([]byte(`<link rel="icon" href="favicon.png">`), pageURL)
+	assert.Equal(t, []IconCandidate{{URL: "http://fofa.info/app/favicon.png", Source: "icon"}}, icons)
+	assert.Nil(t, manifests)
+}
+
+func TestParseManifestIcons(t *testing.T) {
+	manifestURL, _ := url.Parse("http://fofa.info/static/manifest.json")
+	icons, err := ParseManifestIcons([]byte(`{"name":"a","icons":[{"src":"icon-192.png","sizes":"192x192"},{"src":"/icon-512.png"},{"src":""}]}`), manifestURL)
+	assert.Nil(t, err)
+	assert.Equal(t, []IconCandidate{
+		{URL: "http://fofa.info/static/icon-192.png", Source: IconSourceManifest, Sizes: "192x192"},
+		{URL: "http://fofa.info/icon-512.png", Source: IconSourceManifest},
+	}, icons)
+
+	_, err = ParseManifestIcons([]byte(`<html>`), manifestURL)
+	assert.Error(t, err)
+}
+
+func TestIconHasher_HashAll(t *testing.T) {
+	ico, _ := os.ReadFile("./data/favicon.ico")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<link rel="icon" href="/favicon.png">
+<link rel="apple-touch-icon" href="/apple.png">
+<link rel="icon" href="` + dataurl.New(ico, "image/x-icon").String() + `">
+<link rel="icon" href="/missing.png">
+<link rel="manifest" href="/app.webmanifest">`))
+		case "/app.webmanifest":
+			w.Write([]byte(`{"icons":[{"src":"/favicon.gif","sizes":"64x64"},{"src":"/favicon.png"}]}`))
+		case "/apple.png":
+			http.ServeFile(w, r, "./data/favicon.png")
+		case "/favicon.ico", "/favicon.png", "/favicon.gif":
+			http.ServeFile(w, r, "./data"+r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+	h := NewIconHasher()
+
+	results, err := h.HashAll(ctx, ts.URL)
+	assert.Nil(t, err)
+	var sources []string
+	for _, r := range results {
+		sources = append(sources, r.Source)
+	}
+	assert.Equal(t, []string{"icon", "apple-touch-icon", "icon", "icon", "manifest", "default"}, sources)
+	assert.Equal(t, ts.URL+"/favicon.png", results[0].URL)
+	assert.Equal(t, "-343282923", results[0].Hash)
+	assert.Equal(t, "-343282923", results[1].Hash)
+	assert.Equal(t, "-247388890", results[2].Hash)
+	assert.Error(t, results[3].Err)
+	assert.Equal(t, ts.URL+"/favicon.gif", results[4].URL)
+	assert.Equal(t, "64x64", results[4].Sizes)
+	assert.Equal(t, "-466535725", results[4].Hash)
+	assert.Equal(t, ts.URL+"/favicon.ico", results[5].URL)
+	assert.Equal(t, "-247388890", results[5].Hash)
+	assert.Equal(t, []string{"-343282923", "-247388890", "-466535725"}, DistinctIconHashes(results))
+
+	// icon url
+	results, err = h.HashAll(ctx, ts.URL+"/favicon.gif")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, IconSourceURL, results[0].Source)
+
+	// local file
+	results, err = h.HashAll(ctx, "./data/favicon.ico")
+	assert.Nil(t, err)
+	assert.Equal(t, []IconCandidateHash{{IconCandidate: IconCandidate{URL: "./data/favicon.ico", Source: IconSourceFile}, Hash: "-247388890"}}, results)
+
+	// default manifest and favicon.ico
+	ts1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/site.webmanifest":
+			w.Write([]byte(`{"icons":[{"src":"favicon.png"}]}`))
+		case "/favicon.png":
+			http.ServeFile(w, r, "./data/favicon.png")
+		default:
+			w.Write([]byte(`hello`))
+		}
+	}))
+	defer ts1.Close()
+	results, err = h.HashAll(ctx, ts1.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, IconSourceManifest, results[0].Source)
+	assert.Equal(t, "-343282923", results[0].Hash)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, []string{"-343282923"}, DistinctIconHashes(results))
+
+	_, err = h.HashAll(ctx, "not_exists")
+	assert.Error(t, err)
+	_, err = h.HashAll(ctx, "http://127.0.0.1:55")
+	assert.Error(t, err)
+}
+
+func TestIconHasher_CandidatesRedirect(t *testing.T) {
+	// 相对地址都基于跳转之后的地址
+	to := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<link rel="icon" href="icon.png"><link rel="manifest" href="manifest.json">`))
+		case "/app/icon.png":
+			http.ServeFile(w, r, "./data/favicon.png")
+		case "/app/manifest.json":
+			http.Redirect(w, r, "/static/manifest.json", http.StatusFound)
+		case "/static/manifest.json":
+			w.Write([]byte(`{"icons":[{"src":"m.png"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer to.Close()
+	from := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, to.URL+"/app/", http.StatusFound)
+	}))
+	defer from.Close()
+
+	candidates, err := NewIconHasher().Candidates(context.Background(), from.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, []IconCandidate{
+		{URL: to.URL + "/app/icon.png", Source: "icon"},
+		{URL: to.URL + "/static/m.png", Source: IconSourceManifest},
+		{URL: to.URL + "/favicon.ico", Source: IconSourceDefault},
+	}, candidates)
+
+	hash, iconURL, err := NewIconHasher().Hash(context.Background(), from.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "-343282923", hash)
+	assert.Equal(t, to.URL+"/app/icon.png", iconURL)
+}
@@ -2,6 +2,8 @@ package gofofa
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -10,11 +12,20 @@ import (
 	"github.com/vincent-petithory/dataurl"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIconMaxSize     = 2 * 1024 * 1024 // 2MB
+	defaultIconTimeout     = 10 * time.Second
+	defaultIconConcurrency = 10
+	defaultIconUserAgent   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 )
 
 // mmh3Hash32 generate icon hash
@@ -39,19 +50,113 @@ func isImageContent(contentType string) bool {
 	return false
 }
 
+// IconHasher calc fofa icon hash of local file, remote icon url or web homepage
+type IconHasher struct {
+	httpClient  *http.Client
+	timeout     time.Duration // timeout of default http client
+	insecure    bool          // skip tls verify of default http client
+	maxSize     int64         // max bytes of downloaded content
+	headers     http.Header   // headers of each request
+	logger      *logrus.Logger
+	concurrency int // workers of HashMany and HashAll
+}
+
+// IconHasherOption option of IconHasher
+type IconHasherOption func(h *IconHasher)
+
+// WithIconHTTPClient set http client, timeout and proxy are decided by it, the client is never modified
+func WithIconHTTPClient(httpClient *http.Client) IconHasherOption {
+	return func(h *IconHasher) {
+		h.httpClient = httpClient
+	}
+}
+
+// WithIconTimeout set timeout of each request of default http client, ignored with WithIconHTTPClient
+func WithIconTimeout(timeout time.Duration) IconHasherOption {
+	return func(h *IconHasher) {
+		h.timeout = timeout
+	}
+}
+
+// WithIconInsecure skip tls verify of default http client, ignored with WithIconHTTPClient
+func WithIconInsecure(insecure bool) IconHasherOption {
+	return func(h *IconHasher) {
+		h.insecure = insecure
+	}
+}
+
+// WithIconMaxSize set max bytes of downloaded content, larger icon is rejected and larger page is truncated
+func WithIconMaxSize(maxSize int64) IconHasherOption {
+	return func(h *IconHasher) {
+		h.maxSize = maxSize
+	}
+}
+
+// WithIconHeader set header of each request, such as User-Agent or Cookie
+func WithIconHeader(key, value string) IconHasherOption {
+	return func(h *IconHasher) {
+		h.headers.Set(key, value)
+	}
+}
+
+// WithIconLogger set logger
+func WithIconLogger(logger *logrus.Logger) IconHasherOption {
+	return func(h *IconHasher) {
+		h.logger = logger
+	}
+}
+
+// WithIconConcurrency set workers of HashMany and HashAll
+func WithIconConcurrency(concurrency int) IconHasherOption {
+	return func(h *IconHasher) {
+		h.concurrency = concurrency
+	}
+}
+
+// NewIconHasher create icon hasher, default timeout is 10s and max size is 2MB
+func NewIconHasher(options ...IconHasherOption) *IconHasher {
+	h := &IconHasher{
+		timeout:     defaultIconTimeout,
+		maxSize:     defaultIconMaxSize,
+		headers:     http.Header{},
+		logger:      logrus.StandardLogger(),
+		concurrency: defaultIconConcurrency,
+	}
+	h.headers.Set("User-Agent", defaultIconUserAgent)
+	for _, opt := range options {
+		opt(h)
+	}
+	if h.concurrency < 1 {
+		h.concurrency = 1
+	}
+	if h.httpClient == nil {
+		h.httpClient = &http.Client{
+			Timeout: h.timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: h.insecure},
+			},
+		}
+	}
+	return h
+}
+
+// defaultIconHasher used by IconHash
+var defaultIconHasher = NewIconHasher()
+
 // fileIconHash local file hash
-func fileIconHash(url string) (hash string, err error) {
+func (h *IconHasher) fileIconHash(filename string) (hash string, err error) {
 	var data []byte
 
-	logrus.Debug("load local file:", url)
+	h.logger.Debug("load local file:", filename)
 
-	data, err = os.ReadFile(url)
+	data, err = os.ReadFile(filename)
 	if err != nil {
 		return
 	}
 
 	ct := http.DetectContentType(data)
-	logrus.Debug("local file format:", ct)
+	h.logger.Debug("local file format:", ct)
 
 	if isImageContent(ct) {
 		hash = mmh3Hash32(data)
@@ -63,30 +168,71 @@ func fileIconHash(url string) (hash string, err error) {
 	return
 }
 
-// fetchURLContent fetch content and type from url
-func fetchURLContent(iconUrl string) (data []byte, contentType string, err error) {
-	// fetch url
-	var resp *http.Response
-	resp, err = http.Get(iconUrl)
+// fileIconHash local file hash with default icon hasher
+func fileIconHash(filename string) (hash string, err error) {
+	return defaultIconHasher.fileIconHash(filename)
+}
+
+// get request url, body is read at most maxSize+1 bytes and closed
+func (h *IconHasher) get(ctx context.Context, rawURL string) (resp *http.Response, data []byte, err error) {
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return
+	}
+	for k, v := range h.headers {
+		req.Header[k] = v
+	}
+	resp, err = h.httpClient.Do(req)
 	if err != nil {
 		return
 	}
 
-	// read data
+	// read data, one more byte to check size
 	defer resp.Body.Close()
-	data, err = ioutil.ReadAll(resp.Body)
+	data, err = io.ReadAll(io.LimitReader(resp.Body, h.maxSize+1))
+	return
+}
+
+// fetch fetch content and type from url, finalURL is the url after redirects, relative urls of page are resolved against it
+func (h *IconHasher) fetch(ctx context.Context, iconUrl string) (data []byte, contentType string, finalURL *url.URL, err error) {
+	// fetch url
+	var resp *http.Response
+	resp, data, err = h.get(ctx, iconUrl)
 	if err != nil {
 		return
 	}
+	if resp.Request != nil {
+		finalURL = resp.Request.URL
+	}
+	if finalURL == nil {
+		finalURL, err = url.Parse(iconUrl)
+		if err != nil {
+			return
+		}
+	}
 
 	// check content type by header
 	contentType = resp.Header.Get("Content-type")
-	if len(contentType) > 0 {
-		return
+	if len(contentType) == 0 {
+		// check content type by data
+		contentType = http.DetectContentType(data)
 	}
 
-	// check content type by data
-	contentType = http.DetectContentType(data)
+	if int64(len(data)) > h.maxSize {
+		if isImageContent(contentType) {
+			err = fmt.Errorf("icon is larger than %d bytes", h.maxSize)
+			return
+		}
+		// 页面只需要前面部分
+		data = data[:h.maxSize]
+	}
+	return
+}
+
+// fetchURLContent fetch content and type from url with default icon hasher
+func fetchURLContent(iconUrl string) (data []byte, contentType string, err error) {
+	data, contentType, _, err = defaultIconHasher.fetch(context.Background(), iconUrl)
 	return
 }
 
@@ -137,28 +283,25 @@ tokenize:
 	}
 }
 
-// IconHash
-// if url is a local icon file, then calc the hash
-// if url is remote icon url, the download and calc the hash
-// if url is web homepage, then try to parse favicon url and download it, then calc the hash
-func IconHash(iconUrl string) (hash string, err error) {
+// Hash calc icon hash, returns hash and url/file of the icon
+// if input is a local icon file, then calc the hash
+// if input is remote icon url, the download and calc the hash
+// if input is web homepage, then try to parse favicon url and download it, then calc the hash
+func (h *IconHasher) Hash(ctx context.Context, input string) (hash string, iconURL string, err error) {
 	// check if local file
-	_, err = os.Stat(iconUrl)
+	_, err = os.Stat(input)
 	if err == nil {
 		// 存在
-		return fileIconHash(iconUrl)
+		hash, err = h.fileIconHash(input)
+		return hash, input, err
 	}
-	//// 还有不存在的错误？
-	//if !errors.Is(err, os.ErrNotExist) {
-	//	return
-	//}
 
-	if !strings.Contains(iconUrl, "://") {
+	if !strings.Contains(input, "://") {
 		err = errors.New("icon url is not valid url")
 		return
 	}
 	var u *url.URL
-	u, err = url.Parse(iconUrl)
+	u, err = url.Parse(input)
 	if err != nil {
 		return
 	}
@@ -166,21 +309,29 @@ func IconHash(iconUrl string) (hash string, err error) {
 	// remote url
 	var data []byte
 	var contentType string
-	data, contentType, err = fetchURLContent(iconUrl)
-	if isImageContent(contentType) {
-		hash = mmh3Hash32(data)
-		return
+	var finalURL *url.URL
+	data, contentType, finalURL, err = h.fetch(ctx, input)
+	if err == nil && isImageContent(contentType) {
+		return mmh3Hash32(data), input, nil
 	}
+	// 图标相对于跳转之后的地址
+	if finalURL != nil {
+		u = finalURL
+	}
+	return h.hashPage(ctx, u, data, contentType)
+}
 
+// hashPage calc icon hash of fetched homepage, link icon is tried first, then /favicon.ico
+func (h *IconHasher) hashPage(ctx context.Context, u *url.URL, data []byte, contentType string) (hash string, iconURL string, err error) {
 	// parse icon url
 	var parsedURL string
 	if strings.Contains(contentType, "html") {
-		logrus.Debug("try to parse favicon url")
+		h.logger.Debug("try to parse favicon url")
 		parsedURL = ExtractIconFromHtml(data)
 	}
 
 	if len(parsedURL) > 0 {
-		logrus.Debug("parsed favicon url from html:", parsedURL)
+		h.logger.Debug("parsed favicon url from html:", parsedURL)
 
 		// inner base64
 		if strings.HasPrefix(parsedURL, "data:image") {
@@ -190,32 +341,135 @@ func IconHash(iconUrl string) (hash string, err error) {
 				return
 			}
 			if isImageContent(dataURL.MediaType.ContentType()) {
-				hash = mmh3Hash32(dataURL.Data)
-				return
+				return mmh3Hash32(dataURL.Data), "data:" + dataURL.MediaType.ContentType(), nil
 			}
 		}
 
 		if rel, errP := url.Parse(parsedURL); errP == nil {
-			newURL := u.ResolveReference(rel)
-			data, contentType, err = fetchURLContent(newURL.String())
-			if isImageContent(contentType) {
-				hash = mmh3Hash32(data)
-				return
+			newURL := u.ResolveReference(rel).String()
+			data, contentType, _, err = h.fetch(ctx, newURL)
+			if err == nil && isImageContent(contentType) {
+				return mmh3Hash32(data), newURL, nil
 			}
 		} else {
-			logrus.Debug("parsed favicon url is not valid:", errP)
+			h.logger.Debug("parsed favicon url is not valid:", errP)
 		}
 	}
 
 	// just try default favicon.ico
-	logrus.Debug("try default favicon.ico")
+	h.logger.Debug("try default favicon.ico")
 	defaultIconURL := u.Scheme + "://" + u.Host + "/favicon.ico"
-	data, contentType, err = fetchURLContent(defaultIconURL)
-	if isImageContent(contentType) {
-		hash = mmh3Hash32(data)
-		return
+	data, contentType, _, err = h.fetch(ctx, defaultIconURL)
+	if err == nil && isImageContent(contentType) {
+		return mmh3Hash32(data), defaultIconURL, nil
+	}
+	if ctx.Err() != nil {
+		return "", "", ctx.Err()
 	}
 
 	err = errors.New("can not find any icon")
 	return
 }
+
+// ErrIconNotFetched remote icon is found but fetch is not allowed
+var ErrIconNotFetched = errors.New("remote icon is not fetched")
+
+// HashBody calc icon hash of html body already fetched, such as body field of fofa results
+// link icon is resolved against pageURL, /favicon.ico is used if no link icon, inline data:image icon is decoded offline
+// remote icon is fetched only if fetch is true, otherwise ErrIconNotFetched is returned with the icon url
+func (h *IconHasher) HashBody(ctx context.Context, body []byte, pageURL string, fetch bool) (hash string, iconURL string, err error) {
+	href := strings.TrimSpace(ExtractIconFromHtml(body))
+	if strings.HasPrefix(href, "data:") {
+		var dataURL *dataurl.DataURL
+		dataURL, err = dataurl.DecodeString(href)
+		if err != nil {
+			return
+		}
+		contentType := dataURL.MediaType.ContentType()
+		if !isImageContent(contentType) {
+			return "", "", fmt.Errorf("content is not a image: %s", contentType)
+		}
+		return mmh3Hash32(dataURL.Data), "data:" + contentType, nil
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return
+	}
+	iconURL = u.Scheme + "://" + u.Host + "/favicon.ico"
+	if len(href) > 0 {
+		rel, errP := url.Parse(href)
+		if errP != nil {
+			return "", "", errP
+		}
+		iconURL = u.ResolveReference(rel).String()
+	}
+	if !fetch {
+		return "", iconURL, ErrIconNotFetched
+	}
+
+	data, contentType, _, err := h.fetch(ctx, iconURL)
+	if err != nil {
+		return "", iconURL, err
+	}
+	if !isImageContent(contentType) {
+		return "", iconURL, fmt.Errorf("content is not a image: %s", contentType)
+	}
+	return mmh3Hash32(data), iconURL, nil
+}
+
+// IconHashResult result of HashMany
+type IconHashResult struct {
+	Input   string // local file or url
+	IconURL string // url or file of the icon, data:<type> for inline icon
+	Hash    string
+	Err     error
+}
+
+// HashMany calc icon hashes of inputs concurrently, results are in the same order as inputs
+func (h *IconHasher) HashMany(ctx context.Context, inputs []string) []IconHashResult {
+	results := make([]IconHashResult, len(inputs))
+	done := h.each(ctx, len(inputs), func(i int) {
+		r := IconHashResult{Input: inputs[i]}
+		r.Hash, r.IconURL, r.Err = h.Hash(ctx, inputs[i])
+		results[i] = r
+	})
+	for i := range results {
+		if !done[i] {
+			results[i] = IconHashResult{Input: inputs[i], Err: ctx.Err()}
+		}
+	}
+	return results
+}
+
+// each call f with 0..n-1 by concurrency workers, returns which indexes are called
+// indexes are not called any more after ctx is done
+func (h *IconHasher) each(ctx context.Context, n int, f func(i int)) []bool {
+	done := make([]bool, n)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < h.concurrency && i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		done[i] = true
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return done
+}
+
+// IconHash calc icon hash with default icon hasher, see IconHasher.Hash
+func IconHash(iconUrl string) (hash string, err error) {
+	hash, _, err = defaultIconHasher.Hash(context.Background(), iconUrl)
+	return
+}
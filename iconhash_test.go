@@ -1,12 +1,15 @@
 package gofofa
 
 import (
+	"context"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/vincent-petithory/dataurl"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 var (
@@ -418,3 +421,141 @@ func TestIconHash(t *testing.T) {
 	assert.Contains(t, err.Error(), "unterminated parameter sequence")
 
 }
+
+func TestIconHasher(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/header.ico" {
+			if r.Header.Get("Cookie") != "a=b" || r.UserAgent() != "test" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			r.URL.Path = "/favicon.ico"
+		}
+		if r.URL.Path == "/slow.ico" {
+			time.Sleep(200 * time.Millisecond)
+			r.URL.Path = "/favicon.ico"
+		}
+		faviconOkHandler(w, r)
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	h := NewIconHasher()
+	hash, iconURL, err := h.Hash(ctx, ts.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "-247388890", hash)
+	assert.Equal(t, ts.URL+"/favicon.ico", iconURL)
+
+	hash, iconURL, err = h.Hash(ctx, "./data/favicon.ico")
+	assert.Nil(t, err)
+	assert.Equal(t, "./data/favicon.ico", iconURL)
+
+	// headers
+	_, contentType, _, err := h.fetch(ctx, ts.URL+"/header.ico")
+	assert.Nil(t, err)
+	assert.NotContains(t, contentType, "image")
+	hash, iconURL, err = NewIconHasher(WithIconHeader("Cookie", "a=b"), WithIconHeader("User-Agent", "test")).
+		Hash(ctx, ts.URL+"/header.ico")
+	assert.Nil(t, err)
+	assert.Equal(t, "-247388890", hash)
+	assert.Equal(t, ts.URL+"/header.ico", iconURL)
+
+	// max size, page is truncated and icon is rejected
+	_, _, err = NewIconHasher(WithIconMaxSize(100)).Hash(ctx, ts.URL+"/favicon.ico")
+	assert.Contains(t, err.Error(), "can not find any icon")
+	_, _, _, err = NewIconHasher(WithIconMaxSize(100)).fetch(ctx, ts.URL+"/favicon.ico")
+	assert.Contains(t, err.Error(), "larger than 100 bytes")
+	data, _, _, err := NewIconHasher(WithIconMaxSize(100)).fetch(ctx, ts.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, len(data))
+
+	// timeout
+	_, _, _, err = NewIconHasher(WithIconTimeout(50*time.Millisecond)).fetch(ctx, ts.URL+"/slow.ico")
+	assert.Error(t, err)
+	hash, _, err = NewIconHasher(WithIconHTTPClient(&http.Client{}), WithIconInsecure(true), WithIconLogger(logrus.New())).
+		Hash(ctx, ts.URL+"/slow.ico")
+	assert.Nil(t, err)
+	assert.Equal(t, "-247388890", hash)
+}
+
+func TestNewIconHasher_Options(t *testing.T) {
+	// 选项顺序不影响结果
+	for _, h := range []*IconHasher{
+		NewIconHasher(WithIconTimeout(time.Second), WithIconInsecure(true)),
+		NewIconHasher(WithIconInsecure(true), WithIconTimeout(time.Second)),
+	} {
+		assert.Equal(t, time.Second, h.httpClient.Timeout)
+		assert.True(t, h.httpClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+	}
+	assert.Equal(t, defaultIconTimeout, NewIconHasher().httpClient.Timeout)
+
+	// 不修改调用方的client
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport}
+	h := NewIconHasher(WithIconTimeout(time.Second), WithIconHTTPClient(client), WithIconInsecure(true))
+	assert.Equal(t, client, h.httpClient)
+	assert.Equal(t, time.Duration(0), client.Timeout)
+	assert.Nil(t, transport.TLSClientConfig)
+}
+
+func TestIconHasher_HashMany(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(faviconOkHandler))
+	defer ts.Close()
+
+	inputs := []string{ts.URL, "./data/aaa.ico", ts.URL + "/favicon.png", "./data/favicon.ico"}
+	results := NewIconHasher(WithIconConcurrency(2)).HashMany(context.Background(), inputs)
+	assert.Equal(t, 4, len(results))
+	for i, r := range results {
+		assert.Equal(t, inputs[i], r.Input)
+	}
+	assert.Equal(t, "-247388890", results[0].Hash)
+	assert.Equal(t, ts.URL+"/favicon.ico", results[0].IconURL)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, "", results[1].Hash)
+	assert.Equal(t, "-343282923", results[2].Hash)
+	assert.Equal(t, "-247388890", results[3].Hash)
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = NewIconHasher().HashMany(ctx, []string{ts.URL, ts.URL})
+	assert.Equal(t, 2, len(results))
+	assert.ErrorIs(t, results[1].Err, context.Canceled)
+}
+
+func TestIconHasher_HashBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(faviconOkHandler))
+	defer ts.Close()
+	ctx := context.Background()
+	h := NewIconHasher()
+
+	// inline icon is decoded offline
+	ico, _ := os.ReadFile("./data/favicon.ico")
+	body := `<link rel="icon" href="` + dataurl.New(ico, "image/x-icon").String() + `">`
+	hash, iconURL, err := h.HashBody(ctx, []byte(body), "http://127.0.0.1:1", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "-247388890", hash)
+	assert.Equal(t, "data:image/x-icon", iconURL)
+	_, _, err = h.HashBody(ctx, []byte(`<link rel="icon" href="data:text/plain;base64,YWJj">`), ts.URL, false)
+	assert.Error(t, err)
+
+	// remote icon is resolved against page url
+	body = `<link rel="shortcut icon" href="../favicon.png">`
+	hash, iconURL, err = h.HashBody(ctx, []byte(body), ts.URL+"/app/index.html", false)
+	assert.ErrorIs(t, err, ErrIconNotFetched)
+	assert.Equal(t, "", hash)
+	assert.Equal(t, ts.URL+"/favicon.png", iconURL)
+	hash, iconURL, err = h.HashBody(ctx, []byte(body), ts.URL+"/app/index.html", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "-343282923", hash)
+	assert.Equal(t, ts.URL+"/favicon.png", iconURL)
+
+	// no link icon
+	hash, iconURL, err = h.HashBody(ctx, []byte("<html></html>"), ts.URL+"/app/", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "-247388890", hash)
+	assert.Equal(t, ts.URL+"/favicon.ico", iconURL)
+	_, iconURL, err = h.HashBody(ctx, []byte(`<link rel="icon" href="/aaa.png">`), ts.URL, true)
+	assert.Error(t, err)
+	assert.Equal(t, ts.URL+"/aaa.png", iconURL)
+}
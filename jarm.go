@@ -0,0 +1,468 @@
+package gofofa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultJARMTimeout = 5 * time.Second
+	jarmMaxRead        = 1484 // bytes of server hello read by jarm.py
+)
+
+// jarmEmpty fingerprint when no tls handshake succeeds
+var jarmEmpty = strings.Repeat("0", 62)
+
+// order of cipher suites, alpns and supported versions in client hello
+const (
+	jarmForward    = "FORWARD"
+	jarmReverse    = "REVERSE"
+	jarmTopHalf    = "TOP_HALF"
+	jarmBottomHalf = "BOTTOM_HALF"
+	jarmMiddleOut  = "MIDDLE_OUT"
+)
+
+// jarmCiphers cipher suites of client hello, in order of jarm.py
+var jarmCiphers = []uint16{
+	0x0016, 0x0033, 0x0067, 0xc09e, 0xc0a2, 0x009e, 0x0039, 0x006b, 0xc09f, 0xc0a3, 0x009f, 0x0045, 0x00be, 0x0088,
+	0x00c4, 0x009a, 0xc008, 0xc009, 0xc023, 0xc0ac, 0xc0ae, 0xc02b, 0xc00a, 0xc024, 0xc0ad, 0xc0af, 0xc02c, 0xc072,
+	0xc073, 0xcca9, 0x1302, 0x1301, 0xcc14, 0xc007, 0xc012, 0xc013, 0xc027, 0xc02f, 0xc014, 0xc028, 0xc030, 0xc060,
+	0xc061, 0xc076, 0xc077, 0xcca8, 0x1305, 0x1304, 0x1303, 0xcc13, 0xc011, 0x000a, 0x002f, 0x003c, 0xc09c, 0xc0a0,
+	0x009c, 0x0035, 0x003d, 0xc09d, 0xc0a1, 0x009d, 0x0041, 0x00ba, 0x0084, 0x00c0, 0x0007, 0x0004, 0x0005,
+}
+
+// jarmCipherIndex cipher suites in order of fingerprint bytes
+var jarmCipherIndex = []uint16{
+	0x0004, 0x0005, 0x0007, 0x000a, 0x0016, 0x002f, 0x0033, 0x0035, 0x0039, 0x003c, 0x003d, 0x0041, 0x0045, 0x0067,
+	0x006b, 0x0084, 0x0088, 0x009a, 0x009c, 0x009d, 0x009e, 0x009f, 0x00ba, 0x00be, 0x00c0, 0x00c4, 0xc007, 0xc008,
+	0xc009, 0xc00a, 0xc011, 0xc012, 0xc013, 0xc014, 0xc023, 0xc024, 0xc027, 0xc028, 0xc02b, 0xc02c, 0xc02f, 0xc030,
+	0xc060, 0xc061, 0xc072, 0xc073, 0xc076, 0xc077, 0xc09c, 0xc09d, 0xc09e, 0xc09f, 0xc0a0, 0xc0a1, 0xc0a2, 0xc0a3,
+	0xc0ac, 0xc0ad, 0xc0ae, 0xc0af, 0xcc13, 0xcc14, 0xcca8, 0xcca9, 0x1301, 0x1302, 0x1303, 0x1304, 0x1305,
+}
+
+var (
+	jarmALPNs     = []string{"http/0.9", "http/1.0", "http/1.1", "spdy/1", "spdy/2", "spdy/3", "h2", "h2c", "hq"}
+	jarmRareALPNs = []string{"http/0.9", "http/1.0", "spdy/1", "spdy/2", "spdy/3", "h2c", "hq"}
+)
+
+// jarmProbe one of the client hellos of jarm
+type jarmProbe struct {
+	version     uint16 // version of record and client hello
+	noTLS13     bool   // tls 1.3 cipher suites are removed
+	cipherOrder string
+	grease      bool
+	rareALPN    bool
+	support     uint16 // max version of supported_versions extension, 0 means no extension
+	extOrder    string // order of alpns and supported versions
+}
+
+// jarmProbes the 10 client hellos in order of jarm.py
+var jarmProbes = []jarmProbe{
+	{version: 0x0303, cipherOrder: jarmForward, support: 0x0303, extOrder: jarmReverse},
+	{version: 0x0303, cipherOrder: jarmReverse, support: 0x0303, extOrder: jarmForward},
+	{version: 0x0303, cipherOrder: jarmTopHalf, extOrder: jarmForward},
+	{version: 0x0303, cipherOrder: jarmBottomHalf, rareALPN: true, extOrder: jarmForward},
+	{version: 0x0303, cipherOrder: jarmMiddleOut, grease: true, rareALPN: true, extOrder: jarmReverse},
+	{version: 0x0302, cipherOrder: jarmForward, extOrder: jarmForward},
+	{version: 0x0304, cipherOrder: jarmForward, support: 0x0304, extOrder: jarmReverse},
+	{version: 0x0304, cipherOrder: jarmReverse, support: 0x0304, extOrder: jarmForward},
+	{version: 0x0304, noTLS13: true, cipherOrder: jarmForward, support: 0x0304, extOrder: jarmForward},
+	{version: 0x0304, cipherOrder: jarmMiddleOut, grease: true, support: 0x0304, extOrder: jarmReverse},
+}
+
+// jarmMung reorder items as cipher_mung of jarm.py
+func jarmMung(items [][]byte, order string) [][]byte {
+	n := len(items)
+	var out [][]byte
+	switch order {
+	case jarmReverse:
+		for i := n - 1; i >= 0; i-- {
+			out = append(out, items[i])
+		}
+	case jarmBottomHalf:
+		out = append(out, items[n/2+n%2:]...)
+	case jarmTopHalf:
+		if n%2 == 1 {
+			out = append(out, items[n/2])
+		}
+		out = append(out, jarmMung(jarmMung(items, jarmReverse), jarmBottomHalf)...)
+	case jarmMiddleOut:
+		middle := n / 2
+		if n%2 == 1 {
+			out = append(out, items[middle])
+			for i := 1; i <= middle; i++ {
+				out = append(out, items[middle+i], items[middle-i])
+			}
+		} else {
+			for i := 1; i <= middle; i++ {
+				out = append(out, items[middle-1+i], items[middle-i])
+			}
+		}
+	default:
+		out = items
+	}
+	return out
+}
+
+// jarmGrease random grease value
+func jarmGrease() []byte {
+	b := make([]byte, 1)
+	rand.Read(b)
+	v := b[0]&0xf0 | 0x0a
+	return []byte{v, v}
+}
+
+// jarmRandom random bytes
+func jarmRandom(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// appendUint16 append big endian uint16
+func appendUint16(b []byte, v int) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// jarmExtensions extensions of client hello with length prefix
+func jarmExtensions(p jarmProbe, host string) []byte {
+	var ext []byte
+	if p.grease {
+		ext = append(ext, jarmGrease()...)
+		ext = append(ext, 0x00, 0x00)
+	}
+
+	// server_name
+	ext = append(ext, 0x00, 0x00)
+	ext = appendUint16(ext, len(host)+5)
+	ext = appendUint16(ext, len(host)+3)
+	ext = append(ext, 0x00)
+	ext = appendUint16(ext, len(host))
+	ext = append(ext, host...)
+
+	ext = append(ext, 0x00, 0x17, 0x00, 0x00)       // extended_master_secret
+	ext = append(ext, 0x00, 0x01, 0x00, 0x01, 0x01) // max_fragment_length
+	ext = append(ext, 0xff, 0x01, 0x00, 0x01, 0x00) // renegotiation_info
+
+	// supported_groups
+	ext = append(ext, 0x00, 0x0a, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19)
+	ext = append(ext, 0x00, 0x0b, 0x00, 0x02, 0x01, 0x00) // ec_point_formats
+	ext = append(ext, 0x00, 0x23, 0x00, 0x00)             // session_ticket
+
+	// application_layer_protocol_negotiation
+	names := jarmALPNs
+	if p.rareALPN {
+		names = jarmRareALPNs
+	}
+	var alpns [][]byte
+	for _, name := range names {
+		alpns = append(alpns, append([]byte{byte(len(name))}, name...))
+	}
+	var alpn []byte
+	for _, a := range jarmMung(alpns, p.extOrder) {
+		alpn = append(alpn, a...)
+	}
+	ext = append(ext, 0x00, 0x10)
+	ext = appendUint16(ext, len(alpn)+2)
+	ext = appendUint16(ext, len(alpn))
+	ext = append(ext, alpn...)
+
+	// signature_algorithms
+	ext = append(ext, 0x00, 0x0d, 0x00, 0x14, 0x00, 0x12, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01, 0x05, 0x03,
+		0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01, 0x02, 0x01)
+
+	// key_share
+	var share []byte
+	if p.grease {
+		share = append(share, jarmGrease()...)
+		share = append(share, 0x00, 0x01, 0x00)
+	}
+	share = append(share, 0x00, 0x1d, 0x00, 0x20)
+	share = append(share, jarmRandom(32)...)
+	ext = append(ext, 0x00, 0x33)
+	ext = appendUint16(ext, len(share)+2)
+	ext = appendUint16(ext, len(share))
+	ext = append(ext, share...)
+
+	ext = append(ext, 0x00, 0x2d, 0x00, 0x02, 0x01, 0x01) // psk_key_exchange_modes
+
+	// supported_versions
+	if p.support > 0 {
+		var versions [][]byte
+		for v := 0x0301; v <= int(p.support); v++ {
+			versions = append(versions, []byte{byte(v >> 8), byte(v)})
+		}
+		versions = jarmMung(versions, p.extOrder)
+		if p.grease {
+			versions = append([][]byte{jarmGrease()}, versions...)
+		}
+		ext = append(ext, 0x00, 0x2b)
+		ext = appendUint16(ext, len(versions)*2+1)
+		ext = append(ext, byte(len(versions)*2))
+		for _, v := range versions {
+			ext = append(ext, v...)
+		}
+	}
+
+	return append(appendUint16(nil, len(ext)), ext...)
+}
+
+// jarmClientHello tls record of client hello
+func jarmClientHello(p jarmProbe, host string) []byte {
+	var ciphers [][]byte
+	for _, c := range jarmCiphers {
+		if p.noTLS13 && c>>8 == 0x13 {
+			continue
+		}
+		ciphers = append(ciphers, []byte{byte(c >> 8), byte(c)})
+	}
+	ciphers = jarmMung(ciphers, p.cipherOrder)
+	if p.grease {
+		ciphers = append([][]byte{jarmGrease()}, ciphers...)
+	}
+
+	// tls 1.3 用1.2的格式，版本在supported_versions里
+	version := p.version
+	recordVersion := p.version
+	if p.version == 0x0304 {
+		version = 0x0303
+		recordVersion = 0x0301
+	}
+
+	hello := appendUint16(nil, int(version))
+	hello = append(hello, jarmRandom(32)...)
+	hello = append(hello, 32)
+	hello = append(hello, jarmRandom(32)...)
+	hello = appendUint16(hello, len(ciphers)*2)
+	for _, c := range ciphers {
+		hello = append(hello, c...)
+	}
+	hello = append(hello, 0x01, 0x00) // compression methods
+	hello = append(hello, jarmExtensions(p, host)...)
+
+	handshake := []byte{0x01, 0x00}
+	handshake = appendUint16(handshake, len(hello))
+	handshake = append(handshake, hello...)
+
+	record := []byte{0x16}
+	record = appendUint16(record, int(recordVersion))
+	record = appendUint16(record, len(handshake))
+	return append(record, handshake...)
+}
+
+// jarmParseServerHello cipher|version|alpn|extensions of server hello, ||| if not a server hello
+func jarmParseServerHello(data []byte) string {
+	if len(data) < 44 || data[0] != 0x16 || data[5] != 0x02 {
+		return "|||"
+	}
+	helloLength := int(binary.BigEndian.Uint16(data[3:5]))
+	counter := int(data[43])
+	if len(data) < counter+46 {
+		return "|||"
+	}
+	return hex.EncodeToString(data[counter+44:counter+46]) + "|" +
+		hex.EncodeToString(data[9:11]) + "|" +
+		jarmExtensionInfo(data, counter, helloLength)
+}
+
+// jarmExtensionInfo alpn|extension types of server hello
+func jarmExtensionInfo(data []byte, counter int, helloLength int) string {
+	if len(data) < counter+53 || data[counter+47] == 11 {
+		return "|"
+	}
+	if string(data[counter+50:counter+53]) == "\x0e\xac\x0b" || (len(data) >= 85 && string(data[82:85]) == "\x0f\xf0\x0b") {
+		return "|"
+	}
+	if counter+42 >= helloLength {
+		return "|"
+	}
+
+	count := counter + 49
+	maximum := int(binary.BigEndian.Uint16(data[counter+47:counter+49])) + count - 1
+	var types []string
+	var alpn string
+	foundALPN := false
+	for count < maximum {
+		if count+4 > len(data) {
+			return "|"
+		}
+		extType := data[count : count+2]
+		length := int(binary.BigEndian.Uint16(data[count+2 : count+4]))
+		if count+4+length > len(data) {
+			return "|"
+		}
+		value := data[count+4 : count+4+length]
+		if string(extType) == "\x00\x10" && !foundALPN {
+			foundALPN = true
+			if len(value) > 3 {
+				alpn = string(value[3:])
+			}
+		}
+		types = append(types, hex.EncodeToString(extType))
+		count += length + 4
+	}
+	return alpn + "|" + strings.Join(types, "-")
+}
+
+// jarmHash fuzzy hash of raw results of the probes
+func jarmHash(raws []string) string {
+	empty := true
+	for _, raw := range raws {
+		if raw != "|||" {
+			empty = false
+		}
+	}
+	if empty {
+		return jarmEmpty
+	}
+
+	var fuzzy strings.Builder
+	var alpnAndExt strings.Builder
+	for _, raw := range raws {
+		components := strings.SplitN(raw, "|", 4)
+		for len(components) < 4 {
+			components = append(components, "")
+		}
+
+		// cipher
+		if len(components[0]) == 0 {
+			fuzzy.WriteString("00")
+		} else {
+			index := len(jarmCipherIndex) + 1
+			for i, c := range jarmCipherIndex {
+				if fmt.Sprintf("%04x", c) == components[0] {
+					index = i + 1
+					break
+				}
+			}
+			fmt.Fprintf(&fuzzy, "%02x", index)
+		}
+
+		// version
+		if len(components[1]) == 4 && components[1][3] >= '0' && components[1][3] <= '5' {
+			fuzzy.WriteByte("abcdef"[components[1][3]-'0'])
+		} else {
+			fuzzy.WriteByte('0')
+		}
+
+		alpnAndExt.WriteString(components[2])
+		alpnAndExt.WriteString(components[3])
+	}
+	sum := sha256.Sum256([]byte(alpnAndExt.String()))
+	return fuzzy.String() + hex.EncodeToString(sum[:])[:32]
+}
+
+// JARMOption option of JARM
+type JARMOption func(o *jarmOptions)
+
+type jarmOptions struct {
+	timeout time.Duration
+	dialer  *net.Dialer
+}
+
+// WithJARMTimeout set timeout of each probe, default is 5s
+func WithJARMTimeout(timeout time.Duration) JARMOption {
+	return func(o *jarmOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithJARMDialer set dialer of probes, such as local address
+func WithJARMDialer(dialer *net.Dialer) JARMOption {
+	return func(o *jarmOptions) {
+		o.dialer = dialer
+	}
+}
+
+// jarmSend send client hello and read the first record of response, connected reports whether dial succeeded
+// errors except dial, write and read timeout are treated as no response
+func jarmSend(ctx context.Context, o *jarmOptions, hostport string, payload []byte) (data []byte, connected bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	conn, err := o.dialer.DialContext(ctx, "tcp", hostport)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err = conn.Write(payload); err != nil {
+		return nil, true, err
+	}
+
+	// 读完第一个record，最多1484字节
+	data = make([]byte, jarmMaxRead)
+	n, err := io.ReadAtLeast(conn, data, 5)
+	if err != nil {
+		if isTimeout(err) {
+			return nil, true, err
+		}
+		return data[:n], true, nil
+	}
+	want := 5 + int(binary.BigEndian.Uint16(data[3:5]))
+	if want > jarmMaxRead {
+		want = jarmMaxRead
+	}
+	if n < want {
+		m, _ := io.ReadFull(conn, data[n:want])
+		n += m
+	}
+	return data[:n], true, nil
+}
+
+// isTimeout whether err is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// JARM active tls fingerprint of hostport, the 10 client hellos of salesforce/jarm are sent one by one
+// hash is 62 chars, all zeros means no tls handshake succeeds or the server stops responding
+// error is returned when hostport can not be connected
+func JARM(ctx context.Context, hostport string, options ...JARMOption) (string, error) {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", err
+	}
+	o := &jarmOptions{
+		timeout: defaultJARMTimeout,
+		dialer:  &net.Dialer{},
+	}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	raws := make([]string, 0, len(jarmProbes))
+	connected := false
+	for _, p := range jarmProbes {
+		data, ok, err := jarmSend(ctx, o, hostport, jarmClientHello(p, host))
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		connected = connected || ok
+		if err != nil {
+			if !connected && (isTimeout(err) || len(raws) == len(jarmProbes)-1) {
+				return "", fmt.Errorf("connect to %s failed: %w", hostport, err)
+			}
+			// 和jarm.py一样，超时则整个指纹为空
+			if isTimeout(err) {
+				return jarmEmpty, nil
+			}
+			raws = append(raws, "|||")
+			continue
+		}
+		raws = append(raws, jarmParseServerHello(data))
+	}
+	return jarmHash(raws), nil
+}
@@ -0,0 +1,152 @@
+package gofofa
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJarmMung(t *testing.T) {
+	items := func(s string) [][]byte {
+		var out [][]byte
+		for _, c := range s {
+			out = append(out, []byte{byte(c)})
+		}
+		return out
+	}
+	join := func(items [][]byte) string {
+		var s string
+		for _, b := range items {
+			s += string(b)
+		}
+		return s
+	}
+	tests := []struct {
+		order string
+		odd   string
+		even  string
+	}{
+		{jarmForward, "12345", "1234"},
+		{jarmReverse, "54321", "4321"},
+		{jarmBottomHalf, "45", "34"},
+		{jarmTopHalf, "321", "21"},
+		{jarmMiddleOut, "34251", "3241"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.odd, join(jarmMung(items("12345"), tt.order)), tt.order)
+		assert.Equal(t, tt.even, join(jarmMung(items("1234"), tt.order)), tt.order)
+	}
+}
+
+func TestJarmClientHello(t *testing.T) {
+	for _, p := range jarmProbes {
+		data := jarmClientHello(p, "fofa.info")
+		// record和handshake的长度
+		assert.Equal(t, byte(0x16), data[0])
+		assert.Equal(t, len(data)-5, int(data[3])<<8|int(data[4]))
+		assert.Equal(t, byte(0x01), data[5])
+		assert.Equal(t, len(data)-9, int(data[7])<<8|int(data[8]))
+		assert.Contains(t, string(data), "\x00\x09fofa.info")
+	}
+	assert.Equal(t, "\x16\x03\x01", string(jarmClientHello(jarmProbes[6], "a")[:3]))
+	assert.Equal(t, "\x16\x03\x02", string(jarmClientHello(jarmProbes[5], "a")[:3]))
+}
+
+func TestJarmParseServerHello(t *testing.T) {
+	ext := "\xff\x01\x00\x01\x00" + "\x00\x10\x00\x05\x00\x03\x02h2" + "\x00\x0b\x00\x02\x01\x00"
+	hello := "\x03\x03" + strings.Repeat("r", 32) + "\x00" + "\xc0\x2f" + "\x00" + string([]byte{0, byte(len(ext))}) + ext
+	handshake := "\x02\x00" + string([]byte{0, byte(len(hello))}) + hello
+	data := "\x16\x03\x03" + string([]byte{0, byte(len(handshake))}) + handshake
+	assert.Equal(t, "c02f|0303|h2|ff01-0010-000b", jarmParseServerHello([]byte(data)))
+
+	// alert
+	assert.Equal(t, "|||", jarmParseServerHello([]byte("\x15\x03\x03\x00\x02\x02\x28")))
+	assert.Equal(t, "|||", jarmParseServerHello([]byte("HTTP/1.1 400 Bad Request\r\n")))
+	assert.Equal(t, "|||", jarmParseServerHello(nil))
+	// 扩展被截断
+	assert.Equal(t, "c02f|0303||", jarmParseServerHello([]byte(data[:len(data)-3])))
+}
+
+func TestJarmHash(t *testing.T) {
+	raws := make([]string, 10)
+	for i := range raws {
+		raws[i] = "|||"
+	}
+	assert.Equal(t, strings.Repeat("0", 62), jarmHash(raws))
+
+	raws[0] = "c02f|0303|h2|ff01-0000-0010"
+	raws[9] = "1301|0303||002b-0033"
+	assert.Equal(t, "29d00000000000000000000000041d002ccfafe58d3b39c3d9e26551ab0c39", jarmHash(raws))
+
+	// 未知cipher
+	raws[9] = "ffff|0301||"
+	assert.Equal(t, "46b", jarmHash(raws)[27:30])
+}
+
+func TestJARM(t *testing.T) {
+	ctx := context.Background()
+	// 握手失败的日志不输出
+	newServer := func(config *tls.Config) *httptest.Server {
+		ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+		ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+		ts.TLS = config
+		ts.StartTLS()
+		return ts
+	}
+	ts := newServer(nil)
+	defer ts.Close()
+	ts12 := newServer(&tls.Config{MaxVersion: tls.VersionTLS12})
+	defer ts12.Close()
+
+	hash, err := JARM(ctx, ts.Listener.Addr().String(), WithJARMTimeout(2*time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, hash, 62)
+	assert.NotEqual(t, jarmEmpty, hash)
+	// 随机数不影响结果
+	hash2, err := JARM(ctx, ts.Listener.Addr().String())
+	assert.Nil(t, err)
+	assert.Equal(t, hash, hash2)
+
+	hash12, err := JARM(ctx, ts12.Listener.Addr().String())
+	assert.Nil(t, err)
+	assert.Len(t, hash12, 62)
+	assert.NotEqual(t, hash, hash12)
+
+	// 不是tls
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	hash, err = JARM(ctx, plain.Listener.Addr().String())
+	assert.Nil(t, err)
+	assert.Equal(t, jarmEmpty, hash)
+
+	// 不响应
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	hash, err = JARM(ctx, ln.Addr().String(), WithJARMTimeout(100*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, jarmEmpty, hash)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = JARM(ctx, addr)
+	assert.Error(t, err)
+	_, err = JARM(ctx, "127.0.0.1")
+	assert.Error(t, err)
+}
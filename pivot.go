@@ -0,0 +1,432 @@
+package gofofa
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// node types of pivot graph
+const (
+	PivotNodeIP     = "ip"
+	PivotNodeCert   = "cert"      // subject common name of certificate
+	PivotNodeDomain = "domain"    // registrable domain
+	PivotNodeIcon   = "icon_hash" // icon hash
+)
+
+// PivotNode node of pivot graph
+type PivotNode struct {
+	ID    string            `json:"id"` // type:value
+	Type  string            `json:"type"`
+	Value string            `json:"value"`
+	Depth int               `json:"depth"`           // 0 for seeds
+	Attrs map[string]string `json:"attrs,omitempty"` // such as org and ports of ip
+}
+
+// PivotEdge undirected edge of pivot graph, Source is the node which found Target
+type PivotEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`   // such as ip-cert, domain-icon_hash
+	Weight int    `json:"weight"` // times the relation is seen
+}
+
+// PivotGraph graph built by Pivot
+type PivotGraph struct {
+	Nodes   []PivotNode `json:"nodes"`
+	Links   []PivotEdge `json:"links"`
+	Queries int         `json:"queries"`           // queries sent
+	Stopped string      `json:"stopped,omitempty"` // why crawl stopped early: max nodes, max queries or budget
+}
+
+// PivotOptions options of Pivot
+type PivotOptions struct {
+	Depth      int  // rounds of crawling, default is 1
+	Size       int  // size of each query, default is 100
+	MaxNodes   int  // stop when so many nodes are found, 0 means no limit
+	MaxQueries int  // stop when so many queries are sent, 0 means no limit
+	Full       bool // search result for over a year
+}
+
+// ParsePivotSeed parse seed like ip:1.2.3.4, domain:example.com, cert:example.com or icon_hash:-123
+func ParsePivotSeed(seed string) (PivotNode, error) {
+	typ, value, ok := strings.Cut(strings.TrimSpace(seed), ":")
+	value = strings.TrimSpace(value)
+	if !ok || len(value) == 0 {
+		return PivotNode{}, fmt.Errorf("invalid seed %q, should be type:value", seed)
+	}
+	switch typ {
+	case PivotNodeIP:
+		if net.ParseIP(value) == nil {
+			return PivotNode{}, fmt.Errorf("invalid ip of seed: %s", value)
+		}
+	case PivotNodeDomain:
+		value = strings.ToLower(value)
+	case PivotNodeCert, PivotNodeIcon:
+	case "icon":
+		typ = PivotNodeIcon
+	default:
+		return PivotNode{}, fmt.Errorf("unknown seed type: %s", typ)
+	}
+	return PivotNode{ID: typ + ":" + value, Type: typ, Value: value}, nil
+}
+
+// pivotCrawler state of Pivot
+type pivotCrawler struct {
+	c       *Client
+	ctx     context.Context
+	options PivotOptions
+	g       PivotGraph
+	nodes   map[string]int // id to index of nodes
+	edges   map[string]int // sorted ids to index of links
+}
+
+// errPivotStopped stop condition of crawl reached
+var errPivotStopped = errors.New("pivot stopped")
+
+// query count query, stop when limit reached
+func (p *pivotCrawler) query() error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	if p.options.MaxQueries > 0 && p.g.Queries >= p.options.MaxQueries {
+		p.g.Stopped = "max queries"
+		return errPivotStopped
+	}
+	p.g.Queries++
+	return nil
+}
+
+// stopped map budget error to stop
+func (p *pivotCrawler) stopped(err error) error {
+	if errors.Is(err, ErrBudgetExceeded) {
+		p.g.Stopped = "budget"
+		return errPivotStopped
+	}
+	return err
+}
+
+// search host search with stop conditions
+func (p *pivotCrawler) search(query string, fields []string) ([][]string, error) {
+	if err := p.query(); err != nil {
+		return nil, err
+	}
+	p.c.logger.Debugln("pivot:", query)
+	res, err := p.c.HostSearch(query, p.options.Size, fields, SearchOptions{Full: p.options.Full})
+	return res, p.stopped(err)
+}
+
+// add node found from node of index from, and the edge between them, returns index of new node, -1 if already found
+func (p *pivotCrawler) add(from int, typ string, value string, weight int) (int, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return -1, nil
+	}
+	if typ == PivotNodeDomain {
+		if value = registrableDomain(value); len(value) == 0 {
+			return -1, nil
+		}
+	}
+
+	id := typ + ":" + value
+	i, ok := p.nodes[id]
+	if !ok {
+		if p.options.MaxNodes > 0 && len(p.g.Nodes) >= p.options.MaxNodes {
+			p.g.Stopped = "max nodes"
+			return -1, errPivotStopped
+		}
+		i = len(p.g.Nodes)
+		p.nodes[id] = i
+		node := PivotNode{ID: id, Type: typ, Value: value}
+		if from >= 0 {
+			node.Depth = p.g.Nodes[from].Depth + 1
+		}
+		p.g.Nodes = append(p.g.Nodes, node)
+	}
+	if from < 0 || from == i {
+		if ok {
+			return -1, nil
+		}
+		return i, nil
+	}
+
+	// 无向边，两个方向只记录一条
+	source := p.g.Nodes[from].ID
+	ids := []string{source, id}
+	sort.Strings(ids)
+	key := ids[0] + "\x00" + ids[1]
+	if e, exists := p.edges[key]; exists {
+		p.g.Links[e].Weight += weight
+	} else {
+		p.edges[key] = len(p.g.Links)
+		p.g.Links = append(p.g.Links, PivotEdge{
+			Source: source,
+			Target: id,
+			Type:   p.g.Nodes[from].Type + "-" + typ,
+			Weight: weight,
+		})
+	}
+	if ok {
+		return -1, nil
+	}
+	return i, nil
+}
+
+// addRows add nodes of each column of rows, types are node types of columns
+func (p *pivotCrawler) addRows(from int, rows [][]string, types []string) (next []int, err error) {
+	for _, row := range rows {
+		for i, typ := range types {
+			if i >= len(row) {
+				break
+			}
+			values := []string{row[i]}
+			if typ == PivotNodeDomain {
+				values = strings.Split(row[i], ",")
+			}
+			for _, v := range values {
+				n, err := p.add(from, typ, v, 1)
+				if err != nil {
+					return next, err
+				}
+				if n >= 0 {
+					next = append(next, n)
+				}
+			}
+		}
+	}
+	return next, nil
+}
+
+// expand crawl relations of one node
+// ip: host stats, then certs, domains and icons on it
+// cert: ips and domains of certificate
+// domain: ips, icons and certs of domain
+// icon_hash: top domains by stats, and ips
+func (p *pivotCrawler) expand(from int) (next []int, err error) {
+	node := p.g.Nodes[from]
+	var query string
+	var fields, types []string
+	switch node.Type {
+	case PivotNodeIP:
+		if err = p.query(); err != nil {
+			return nil, err
+		}
+		var data HostStatsData
+		if data, err = p.c.HostStats(node.Value); err != nil {
+			if err = p.stopped(err); errors.Is(err, errPivotStopped) {
+				return nil, err
+			}
+			// 没有数据的ip不影响继续拓展
+			p.c.logger.Debugln("host stats of", node.Value, "failed:", err)
+		} else {
+			p.g.Nodes[from].Attrs = hostStatsAttrs(data)
+		}
+		query = fofaQuote("ip", node.Value)
+		fields = []string{"certs_subject_cn", "certs_domains", "icon_hash"}
+		types = []string{PivotNodeCert, PivotNodeDomain, PivotNodeIcon}
+	case PivotNodeCert:
+		query = fofaQuote("cert.subject.cn", node.Value)
+		fields = []string{"ip", "certs_domains"}
+		types = []string{PivotNodeIP, PivotNodeDomain}
+	case PivotNodeDomain:
+		query = fofaQuote("domain", node.Value)
+		fields = []string{"ip", "icon_hash", "certs_subject_cn"}
+		types = []string{PivotNodeIP, PivotNodeIcon, PivotNodeCert}
+	case PivotNodeIcon:
+		query = fofaQuote("icon_hash", node.Value)
+		if err = p.query(); err != nil {
+			return nil, err
+		}
+		var stats []StatsObject
+		if stats, err = p.c.Stats(query, p.options.Size, []string{"domain"}, SearchOptions{Full: p.options.Full}); err != nil {
+			return nil, p.stopped(err)
+		}
+		for _, so := range stats {
+			for _, item := range so.Items {
+				n, err := p.add(from, PivotNodeDomain, item.Name, item.Count)
+				if err != nil {
+					return next, err
+				}
+				if n >= 0 {
+					next = append(next, n)
+				}
+			}
+		}
+		fields = []string{"ip"}
+		types = []string{PivotNodeIP}
+	}
+
+	rows, err := p.search(query, fields)
+	if err != nil {
+		return next, err
+	}
+	found, err := p.addRows(from, rows, types)
+	return append(next, found...), err
+}
+
+// hostStatsAttrs attributes of ip node from host stats
+func hostStatsAttrs(data HostStatsData) map[string]string {
+	attrs := make(map[string]string)
+	add := func(k, v string) {
+		if len(v) > 0 {
+			attrs[k] = v
+		}
+	}
+	add("org", data.ORG)
+	add("country", data.CountryCode)
+	if data.ASN > 0 {
+		add("asn", strconv.Itoa(data.ASN))
+	}
+	var ports []string
+	for _, port := range data.Ports {
+		ports = append(ports, strconv.Itoa(port))
+	}
+	add("ports", strings.Join(ports, ","))
+	add("protocols", strings.Join(data.Protocols, ","))
+	add("products", strings.Join(data.Products, ","))
+	return attrs
+}
+
+// Pivot crawl relations of seeds: ip, certificate, domain and icon hash, build graph of them
+// seeds are parsed by ParsePivotSeed, partial graph is returned with nil error when MaxNodes, MaxQueries or client budget is reached
+func (c *Client) Pivot(ctx context.Context, seeds []string, options PivotOptions) (PivotGraph, error) {
+	if options.Depth < 1 {
+		options.Depth = 1
+	}
+	if options.Size < 1 {
+		options.Size = 100
+	}
+
+	p := &pivotCrawler{
+		c:       c,
+		ctx:     ctx,
+		options: options,
+		nodes:   make(map[string]int),
+		edges:   make(map[string]int),
+	}
+	var frontier []int
+	for _, seed := range seeds {
+		node, err := ParsePivotSeed(seed)
+		if err != nil {
+			return PivotGraph{}, err
+		}
+		i, err := p.add(-1, node.Type, node.Value, 0)
+		if errors.Is(err, errPivotStopped) {
+			return p.g, nil
+		}
+		if i >= 0 {
+			frontier = append(frontier, i)
+		}
+	}
+
+	for depth := 1; depth <= options.Depth && len(frontier) > 0; depth++ {
+		var next []int
+		for _, i := range frontier {
+			found, err := p.expand(i)
+			next = append(next, found...)
+			if errors.Is(err, errPivotStopped) {
+				return p.g, nil
+			}
+			if err != nil {
+				return p.g, err
+			}
+		}
+		frontier = next
+	}
+	return p.g, nil
+}
+
+// WriteJSON write graph as json of nodes and links, which d3 and most viewers can load
+func (g PivotGraph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// attrKeys sorted keys of attrs
+func attrKeys(attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteDOT write graph as graphviz dot
+func (g PivotGraph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("graph pivot {\n")
+	for _, n := range g.Nodes {
+		label := n.Value
+		for _, k := range attrKeys(n.Attrs) {
+			label += "\n" + k + ": " + n.Attrs[k]
+		}
+		fmt.Fprintf(&sb, "  %s [label=%s, type=%s, depth=%d];\n",
+			strconv.Quote(n.ID), strconv.Quote(label), strconv.Quote(n.Type), n.Depth)
+	}
+	for _, e := range g.Links {
+		fmt.Fprintf(&sb, "  %s -- %s [type=%s, weight=%d];\n",
+			strconv.Quote(e.Source), strconv.Quote(e.Target), strconv.Quote(e.Type), e.Weight)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// xmlEscape escape text of xml
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// WriteGraphML write graph as graphml, which gephi and yed can load, attrs of nodes are keys of their own
+func (g PivotGraph) WriteGraphML(w io.Writer) error {
+	attrSet := make(map[string]string)
+	for _, n := range g.Nodes {
+		for k, v := range n.Attrs {
+			attrSet[k] = v
+		}
+	}
+	attrs := attrKeys(attrSet)
+
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	sb.WriteString(`  <key id="type" for="node" attr.name="type" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="value" for="node" attr.name="value" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="depth" for="node" attr.name="depth" attr.type="int"/>` + "\n")
+	for _, k := range attrs {
+		fmt.Fprintf(&sb, `  <key id="attr_%s" for="node" attr.name="%s" attr.type="string"/>`+"\n", xmlEscape(k), xmlEscape(k))
+	}
+	sb.WriteString(`  <key id="etype" for="edge" attr.name="type" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="weight" for="edge" attr.name="weight" attr.type="int"/>` + "\n")
+	sb.WriteString(`  <graph id="pivot" edgedefault="undirected">` + "\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&sb, `    <node id="%s">`+"\n", xmlEscape(n.ID))
+		fmt.Fprintf(&sb, `      <data key="type">%s</data>`+"\n", xmlEscape(n.Type))
+		fmt.Fprintf(&sb, `      <data key="value">%s</data>`+"\n", xmlEscape(n.Value))
+		fmt.Fprintf(&sb, `      <data key="depth">%d</data>`+"\n", n.Depth)
+		for _, k := range attrKeys(n.Attrs) {
+			fmt.Fprintf(&sb, `      <data key="attr_%s">%s</data>`+"\n", xmlEscape(k), xmlEscape(n.Attrs[k]))
+		}
+		sb.WriteString("    </node>\n")
+	}
+	for i, e := range g.Links {
+		fmt.Fprintf(&sb, `    <edge id="e%d" source="%s" target="%s">`+"\n", i, xmlEscape(e.Source), xmlEscape(e.Target))
+		fmt.Fprintf(&sb, `      <data key="etype">%s</data>`+"\n", xmlEscape(e.Type))
+		fmt.Fprintf(&sb, `      <data key="weight">%d</data>`+"\n", e.Weight)
+		sb.WriteString("    </edge>\n")
+	}
+	sb.WriteString("  </graph>\n</graphml>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,173 @@
+package gofofa
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePivotSeed(t *testing.T) {
+	n, err := ParsePivotSeed("ip:1.2.3.4")
+	assert.Nil(t, err)
+	assert.Equal(t, PivotNode{ID: "ip:1.2.3.4", Type: PivotNodeIP, Value: "1.2.3.4"}, n)
+	n, err = ParsePivotSeed("icon:-123")
+	assert.Nil(t, err)
+	assert.Equal(t, "icon_hash:-123", n.ID)
+	n, err = ParsePivotSeed(" domain:Example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "domain:example.com", n.ID)
+
+	for _, seed := range []string{"1.2.3.4", "ip:", "ip:abc", "title:x"} {
+		_, err = ParsePivotSeed(seed)
+		assert.Error(t, err, seed)
+	}
+}
+
+func newPivotTestServer(queries *[]string) *httptest.Server {
+	search := map[string]interface{}{
+		`ip="1.2.3.4"`: [][]string{
+			{"a.example.com", "a.example.com,b.example.org", "111"},
+			{"", "", "111"},
+		},
+		`cert.subject.cn="a.example.com"`: [][]string{{"1.2.3.4", "example.com"}, {"5.6.7.8", ""}},
+		`domain="example.com"`:            [][]string{{"5.6.7.8", "", ""}},
+		`icon_hash="111"`:                 []string{"1.2.3.4", "9.9.9.9"},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, _ := base64.StdEncoding.DecodeString(r.FormValue("qbase64"))
+		switch r.URL.Path {
+		case "/api/v1/info/my":
+			queryHander(w, r)
+			return
+		case "/api/v1/host/1.2.3.4":
+			*queries = append(*queries, "host 1.2.3.4")
+			w.Write([]byte(`{"error":false,"ip":"1.2.3.4","asn":13335,"org":"CF","country_code":"US","port":[80,443],"protocol":["http","https"]}`))
+			return
+		case "/api/v1/search/stats":
+			*queries = append(*queries, "stats "+string(q))
+			w.Write([]byte(`{"error":false,"aggs":{"domain":[{"name":"example.com","count":5},{"name":"other.net","count":2}]}}`))
+			return
+		}
+		*queries = append(*queries, string(q))
+		res, ok := search[string(q)]
+		if !ok {
+			if strings.HasPrefix(r.URL.Path, "/api/v1/host/") {
+				w.Write([]byte(`{"error":true,"errmsg":"not found"}`))
+				return
+			}
+			res = []string{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"error": false, "size": 10, "page": 1, "results": res})
+		w.Write(b)
+	}))
+}
+
+func TestClient_Pivot(t *testing.T) {
+	var queries []string
+	ts := newPivotTestServer(&queries)
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	g, err := cli.Pivot(ctx, []string{"ip:1.2.3.4"}, PivotOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"host 1.2.3.4", `ip="1.2.3.4"`}, queries)
+	assert.Equal(t, 2, g.Queries)
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	assert.Equal(t, []string{"ip:1.2.3.4", "cert:a.example.com", "domain:example.com", "domain:example.org", "icon_hash:111"}, ids)
+	assert.Equal(t, map[string]string{"org": "CF", "country": "US", "asn": "13335", "ports": "80,443", "protocols": "http,https"}, g.Nodes[0].Attrs)
+	assert.Equal(t, 1, g.Nodes[4].Depth)
+	assert.Equal(t, PivotEdge{Source: "ip:1.2.3.4", Target: "icon_hash:111", Type: "ip-icon_hash", Weight: 2}, g.Links[3])
+	assert.Equal(t, 4, len(g.Links))
+
+	// 第二层，反向的边合并
+	queries = nil
+	g, err = cli.Pivot(ctx, []string{"ip:1.2.3.4"}, PivotOptions{Depth: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "", g.Stopped)
+	assert.Contains(t, queries, `stats icon_hash="111"`)
+	assert.Contains(t, queries, `cert.subject.cn="a.example.com"`)
+	byID := make(map[string]PivotNode)
+	for _, n := range g.Nodes {
+		byID[n.ID] = n
+	}
+	assert.Equal(t, 2, byID["ip:5.6.7.8"].Depth)
+	assert.Equal(t, 2, byID["ip:9.9.9.9"].Depth)
+	assert.Equal(t, 2, byID["domain:other.net"].Depth)
+	for _, e := range g.Links {
+		if e.Source == "ip:1.2.3.4" && e.Target == "icon_hash:111" {
+			assert.Equal(t, 3, e.Weight)
+		}
+		assert.NotEqual(t, e.Source, e.Target)
+	}
+
+	// 停止条件
+	g, err = cli.Pivot(ctx, []string{"ip:1.2.3.4"}, PivotOptions{Depth: 3, MaxNodes: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, "max nodes", g.Stopped)
+	assert.Equal(t, 3, len(g.Nodes))
+	g, err = cli.Pivot(ctx, []string{"ip:1.2.3.4"}, PivotOptions{Depth: 3, MaxQueries: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "max queries", g.Stopped)
+	assert.Equal(t, 1, g.Queries)
+
+	_, err = cli.Pivot(ctx, []string{"x"}, PivotOptions{})
+	assert.Error(t, err)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = cli.Pivot(canceled, []string{"domain:example.com"}, PivotOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPivotGraph_Write(t *testing.T) {
+	g := PivotGraph{
+		Nodes: []PivotNode{
+			{ID: "ip:1.2.3.4", Type: PivotNodeIP, Value: "1.2.3.4", Attrs: map[string]string{"org": "A&B"}},
+			{ID: "cert:<x>", Type: PivotNodeCert, Value: `<x>"`, Depth: 1},
+		},
+		Links: []PivotEdge{{Source: "ip:1.2.3.4", Target: "cert:<x>", Type: "ip-cert", Weight: 2}},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, g.WriteDOT(&buf))
+	assert.Equal(t, `graph pivot {
+  "ip:1.2.3.4" [label="1.2.3.4\norg: A&B", type="ip", depth=0];
+  "cert:<x>" [label="<x>\"", type="cert", depth=1];
+  "ip:1.2.3.4" -- "cert:<x>" [type="ip-cert", weight=2];
+}
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, g.WriteGraphML(&buf))
+	assert.Contains(t, buf.String(), `<key id="attr_org" for="node" attr.name="org" attr.type="string"/>`)
+	assert.Contains(t, buf.String(), `<data key="attr_org">A&amp;B</data>`)
+	assert.Contains(t, buf.String(), `<edge id="e0" source="ip:1.2.3.4" target="cert:&lt;x&gt;">`)
+	// 必须是合法的xml
+	d := xml.NewDecoder(&buf)
+	for {
+		_, err := d.Token()
+		if err != nil {
+			assert.Equal(t, "EOF", err.Error())
+			break
+		}
+	}
+
+	buf.Reset()
+	assert.Nil(t, g.WriteJSON(&buf))
+	var v PivotGraph
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &v))
+	assert.Equal(t, g, v)
+}
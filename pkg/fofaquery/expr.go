@@ -0,0 +1,234 @@
+package fofaquery
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Expr parsed query expression
+type Expr interface {
+	// Match check if record matches expression
+	Match(record map[string]string) bool
+	// Fields record fields referenced by expression
+	Fields() []string
+	String() string
+}
+
+// fieldAliases query field to record field, same as fofa stats
+var fieldAliases = map[string]string{
+	"asn":    "as_number",
+	"org":    "as_organization",
+	"after":  "lastupdatetime",
+	"before": "lastupdatetime",
+}
+
+// exactFields fields compared exactly by =, other fields match by case-insensitive contains
+var exactFields = map[string]bool{
+	"ip":            true,
+	"port":          true,
+	"country":       true,
+	"country_name":  true,
+	"region":        true,
+	"city":          true,
+	"as_number":     true,
+	"protocol":      true,
+	"base_protocol": true,
+	"type":          true,
+	"os":            true,
+	"icon_hash":     true,
+	"status_code":   true,
+	"is_ipv6":       true,
+}
+
+type andExpr struct {
+	left, right Expr
+}
+
+func (e *andExpr) Match(record map[string]string) bool {
+	return e.left.Match(record) && e.right.Match(record)
+}
+
+func (e *andExpr) Fields() []string {
+	return mergeFields(e.left.Fields(), e.right.Fields())
+}
+
+func (e *andExpr) String() string {
+	return "(" + e.left.String() + " && " + e.right.String() + ")"
+}
+
+type orExpr struct {
+	left, right Expr
+}
+
+func (e *orExpr) Match(record map[string]string) bool {
+	return e.left.Match(record) || e.right.Match(record)
+}
+
+func (e *orExpr) Fields() []string {
+	return mergeFields(e.left.Fields(), e.right.Fields())
+}
+
+func (e *orExpr) String() string {
+	return "(" + e.left.String() + " || " + e.right.String() + ")"
+}
+
+// searchExpr bare string, any field contains it
+type searchExpr struct {
+	value string // lower case
+}
+
+func (e *searchExpr) Match(record map[string]string) bool {
+	for _, v := range record {
+		if strings.Contains(strings.ToLower(v), e.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *searchExpr) Fields() []string {
+	return nil
+}
+
+func (e *searchExpr) String() string {
+	return fmt.Sprintf("%q", e.value)
+}
+
+// fieldExpr field op value
+type fieldExpr struct {
+	field  string // query field
+	key    string // record field
+	op     string
+	value  string
+	lower  string         // lower case value for contains
+	ipNet  *net.IPNet     // ip="1.1.1.0/24"
+	regexp *regexp.Regexp // ~= and *=
+}
+
+func newFieldExpr(field, op, value string) (*fieldExpr, error) {
+	e := &fieldExpr{
+		field: field,
+		key:   field,
+		op:    op,
+		value: value,
+		lower: strings.ToLower(value),
+	}
+	if k, ok := fieldAliases[field]; ok {
+		e.key = k
+	}
+
+	switch {
+	case field == "after" || field == "before":
+		if op != "=" {
+			return nil, fmt.Errorf("%s only support =", field)
+		}
+	case op == "~=":
+		r, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp of %s: %w", field, err)
+		}
+		e.regexp = r
+	case op == "*=":
+		e.regexp = wildcardRegexp(value)
+	case field == "ip" && strings.Contains(value, "/"):
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip of %s: %w", value, err)
+		}
+		e.ipNet = ipNet
+	}
+	return e, nil
+}
+
+// match = semantics, exact for exactFields and cidr of ip, case-insensitive contains for others
+func (e *fieldExpr) match(v string) bool {
+	if len(e.value) == 0 {
+		return len(v) == 0
+	}
+	if e.ipNet != nil {
+		ip := net.ParseIP(v)
+		return ip != nil && e.ipNet.Contains(ip)
+	}
+	if exactFields[e.key] {
+		return strings.EqualFold(v, e.value)
+	}
+	return strings.Contains(strings.ToLower(v), e.lower)
+}
+
+func (e *fieldExpr) Match(record map[string]string) bool {
+	v, ok := record[e.key]
+
+	switch e.field {
+	case "after", "before":
+		// lastupdatetime like 2022-01-01 12:00:00, compare by string
+		if !ok || len(v) == 0 {
+			return false
+		}
+		if e.field == "after" {
+			return v > e.value
+		}
+		return v < e.value
+	}
+
+	switch e.op {
+	case "=":
+		return ok && e.match(v)
+	case "==":
+		return ok && v == e.value
+	case "!=":
+		return !ok || !e.match(v)
+	case "*=", "~=":
+		return ok && e.regexp.MatchString(v)
+	}
+	return false
+}
+
+func (e *fieldExpr) Fields() []string {
+	return []string{e.key}
+}
+
+func (e *fieldExpr) String() string {
+	return fmt.Sprintf("%s%s%q", e.field, e.op, e.value)
+}
+
+// wildcardRegexp * matches any characters, ? matches one character, case-insensitive
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("(?is)^" + expr + "$")
+}
+
+func mergeFields(a, b []string) []string {
+	m := make(map[string]bool, len(a)+len(b))
+	for _, f := range a {
+		m[f] = true
+	}
+	for _, f := range b {
+		m[f] = true
+	}
+	fields := make([]string, 0, len(m))
+	for f := range m {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Filter records matched query
+func Filter(query string, records []map[string]string) ([]map[string]string, error) {
+	e, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	var matched []map[string]string
+	for _, record := range records {
+		if e.Match(record) {
+			matched = append(matched, record)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,86 @@
+package fofaquery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var testRecords = []map[string]string{
+	{"ip": "1.1.1.1", "port": "443", "title": "Hello World", "country": "CN", "host": "https://a.gov.cn", "lastupdatetime": "2022-03-01 10:00:00"},
+	{"ip": "2.2.2.2", "port": "80", "title": "Login", "country": "US", "host": "b.com", "header": "Server: nginx", "lastupdatetime": "2021-12-01 10:00:00"},
+	{"ip": "1.1.2.3", "port": "8443", "title": "", "country": "CN", "host": "1.1.2.3:8443", "as_number": "4134", "lastupdatetime": "2022-01-15 10:00:00"},
+}
+
+func matchedIPs(t *testing.T, query string) []string {
+	records, err := Filter(query, testRecords)
+	assert.Nil(t, err, query)
+	var ips []string
+	for _, r := range records {
+		ips = append(ips, r["ip"])
+	}
+	return ips
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		ips   []string
+	}{
+		{`title="hello"`, []string{"1.1.1.1"}},
+		{`title=="Hello"`, nil},
+		{`title=="Hello World"`, []string{"1.1.1.1"}},
+		{`port="443"`, []string{"1.1.1.1"}},
+		{`port=443`, []string{"1.1.1.1"}},
+		{`port!="443"`, []string{"2.2.2.2", "1.1.2.3"}},
+		{`title="x" && port="443" || country="CN"`, []string{"1.1.1.1", "1.1.2.3"}},
+		{`title="login" || (country="CN" && port="8443")`, []string{"2.2.2.2", "1.1.2.3"}},
+		{`country="cn" && (port=443 || port=80)`, []string{"1.1.1.1"}},
+		{`host*="*.gov.cn"`, []string{"1.1.1.1"}},
+		{`host*="?.com"`, []string{"2.2.2.2"}},
+		{`title~="^L.g"`, []string{"2.2.2.2"}},
+		{`header="nginx"`, []string{"2.2.2.2"}},
+		{`header!="nginx"`, []string{"1.1.1.1", "1.1.2.3"}},
+		{`title=""`, []string{"1.1.2.3"}},
+		{`title!=""`, []string{"1.1.1.1", "2.2.2.2"}},
+		{`ip="1.1.0.0/16"`, []string{"1.1.1.1", "1.1.2.3"}},
+		{`ip="1.1.1.1"`, []string{"1.1.1.1"}},
+		{`asn="4134"`, []string{"1.1.2.3"}},
+		{`after="2022-01-01"`, []string{"1.1.1.1", "1.1.2.3"}},
+		{`after="2022-01-01" && before="2022-02-01"`, []string{"1.1.2.3"}},
+		{`"nginx"`, []string{"2.2.2.2"}},
+		{`title="a \"b\" c" || port=80`, []string{"2.2.2.2"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.ips, matchedIPs(t, test.query), test.query)
+	}
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse(`title="x" && port=443 || (asn="1" && !title="")`)
+	assert.Error(t, err)
+	assert.Nil(t, e)
+
+	e, err = Parse(`title="x" && port=443 || (asn="1" && title!="")`)
+	assert.Nil(t, err)
+	assert.Equal(t, `((title="x" && port="443") || (asn="1" && title!=""))`, e.String())
+	assert.Equal(t, []string{"as_number", "port", "title"}, e.Fields())
+
+	for query, msg := range map[string]string{
+		``:                    "unexpected end of query",
+		`title`:               "missing operator after title at 5",
+		`title=`:              "missing value at 6",
+		`title="x`:            "unterminated string at 6",
+		`(title="x"`:          "missing ) at 10",
+		`title="x")`:          `unexpected ")" at 9`,
+		`title="x" &&`:        "unexpected end of query",
+		`title~="("`:          "invalid regexp of title",
+		`ip="1.1.1.1/99"`:     "invalid ip of 1.1.1.1/99",
+		`after*="2022-01-01"`: "after only support =",
+		`title="x" port=1`:    `unexpected "port" at 10`,
+	} {
+		_, err = Parse(query)
+		if assert.Error(t, err, query) {
+			assert.Contains(t, err.Error(), msg, query)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Package fofaquery parse fofa query syntax and evaluate it against local records
+
+records are maps of field name to value, such as lines written by outformats.JSONWriter,
+so the same query language works online and offline:
+
+	title="x" && port="443" || country="CN"
+	host*="*.gov.cn" && header!="nginx"
+	ip="1.1.1.0/24" && after="2022-01-01"
+*/
+package fofaquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// token types
+const (
+	tokEOF = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokOp
+	tokIdent
+	tokString
+)
+
+type token struct {
+	typ int
+	val string
+	pos int
+}
+
+// lexer split query into tokens
+type lexer struct {
+	query string
+	pos   int
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.query) && strings.ContainsRune(" \t\r\n", rune(l.query[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.query) {
+		return token{typ: tokEOF, pos: start}, nil
+	}
+
+	rest := l.query[l.pos:]
+	switch {
+	case rest[0] == '(':
+		l.pos++
+		return token{typ: tokLParen, val: "(", pos: start}, nil
+	case rest[0] == ')':
+		l.pos++
+		return token{typ: tokRParen, val: ")", pos: start}, nil
+	case strings.HasPrefix(rest, "&&"):
+		l.pos += 2
+		return token{typ: tokAnd, val: "&&", pos: start}, nil
+	case strings.HasPrefix(rest, "||"):
+		l.pos += 2
+		return token{typ: tokOr, val: "||", pos: start}, nil
+	case strings.HasPrefix(rest, "=="), strings.HasPrefix(rest, "!="),
+		strings.HasPrefix(rest, "*="), strings.HasPrefix(rest, "~="):
+		l.pos += 2
+		return token{typ: tokOp, val: rest[:2], pos: start}, nil
+	case rest[0] == '=':
+		l.pos++
+		return token{typ: tokOp, val: "=", pos: start}, nil
+	case rest[0] == '"':
+		return l.quoted()
+	case isIdentChar(rest[0]):
+		for l.pos < len(l.query) && isIdentChar(l.query[l.pos]) {
+			l.pos++
+		}
+		return token{typ: tokIdent, val: l.query[start:l.pos], pos: start}, nil
+	}
+	return token{}, fmt.Errorf("unexpected character %q at %d", rest[0], start)
+}
+
+// quoted read quoted string, backslash escapes quote and backslash
+func (l *lexer) quoted() (token, error) {
+	start := l.pos
+	var sb strings.Builder
+	for l.pos++; l.pos < len(l.query); l.pos++ {
+		c := l.query[l.pos]
+		switch c {
+		case '\\':
+			if l.pos+1 < len(l.query) {
+				l.pos++
+				c = l.query[l.pos]
+				if c != '"' && c != '\\' {
+					sb.WriteByte('\\')
+				}
+			}
+			sb.WriteByte(c)
+		case '"':
+			l.pos++
+			return token{typ: tokString, val: sb.String(), pos: start}, nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string at %d", start)
+}
+
+// value read value after operator, quoted or not
+func (l *lexer) value() (token, error) {
+	for l.pos < len(l.query) && strings.ContainsRune(" \t\r\n", rune(l.query[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos < len(l.query) && l.query[l.pos] == '"' {
+		return l.quoted()
+	}
+	for l.pos < len(l.query) && !strings.ContainsRune(" \t\r\n()", rune(l.query[l.pos])) {
+		rest := l.query[l.pos:]
+		if strings.HasPrefix(rest, "&&") || strings.HasPrefix(rest, "||") {
+			break
+		}
+		l.pos++
+	}
+	if l.pos == start {
+		return token{}, fmt.Errorf("missing value at %d", start)
+	}
+	return token{typ: tokString, val: l.query[start:l.pos], pos: start}, nil
+}
+
+// parser recursive descent parser:
+//
+//	expr  := and ('||' and)*
+//	and   := unary ('&&' unary)*
+//	unary := '(' expr ')' | field op value | string
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() (err error) {
+	p.tok, err = p.lex.next()
+	return
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.typ == tokOr {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.typ == tokAnd {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	switch p.tok.typ {
+	case tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.typ != tokRParen {
+			return nil, fmt.Errorf("missing ) at %d", p.tok.pos)
+		}
+		return e, p.advance()
+	case tokString:
+		e := &searchExpr{value: strings.ToLower(p.tok.val)}
+		return e, p.advance()
+	case tokIdent:
+		field := p.tok.val
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.typ != tokOp {
+			return nil, fmt.Errorf("missing operator after %s at %d", field, p.tok.pos)
+		}
+		op := p.tok.val
+		v, err := p.lex.value()
+		if err != nil {
+			return nil, err
+		}
+		e, err := newFieldExpr(strings.ToLower(field), op, v.val)
+		if err != nil {
+			return nil, err
+		}
+		return e, p.advance()
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	return nil, fmt.Errorf("unexpected %s at %d", strconv.Quote(p.tok.val), p.tok.pos)
+}
+
+// Parse parse fofa query
+func Parse(query string) (Expr, error) {
+	p := &parser{lex: &lexer{query: query}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.typ != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at %d", strconv.Quote(p.tok.val), p.tok.pos)
+	}
+	return e, nil
+}
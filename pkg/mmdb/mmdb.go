@@ -0,0 +1,252 @@
+/*
+Package mmdb enrich fofa rows with local MaxMind/mmdb files
+
+each database has a name, columns are named as <db>.<path>, path is looked up in the record of ip:
+
+	geo.city       city.names.en of GeoLite2-City
+	asn.org        autonomous_system_organization of GeoLite2-ASN
+	owner.team     team of custom internal ownership database
+*/
+package mmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// aliases short path to path of MaxMind databases, used when short path is not found in record
+var aliases = map[string]string{
+	"city":         "city.names.en",
+	"country":      "country.iso_code",
+	"country_name": "country.names.en",
+	"continent":    "continent.code",
+	"region":       "subdivisions.0.names.en",
+	"postal":       "postal.code",
+	"latitude":     "location.latitude",
+	"longitude":    "location.longitude",
+	"timezone":     "location.time_zone",
+	"asn":          "autonomous_system_number",
+	"org":          "autonomous_system_organization",
+}
+
+// column one appended column
+type column struct {
+	name  string // <db>.<path>
+	db    *maxminddb.Reader
+	path  []string // path in record
+	alias []string // path of alias, nil if no alias
+}
+
+// Enricher append columns looked up from mmdb files by ip field
+type Enricher struct {
+	dbs     map[string]*maxminddb.Reader
+	columns []column
+}
+
+// ParseDatabases parse database specs like geo=GeoLite2-City.mmdb into name => file
+func ParseDatabases(specs []string) (map[string]string, error) {
+	dbs := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, file, ok := strings.Cut(spec, "=")
+		if !ok || len(name) == 0 || len(file) == 0 {
+			return nil, fmt.Errorf("invalid mmdb spec: %s, should be name=file.mmdb", spec)
+		}
+		if strings.Contains(name, ".") {
+			return nil, fmt.Errorf("invalid mmdb name: %s, should not contain .", name)
+		}
+		dbs[name] = file
+	}
+	return dbs, nil
+}
+
+// Open open mmdb files, dbs is name => file, columns are like geo.city
+func Open(dbs map[string]string, columns []string) (*Enricher, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("enrich columns cannot be empty")
+	}
+
+	e := &Enricher{dbs: make(map[string]*maxminddb.Reader, len(dbs))}
+	for name, file := range dbs {
+		db, err := maxminddb.Open(file)
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("open mmdb %s failed: %w", file, err)
+		}
+		e.dbs[name] = db
+	}
+
+	for _, c := range columns {
+		name, path, ok := strings.Cut(c, ".")
+		db := e.dbs[name]
+		if !ok || len(path) == 0 || db == nil {
+			e.Close()
+			return nil, fmt.Errorf("invalid enrich column: %s, should be <db>.<path> of opened mmdb", c)
+		}
+		col := column{name: c, db: db, path: strings.Split(path, ".")}
+		if alias, ok := aliases[path]; ok {
+			col.alias = strings.Split(alias, ".")
+		}
+		e.columns = append(e.columns, col)
+	}
+	return e, nil
+}
+
+// Close close all mmdb files
+func (e *Enricher) Close() error {
+	var err error
+	for _, db := range e.dbs {
+		if errClose := db.Close(); errClose != nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+// Columns names of appended columns
+func (e *Enricher) Columns() []string {
+	names := make([]string, 0, len(e.columns))
+	for _, c := range e.columns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Lookup values of columns of ip, empty if not found
+func (e *Enricher) Lookup(ip string) ([]string, error) {
+	values := make([]string, len(e.columns))
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return values, nil
+	}
+
+	records := make(map[*maxminddb.Reader]interface{}, len(e.dbs))
+	for i, c := range e.columns {
+		record, ok := records[c.db]
+		if !ok {
+			if err := c.db.Lookup(addr, &record); err != nil {
+				return nil, fmt.Errorf("lookup %s failed: %w", ip, err)
+			}
+			records[c.db] = record
+		}
+
+		// 直接路径不存在或者是对象时使用别名，比如country对应country.iso_code
+		v, found := lookupPath(record, c.path)
+		if c.alias != nil && (!found || isObject(v)) {
+			if av, ok := lookupPath(record, c.alias); ok {
+				v, found = av, ok
+			}
+		}
+		if found {
+			values[i] = formatValue(v)
+		}
+	}
+	return values, nil
+}
+
+// Enrich append columns to rows, fields must contain ip
+// rows are modified in place and returned with new fields
+func (e *Enricher) Enrich(fields []string, rows [][]string) ([]string, [][]string, error) {
+	ipIndex := -1
+	for i, f := range fields {
+		if f == "ip" {
+			ipIndex = i
+			break
+		}
+	}
+	if ipIndex == -1 {
+		return nil, nil, errors.New("enrich needs ip field")
+	}
+
+	for i, row := range rows {
+		var ip string
+		if ipIndex < len(row) {
+			ip = row[ipIndex]
+		}
+		values, err := e.Lookup(ip)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows[i] = append(row, values...)
+	}
+
+	newFields := append(append([]string{}, fields...), e.Columns()...)
+	return newFields, rows, nil
+}
+
+// Stream append columns to rows of stream, fields of stream must contain ip
+func (e *Enricher) Stream(s *stream.Stream) *stream.Stream {
+	fields := append(append([]string{}, s.Fields()...), e.Columns()...)
+	return stream.New(fields, func(ctx context.Context, emit stream.EmitFunc) error {
+		return s.Run(ctx, func(rows [][]string) error {
+			_, rows, err := e.Enrich(s.Fields(), rows)
+			if err != nil {
+				return err
+			}
+			return emit(rows)
+		})
+	})
+}
+
+// lookupPath value of path in record, number is index of array
+func lookupPath(v interface{}, path []string) (interface{}, bool) {
+	for _, p := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[p]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// isObject value is map or array
+func isObject(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// formatValue value as string, map and array are json encoded
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case uint64:
+		return strconv.FormatUint(value, 10)
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(value)
+	case []byte:
+		return string(value)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,225 @@
+package mmdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeData encode value of MaxMind DB data section
+func encodeData(v interface{}) []byte {
+	control := func(typ int, size int) []byte {
+		var buf []byte
+		var first byte
+		if typ <= 7 {
+			first = byte(typ << 5)
+		}
+		var extra []byte
+		switch {
+		case size < 29:
+			first |= byte(size)
+		case size < 29+256:
+			first |= 29
+			extra = []byte{byte(size - 29)}
+		default:
+			first |= 30
+			extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+		}
+		buf = append(buf, first)
+		if typ > 7 {
+			buf = append(buf, byte(typ-7))
+		}
+		return append(buf, extra...)
+	}
+	uintBytes := func(n uint64) []byte {
+		var b []byte
+		for ; n > 0; n >>= 8 {
+			b = append([]byte{byte(n)}, b...)
+		}
+		return b
+	}
+
+	switch value := v.(type) {
+	case string:
+		return append(control(2, len(value)), value...)
+	case float64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(value))
+		return append(control(3, 8), b...)
+	case uint16:
+		b := uintBytes(uint64(value))
+		return append(control(5, len(b)), b...)
+	case uint32:
+		b := uintBytes(uint64(value))
+		return append(control(6, len(b)), b...)
+	case uint64:
+		b := uintBytes(value)
+		return append(control(9, len(b)), b...)
+	case bool:
+		if value {
+			return control(14, 1)
+		}
+		return control(14, 0)
+	case []interface{}:
+		buf := control(11, len(value))
+		for _, item := range value {
+			buf = append(buf, encodeData(item)...)
+		}
+		return buf
+	case map[string]interface{}:
+		buf := control(7, len(value))
+		for k, item := range value {
+			buf = append(buf, encodeData(k)...)
+			buf = append(buf, encodeData(item)...)
+		}
+		return buf
+	}
+	panic("unsupported type")
+}
+
+// writeTestDB write ipv4 mmdb file with one node, 0.0.0.0/1 is low and 128.0.0.0/1 is high
+func writeTestDB(t *testing.T, low, high map[string]interface{}) string {
+	lowData := encodeData(low)
+	data := append(lowData, encodeData(high)...)
+
+	var buf bytes.Buffer
+	// node_count is 1, data pointer is node_count + 16 + offset
+	for _, record := range []int{1 + 16, 1 + 16 + len(lowData)} {
+		buf.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(data)
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	buf.Write(encodeData(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1650000000),
+		"database_type":               "test",
+		"ip_version":                  uint16(4),
+		"node_count":                  uint32(1),
+		"record_size":                 uint16(24),
+	}))
+
+	filename := filepath.Join(t.TempDir(), "test.mmdb")
+	assert.Nil(t, os.WriteFile(filename, buf.Bytes(), 0644))
+	return filename
+}
+
+func testDatabases(t *testing.T) map[string]string {
+	geo := writeTestDB(t, map[string]interface{}{
+		"city":         map[string]interface{}{"names": map[string]interface{}{"en": "Beijing"}},
+		"country":      map[string]interface{}{"iso_code": "CN"},
+		"subdivisions": []interface{}{map[string]interface{}{"names": map[string]interface{}{"en": "Beijing"}}},
+		"location":     map[string]interface{}{"latitude": 39.9, "longitude": 116.4},
+	}, map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "US"},
+	})
+	asn := writeTestDB(t, map[string]interface{}{
+		"autonomous_system_number":       uint32(4808),
+		"autonomous_system_organization": "China Unicom Beijing Province Network",
+	}, map[string]interface{}{
+		"autonomous_system_number":       uint32(15169),
+		"autonomous_system_organization": "GOOGLE",
+		"internal":                       true,
+	})
+	return map[string]string{"geo": geo, "asn": asn}
+}
+
+func TestParseDatabases(t *testing.T) {
+	dbs, err := ParseDatabases([]string{"geo=a.mmdb", "asn=/tmp/b=c.mmdb"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"geo": "a.mmdb", "asn": "/tmp/b=c.mmdb"}, dbs)
+
+	_, err = ParseDatabases([]string{"a.mmdb"})
+	assert.Error(t, err)
+	_, err = ParseDatabases([]string{"geo.city=a.mmdb"})
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	dbs := testDatabases(t)
+
+	_, err := Open(dbs, nil)
+	assert.Error(t, err)
+	_, err = Open(dbs, []string{"city"})
+	assert.Error(t, err)
+	_, err = Open(dbs, []string{"owner.team"})
+	assert.Error(t, err)
+	_, err = Open(map[string]string{"geo": "not_exists.mmdb"}, []string{"geo.city"})
+	assert.Error(t, err)
+
+	e, err := Open(dbs, []string{"geo.city", "asn.org"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"geo.city", "asn.org"}, e.Columns())
+	assert.Nil(t, e.Close())
+}
+
+func TestEnricher_Lookup(t *testing.T) {
+	e, err := Open(testDatabases(t), []string{
+		"geo.city", "geo.country", "geo.region", "geo.latitude", "geo.city.names.en", "geo.location",
+		"asn.asn", "asn.org", "asn.internal", "asn.not_exists",
+	})
+	assert.Nil(t, err)
+	defer e.Close()
+
+	values, err := e.Lookup("1.1.1.1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Beijing", "CN", "Beijing", "39.9", "Beijing", `{"latitude":39.9,"longitude":116.4}`,
+		"4808", "China Unicom Beijing Province Network", "", ""}, values)
+
+	values, err = e.Lookup("8.8.8.8")
+	assert.Nil(t, err)
+	assert.Equal(t, "CN", values[1])
+
+	values, err = e.Lookup("200.1.1.1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"", "US", "", "", "", "", "15169", "GOOGLE", "true", ""}, values)
+
+	values, err = e.Lookup("not ip")
+	assert.Nil(t, err)
+	assert.Equal(t, make([]string, 10), values)
+}
+
+func TestEnricher_Enrich(t *testing.T) {
+	e, err := Open(testDatabases(t), []string{"geo.country", "asn.org"})
+	assert.Nil(t, err)
+	defer e.Close()
+
+	fields, rows, err := e.Enrich([]string{"port", "ip"}, [][]string{
+		{"80", "1.1.1.1"},
+		{"443", "200.1.1.1"},
+		{"22"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"port", "ip", "geo.country", "asn.org"}, fields)
+	assert.Equal(t, [][]string{
+		{"80", "1.1.1.1", "CN", "China Unicom Beijing Province Network"},
+		{"443", "200.1.1.1", "US", "GOOGLE"},
+		{"22", "", ""},
+	}, rows)
+
+	_, _, err = e.Enrich([]string{"host"}, [][]string{{"a.com"}})
+	assert.Error(t, err)
+}
+
+func TestEnricher_Stream(t *testing.T) {
+	e, err := Open(testDatabases(t), []string{"geo.country"})
+	assert.Nil(t, err)
+	defer e.Close()
+
+	s := e.Stream(stream.FromRows([]string{"ip"}, [][]string{{"1.1.1.1"}, {"200.1.1.1"}}))
+	assert.Equal(t, []string{"ip", "geo.country"}, s.Fields())
+	rows, err := s.Collect(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1.1.1.1", "CN"}, {"200.1.1.1", "US"}}, rows)
+
+	_, err = e.Stream(stream.FromRows([]string{"host"}, [][]string{{"a.com"}})).Collect(context.Background())
+	assert.Error(t, err)
+}
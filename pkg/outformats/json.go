@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -61,3 +62,61 @@ func NewJSONWriter(w io.Writer, fields []string) *JSONWriter {
 		fields: fields,
 	}
 }
+
+// JSONReader reads json records line by line, which are written by JSONWriter
+type JSONReader struct {
+	d *json.Decoder
+}
+
+// Read reads one json record as field => value, io.EOF is returned at the end.
+// values which are not string are formatted as string.
+func (r *JSONReader) Read() (map[string]string, error) {
+	var m map[string]interface{}
+	if err := r.d.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]string, len(m))
+	for k, v := range m {
+		switch value := v.(type) {
+		case string:
+			record[k] = value
+		case nil:
+			record[k] = ""
+		case json.Number:
+			record[k] = value.String()
+		case bool:
+			record[k] = fmt.Sprint(value)
+		default:
+			d, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			record[k] = string(d)
+		}
+	}
+	return record, nil
+}
+
+// ReadAll reads all the remaining records from r.
+func (r *JSONReader) ReadAll() (records []map[string]string, err error) {
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// NewJSONReader generate json reader
+func NewJSONReader(r io.Reader) *JSONReader {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return &JSONReader{
+		d: d,
+	}
+}
@@ -1,6 +1,16 @@
 package outformats
 
+import "io"
+
 // OutWriter format writer interface
 type OutWriter interface {
 	WriteAll(records [][]string) error // 写入
 }
+
+// Close finish output of w, such as end tag of xml document, writers without end do nothing
+func Close(w OutWriter) error {
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package outformats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// TemplateFuncs helpers of TemplateWriter
+var TemplateFuncs = template.FuncMap{
+	// url host with scheme, default scheme is http, host which already has scheme is not changed
+	"url": func(host string, scheme ...string) string {
+		if len(host) == 0 || strings.Contains(host, "://") {
+			return host
+		}
+		s := "http"
+		if len(scheme) > 0 && len(scheme[0]) > 0 {
+			s = scheme[0]
+		}
+		return s + "://" + host
+	},
+	// hostport join ip and port, ipv6 is wrapped with brackets
+	"hostport": func(host string, port string) string {
+		return net.JoinHostPort(host, port)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	// truncate first n runes of s, can be used as {{.title | truncate 20}}
+	"truncate": func(n int, s string) string {
+		if n < 0 || utf8.RuneCountInString(s) <= n {
+			return s
+		}
+		return string([]rune(s)[:n])
+	},
+	// json quoted json string of s
+	"json": func(s string) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(s); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+// TemplateWriter Go text/template format writer
+type TemplateWriter struct {
+	fields  []string
+	t       *template.Template
+	newline bool // append newline after each record
+	w       *bufio.Writer
+}
+
+// Write executes template with a single record, values are accessible by field name, like {{.ip}}
+// Writes are buffered, so Flush must eventually be called to ensure
+// that the record is written to the underlying io.Writer.
+func (w *TemplateWriter) Write(records []string) error {
+	if len(records) != len(w.fields) {
+		return errors.New("records length is not equal to fields")
+	}
+
+	m := make(map[string]string)
+	for i := range w.fields {
+		m[w.fields[i]] = records[i]
+	}
+	if err := w.t.Execute(w.w, m); err != nil {
+		return err
+	}
+	if w.newline {
+		if _, err := w.w.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteAll writes multiple records to w using Write and then calls Flush,
+// returning any error from the Flush.
+func (w *TemplateWriter) WriteAll(records [][]string) error {
+	for _, record := range records {
+		err := w.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// NewTemplateWriter generate text/template writer
+// fields are names of values, fields not in fields are errors when executing,
+// a newline is appended to each record unless text ends with newline
+func NewTemplateWriter(w io.Writer, fields []string, text string) (*TemplateWriter, error) {
+	t, err := template.New("record").Funcs(TemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateWriter{
+		w:       bufio.NewWriter(w),
+		fields:  fields,
+		t:       t,
+		newline: !strings.HasSuffix(text, "\n"),
+	}, nil
+}
@@ -0,0 +1,69 @@
+package outformats
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func executeTemplate(t *testing.T, fields []string, text string, records ...[]string) (string, error) {
+	var buf bytes.Buffer
+	w, err := NewTemplateWriter(&buf, fields, text)
+	if !assert.Nil(t, err) {
+		return "", err
+	}
+	err = w.WriteAll(records)
+	return buf.String(), err
+}
+
+func TestTemplateWriter(t *testing.T) {
+	fields := []string{"ip", "port", "cert.subject"}
+	s, err := executeTemplate(t, fields, `{{.ip}}:{{.port}} {{index . "cert.subject"}}`,
+		[]string{"1.1.1.1", "80", "a"}, []string{"2.2.2.2", "443", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1:80 a\n2.2.2.2:443 b\n", s)
+
+	// 模板以换行结尾时不再追加换行
+	s, err = executeTemplate(t, fields, "{{.ip}}\n", []string{"1.1.1.1", "80", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1\n", s)
+	s, err = executeTemplate(t, fields, "{{.ip}}\n{{.port}}", []string{"1.1.1.1", "80", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1\n80\n", s)
+
+	// 不存在的字段报错
+	_, err = executeTemplate(t, fields, "{{.title}}", []string{"1.1.1.1", "80", ""})
+	assert.Contains(t, err.Error(), `map has no entry for key "title"`)
+
+	_, err = executeTemplate(t, fields, "{{.ip}}", []string{"1.1.1.1"})
+	assert.EqualError(t, err, "records length is not equal to fields")
+
+	_, err = NewTemplateWriter(&bytes.Buffer{}, fields, "{{.ip")
+	assert.Error(t, err)
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	fields := []string{"host", "protocol", "ip", "port", "title"}
+	row := []string{"a.com:8443", "https", "2001:db8::1", "8443", ` 你好，"世界" <b> `}
+
+	tests := map[string]string{
+		`{{url .host}}`:                         "http://a.com:8443",
+		`{{url .host .protocol}}`:               "https://a.com:8443",
+		`{{url "https://b.com" "http"}}`:        "https://b.com",
+		`{{url ""}}`:                            "",
+		`{{hostport .ip .port}}`:                "[2001:db8::1]:8443",
+		`{{hostport "1.1.1.1" .port}}`:          "1.1.1.1:8443",
+		`{{.title | trim | truncate 2}}`:        "你好",
+		`{{.title | trim | truncate 100}}`:      `你好，"世界" <b>`,
+		`{{.title | trim | truncate -1}}`:       `你好，"世界" <b>`,
+		`{{.title | trim | json}}`:              `"你好，\"世界\" <b>"`,
+		`{{json "a\nb\\c"}}`:                    `"a\nb\\c"`,
+		`{{.protocol | upper}} {{"A" | lower}}`: "HTTPS a",
+	}
+	for text, want := range tests {
+		s, err := executeTemplate(t, fields, text, row)
+		assert.Nil(t, err, text)
+		assert.Equal(t, want+"\n", s, text)
+	}
+}
@@ -5,15 +5,119 @@ import (
 	"encoding/xml"
 	"errors"
 	"io"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
+// xsiNamespace namespace of xsi:noNamespaceSchemaLocation
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// XMLOptions options of XMLWriter
+type XMLOptions struct {
+	Root           string // root element of document, default is results
+	Record         string // element of each record, default is result
+	Stream         bool   // one record element per line without declaration and root, for endless or appended output
+	DTD            bool   // inline DTD of elements in doctype
+	SchemaLocation string // xsd file referenced by root as xsi:noNamespaceSchemaLocation, see WriteXSD
+}
+
+// withDefaults fill default element names
+func (o XMLOptions) withDefaults() XMLOptions {
+	if len(o.Root) == 0 {
+		o.Root = "results"
+	}
+	if len(o.Record) == 0 {
+		o.Record = "result"
+	}
+	o.Root = XMLName(o.Root)
+	o.Record = XMLName(o.Record)
+	return o
+}
+
 // XMLWriter XML format writer
+// a single document of root element is written, elements of each record follow the order of fields,
+// Close must be called to end the document
 type XMLWriter struct {
-	fields []string
-	w      *bufio.Writer
+	fields  []string
+	names   []string // element names of fields
+	options XMLOptions
+	started bool
+	closed  bool
+	w       *bufio.Writer
+}
+
+// XMLName valid xml element name of field, invalid chars such as . in cert.subject are replaced by _,
+// names not starting with letter or _, or starting with xml are prefixed with _
+func XMLName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	s := sb.String()
+	if len(s) == 0 {
+		return "_"
+	}
+	first := []rune(s)[0]
+	if !(unicode.IsLetter(first) || first == '_') || strings.HasPrefix(strings.ToLower(s), "xml") {
+		s = "_" + s
+	}
+	return s
+}
+
+// XMLNames element names of fields, duplicated names after XMLName are suffixed with _2, _3 and so on
+func XMLNames(fields []string) []string {
+	names := make([]string, len(fields))
+	used := make(map[string]bool)
+	for i, field := range fields {
+		name := XMLName(field)
+		for n := 2; used[name]; n++ {
+			name = XMLName(field) + "_" + strconv.Itoa(n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// start write declaration, doctype and start tag of root
+func (w *XMLWriter) start() error {
+	if w.started || w.options.Stream {
+		return nil
+	}
+	w.started = true
+
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	if w.options.DTD {
+		sb.WriteString("<!DOCTYPE " + w.options.Root + " [\n")
+		sb.WriteString("<!ELEMENT " + w.options.Root + " (" + w.options.Record + "*)>\n")
+		if len(w.options.SchemaLocation) > 0 {
+			sb.WriteString("<!ATTLIST " + w.options.Root + " xmlns:xsi CDATA #FIXED \"" + xsiNamespace +
+				"\" xsi:noNamespaceSchemaLocation CDATA #IMPLIED>\n")
+		}
+		sb.WriteString("<!ELEMENT " + w.options.Record + " (" + strings.Join(w.names, ",") + ")>\n")
+		for _, name := range w.names {
+			sb.WriteString("<!ELEMENT " + name + " (#PCDATA)>\n")
+		}
+		sb.WriteString("]>\n")
+	}
+	sb.WriteString("<" + w.options.Root)
+	if len(w.options.SchemaLocation) > 0 {
+		sb.WriteString(` xmlns:xsi="` + xsiNamespace + `" xsi:noNamespaceSchemaLocation="`)
+		xml.EscapeText(&sb, []byte(w.options.SchemaLocation))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString(">\n")
+	_, err := w.w.WriteString(sb.String())
+	return err
 }
 
-// Write writes a single JSON record to w one line.
+// Write writes a single XML record to w one line.
 // A record is a slice of strings with each string being one field.
 // Writes are buffered, so Flush must eventually be called to ensure
 // that the record is written to the underlying io.Writer.
@@ -21,27 +125,27 @@ func (w *XMLWriter) Write(records []string) error {
 	if len(records) != len(w.fields) {
 		return errors.New("records length is not equal to fields")
 	}
-
-	m := make(result)
-	for i := range w.fields {
-		m[w.fields[i]] = records[i]
+	if w.closed {
+		return errors.New("xml writer is closed")
 	}
-	d, err := xml.Marshal(m)
-	if err != nil {
+	if err := w.start(); err != nil {
 		return err
 	}
 
-	if _, err := w.w.Write(d); err != nil {
-		return err
+	var sb strings.Builder
+	sb.WriteString("<" + w.options.Record + ">")
+	for i, name := range w.names {
+		sb.WriteString("<" + name + ">")
+		// 非法字符会被替换掉
+		xml.EscapeText(&sb, []byte(records[i]))
+		sb.WriteString("</" + name + ">")
 	}
-	if _, err := w.w.WriteString("\n"); err != nil {
-		return err
-	}
-
-	return nil
+	sb.WriteString("</" + w.options.Record + ">\n")
+	_, err := w.w.WriteString(sb.String())
+	return err
 }
 
-// WriteAll writes multiple json records to w using Write and then calls Flush,
+// WriteAll writes multiple xml records to w using Write and then calls Flush,
 // returning any error from the Flush.
 func (w *XMLWriter) WriteAll(records [][]string) error {
 	for _, record := range records {
@@ -53,37 +157,53 @@ func (w *XMLWriter) WriteAll(records [][]string) error {
 	return w.w.Flush()
 }
 
-// StringMap is a map[string]string.
-type result map[string]string
-
-// StringMap marshals into XML.
-func (s result) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-
-	tokens := []xml.Token{start}
-
-	for key, value := range s {
-		t := xml.StartElement{Name: xml.Name{"", key}}
-		tokens = append(tokens, t, xml.CharData(value), xml.EndElement{t.Name})
+// Close write end tag of root, a document without records is still written
+func (w *XMLWriter) Close() error {
+	if w.closed {
+		return nil
 	}
-
-	tokens = append(tokens, xml.EndElement{start.Name})
-
-	for _, t := range tokens {
-		err := e.EncodeToken(t)
-		if err != nil {
+	w.closed = true
+	if !w.options.Stream {
+		if err := w.start(); err != nil {
+			return err
+		}
+		if _, err := w.w.WriteString("</" + w.options.Root + ">\n"); err != nil {
 			return err
 		}
 	}
-
-	// flush to ensure tokens are written
-	return e.Flush()
+	return w.w.Flush()
 }
 
 // NewXMLWriter generate xml writer
-// fields are key field
-func NewXMLWriter(w io.Writer, fields []string) *XMLWriter {
+// fields are key field, names of elements are XMLNames of fields
+func NewXMLWriter(w io.Writer, fields []string, options ...XMLOptions) *XMLWriter {
+	var o XMLOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
 	return &XMLWriter{
-		w:      bufio.NewWriter(w),
-		fields: fields,
+		w:       bufio.NewWriter(w),
+		fields:  fields,
+		names:   XMLNames(fields),
+		options: o.withDefaults(),
+	}
+}
+
+// WriteXSD write xml schema of documents written by XMLWriter of fields and options
+func WriteXSD(w io.Writer, fields []string, options XMLOptions) error {
+	o := options.withDefaults()
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">` + "\n")
+	sb.WriteString(`  <xs:element name="` + o.Root + `">` + "\n")
+	sb.WriteString("    <xs:complexType>\n      <xs:sequence>\n")
+	sb.WriteString(`        <xs:element name="` + o.Record + `" minOccurs="0" maxOccurs="unbounded">` + "\n")
+	sb.WriteString("          <xs:complexType>\n            <xs:sequence>\n")
+	for _, name := range XMLNames(fields) {
+		sb.WriteString(`              <xs:element name="` + name + `" type="xs:string"/>` + "\n")
 	}
+	sb.WriteString("            </xs:sequence>\n          </xs:complexType>\n        </xs:element>\n")
+	sb.WriteString("      </xs:sequence>\n    </xs:complexType>\n  </xs:element>\n</xs:schema>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
 }
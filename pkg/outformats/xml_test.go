@@ -0,0 +1,160 @@
+package outformats
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// xmlTokens decode document to start element names and char data, whitespace between elements is skipped
+func xmlTokens(t *testing.T, data string) []string {
+	var tokens []string
+	d := xml.NewDecoder(strings.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			tokens = append(tokens, "<"+v.Name.Local+">")
+		case xml.CharData:
+			if s := strings.TrimSpace(string(v)); len(s) > 0 {
+				tokens = append(tokens, s)
+			}
+		}
+	}
+	return tokens
+}
+
+func TestXMLName(t *testing.T) {
+	assert.Equal(t, "ip", XMLName("ip"))
+	assert.Equal(t, "cert_subject", XMLName("cert.subject"))
+	assert.Equal(t, "as_number", XMLName("as_number"))
+	assert.Equal(t, "_xml_name", XMLName("xml_name"))
+	assert.Equal(t, "_XMLName", XMLName("XMLName"))
+	assert.Equal(t, "_1field", XMLName("1field"))
+	assert.Equal(t, "_-a", XMLName("-a"))
+	assert.Equal(t, "_", XMLName(""))
+	assert.Equal(t, "a_b", XMLName("a b"))
+
+	assert.Equal(t, []string{"cert_subject", "cert_subject_2", "cert_subject_3", "ip"},
+		XMLNames([]string{"cert.subject", "cert_subject", "cert subject", "ip"}))
+}
+
+func TestXMLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	fields := []string{"port", "ip", "cert.subject", "cert_subject", "xmlns", "1x"}
+	w := NewXMLWriter(&buf, fields)
+	assert.Nil(t, w.WriteAll([][]string{
+		{"80", "1.1.1.1", "a&b", "<c>", "x", "y"},
+		{"443", "2.2.2.2", "", "", "", ""},
+	}))
+	assert.Nil(t, w.Close())
+
+	// 元素顺序和fields一致
+	assert.Equal(t, []string{
+		"<results>",
+		"<result>", "<port>", "80", "<ip>", "1.1.1.1", "<cert_subject>", "a&b", "<cert_subject_2>", "<c>",
+		"<_xmlns>", "x", "<_1x>", "y",
+		"<result>", "<port>", "443", "<ip>", "2.2.2.2", "<cert_subject>", "<cert_subject_2>", "<_xmlns>", "<_1x>",
+	}, xmlTokens(t, buf.String()))
+	assert.True(t, strings.HasPrefix(buf.String(), xml.Header))
+	assert.True(t, strings.HasSuffix(buf.String(), "</results>\n"))
+
+	// 重复Close没有影响，Close之后不能写
+	assert.Nil(t, w.Close())
+	assert.EqualError(t, w.Write([]string{"1", "2", "3", "4", "5", "6"}), "xml writer is closed")
+	assert.EqualError(t, w.Write([]string{"1"}), "records length is not equal to fields")
+
+	// 没有记录也是完整文档
+	buf.Reset()
+	w = NewXMLWriter(&buf, []string{"ip"}, XMLOptions{Root: "hosts", Record: "host"})
+	assert.Nil(t, w.Close())
+	assert.Equal(t, xml.Header+"<hosts>\n</hosts>\n", buf.String())
+	assert.Equal(t, []string{"<hosts>"}, xmlTokens(t, buf.String()))
+}
+
+func TestXMLWriter_Stream(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf, []string{"ip", "port"}, XMLOptions{Stream: true})
+	assert.Nil(t, w.WriteAll([][]string{{"1.1.1.1", "80"}}))
+	assert.Nil(t, w.WriteAll([][]string{{"2.2.2.2", "443"}}))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, "<result><ip>1.1.1.1</ip><port>80</port></result>\n"+
+		"<result><ip>2.2.2.2</ip><port>443</port></result>\n", buf.String())
+
+	// 每行都是完整元素
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		assert.Equal(t, 5, len(xmlTokens(t, line)))
+	}
+
+	// 没有记录时没有输出
+	buf.Reset()
+	w = NewXMLWriter(&buf, []string{"ip"}, XMLOptions{Stream: true})
+	assert.Nil(t, w.Close())
+	assert.Equal(t, "", buf.String())
+}
+
+func TestXMLWriter_DTD(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf, []string{"ip", "cert.subject"}, XMLOptions{DTD: true, SchemaLocation: "a&b.xsd"})
+	assert.Nil(t, w.WriteAll([][]string{{"1.1.1.1", "a"}}))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, xml.Header+`<!DOCTYPE results [
+<!ELEMENT results (result*)>
+<!ATTLIST results xmlns:xsi CDATA #FIXED "http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation CDATA #IMPLIED>
+<!ELEMENT result (ip,cert_subject)>
+<!ELEMENT ip (#PCDATA)>
+<!ELEMENT cert_subject (#PCDATA)>
+]>
+<results xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="a&amp;b.xsd">
+<result><ip>1.1.1.1</ip><cert_subject>a</cert_subject></result>
+</results>
+`, buf.String())
+	assert.Equal(t, []string{"<results>", "<result>", "<ip>", "1.1.1.1", "<cert_subject>", "a"},
+		xmlTokens(t, buf.String()))
+
+	// 没有SchemaLocation时没有ATTLIST
+	buf.Reset()
+	w = NewXMLWriter(&buf, []string{"ip"}, XMLOptions{DTD: true})
+	assert.Nil(t, w.Close())
+	assert.Equal(t, xml.Header+`<!DOCTYPE results [
+<!ELEMENT results (result*)>
+<!ELEMENT result (ip)>
+<!ELEMENT ip (#PCDATA)>
+]>
+<results>
+</results>
+`, buf.String())
+}
+
+func TestWriteXSD(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteXSD(&buf, []string{"ip", "cert.subject"}, XMLOptions{Record: "host"}))
+	assert.Equal(t, xml.Header+`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="results">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="host" minOccurs="0" maxOccurs="unbounded">
+          <xs:complexType>
+            <xs:sequence>
+              <xs:element name="ip" type="xs:string"/>
+              <xs:element name="cert_subject" type="xs:string"/>
+            </xs:sequence>
+          </xs:complexType>
+        </xs:element>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>
+`, buf.String())
+	assert.Equal(t, 9, len(xmlTokens(t, buf.String())))
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strconv"
+)
+
+// wrapper .fofapipe is go statements, wrap them as function body to parse
+const (
+	wrapperHead = "package fofapipe\nfunc _() {\n"
+	wrapperTail = "\n}\n"
+	wrapperLine = 2 // lines of wrapperHead
+)
+
+// Parse parse .fofapipe script, each statement is a step call like:
+//
+//	FetchFofa(GetRunner(), map[string]interface{}{
+//	    "query": "body=icon",
+//	    "size": 10,
+//	})
+func Parse(src string) (*Pipeline, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", wrapperHead+src+wrapperTail, 0)
+	if err != nil {
+		return nil, fmt.Errorf("parse pipeline failed: %s", unwrapError(err))
+	}
+
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	p := &Pipeline{}
+	for _, stmt := range body.List {
+		line := fset.Position(stmt.Pos()).Line - wrapperLine
+
+		es, ok := stmt.(*ast.ExprStmt)
+		if !ok {
+			return nil, fmt.Errorf("line %d: statement should be step call", line)
+		}
+		call, ok := es.X.(*ast.CallExpr)
+		if !ok {
+			return nil, fmt.Errorf("line %d: statement should be step call", line)
+		}
+		ident, ok := call.Fun.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid step name", line)
+		}
+
+		// GetRunner() is optional, params is the map argument
+		params := Params{}
+		args := call.Args
+		if len(args) > 0 && isGetRunner(args[0]) {
+			args = args[1:]
+		}
+		if len(args) > 1 {
+			return nil, fmt.Errorf("line %d: too many arguments of %s", line, ident.Name)
+		}
+		if len(args) == 1 {
+			lit, ok := args[0].(*ast.CompositeLit)
+			if !ok {
+				return nil, fmt.Errorf("line %d: params of %s should be map[string]interface{}", line, ident.Name)
+			}
+			v, err := evalExpr(lit)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if params, ok = v.(Params); !ok {
+				return nil, fmt.Errorf("line %d: params of %s should be map[string]interface{}", line, ident.Name)
+			}
+		}
+
+		step, err := NewStep(ident.Name, params)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		p.steps = append(p.steps, namedStep{name: ident.Name, line: line, step: step})
+	}
+
+	if len(p.steps) == 0 {
+		return nil, fmt.Errorf("no step in pipeline")
+	}
+	return p, nil
+}
+
+// isGetRunner check if expr is GetRunner()
+func isGetRunner(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) > 0 {
+		return false
+	}
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "GetRunner"
+}
+
+// unwrapError fix line number of parse error
+func unwrapError(err error) string {
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		pos := list[0].Pos
+		return fmt.Sprintf("line %d: %s", pos.Line-wrapperLine, list[0].Msg)
+	}
+	return err.Error()
+}
+
+// evalExpr evaluate literal value: string, number, bool, map and slice literal
+func evalExpr(expr ast.Expr) (interface{}, error) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.STRING, token.CHAR:
+			return strconv.Unquote(e.Value)
+		case token.INT:
+			return strconv.ParseInt(e.Value, 0, 64)
+		case token.FLOAT:
+			return strconv.ParseFloat(e.Value, 64)
+		}
+	case *ast.Ident:
+		switch e.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "nil":
+			return nil, nil
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.SUB {
+			v, err := evalExpr(e.X)
+			if err != nil {
+				return nil, err
+			}
+			switch n := v.(type) {
+			case int64:
+				return -n, nil
+			case float64:
+				return -n, nil
+			}
+		}
+	case *ast.ParenExpr:
+		return evalExpr(e.X)
+	case *ast.CompositeLit:
+		switch e.Type.(type) {
+		case *ast.MapType:
+			m := Params{}
+			for _, elt := range e.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					return nil, fmt.Errorf("map element should be key: value")
+				}
+				k, err := evalExpr(kv.Key)
+				if err != nil {
+					return nil, err
+				}
+				key, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("map key should be string")
+				}
+				if m[key], err = evalExpr(kv.Value); err != nil {
+					return nil, err
+				}
+			}
+			return m, nil
+		case *ast.ArrayType:
+			var list []interface{}
+			for _, elt := range e.Elts {
+				v, err := evalExpr(elt)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported expression: %T", expr)
+}
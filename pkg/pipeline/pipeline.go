@@ -0,0 +1,281 @@
+/*
+Package pipeline go native runtime of .fofapipe scripts
+
+each statement of script is a step, steps run concurrently and records are streamed between them:
+
+	FetchFofa(GetRunner(), map[string]interface{}{"query": "port=80", "size": 100, "fields": "host,ip,port"})
+	FilterRecord(GetRunner(), map[string]interface{}{"query": `country="CN"`})
+	Output(GetRunner(), map[string]interface{}{"file": "out.csv"})
+*/
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/gofofa"
+)
+
+// channelSize buffer size of channel between steps
+const channelSize = 100
+
+// Env runtime environment of steps
+type Env struct {
+	Context context.Context
+	Client  *gofofa.Client // nil if no fofa client, steps which need it should return error
+}
+
+// Step one step of pipeline
+// in is closed when previous step is finished, emit sends record to next step
+// steps which return before in is drained are fine, the rest records are discarded
+type Step interface {
+	Run(env *Env, in <-chan *Record, emit func(*Record) error) error
+}
+
+// StepFactory create step from params of script
+type StepFactory func(params Params) (Step, error)
+
+var (
+	stepFactories   = map[string]StepFactory{}
+	stepFactoriesMu sync.RWMutex
+)
+
+// RegisterStep register step factory, name is the function name used in script
+func RegisterStep(name string, factory StepFactory) {
+	stepFactoriesMu.Lock()
+	defer stepFactoriesMu.Unlock()
+	stepFactories[name] = factory
+}
+
+// NewStep create step of name
+func NewStep(name string, params Params) (Step, error) {
+	stepFactoriesMu.RLock()
+	factory, ok := stepFactories[name]
+	stepFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown step: %s", name)
+	}
+	step, err := factory(params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return step, nil
+}
+
+type namedStep struct {
+	name string
+	line int
+	step Step
+}
+
+// StepStats rows and time of one step
+type StepStats struct {
+	Name     string        `json:"name"`
+	Line     int           `json:"line"`
+	In       int           `json:"in"`
+	Out      int           `json:"out"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (s StepStats) String() string {
+	return fmt.Sprintf("%s(line %d): in %d, out %d, %s", s.Name, s.Line, s.In, s.Out, s.Duration.Round(time.Millisecond))
+}
+
+// Pipeline parsed steps
+type Pipeline struct {
+	steps []namedStep
+}
+
+// ParseFile parse .fofapipe file
+func ParseFile(filename string) (*Pipeline, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data))
+}
+
+// Steps names of steps
+func (p *Pipeline) Steps() []string {
+	var names []string
+	for _, s := range p.steps {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// HasStep check if pipeline has step of name
+func (p *Pipeline) HasStep(name string) bool {
+	for _, s := range p.steps {
+		if s.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Run run all steps concurrently, records of last step are sent to sink, sink can be nil
+// stats of each step are returned even if error occurs
+func (p *Pipeline) Run(ctx context.Context, client *gofofa.Client, sink func(*Record) error) ([]StepStats, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		firstErr error
+		errOnce  sync.Once
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	env := &Env{Context: ctx, Client: client}
+	stats := make([]StepStats, len(p.steps))
+
+	in := make(chan *Record)
+	close(in)
+	for i := range p.steps {
+		ns := p.steps[i]
+		st := &stats[i]
+		st.Name = ns.name
+		st.Line = ns.line
+
+		out := make(chan *Record, channelSize)
+		emit := func(r *Record) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case out <- r:
+				st.Out++
+				return nil
+			}
+		}
+
+		wg.Add(1)
+		go func(in <-chan *Record) {
+			defer wg.Done()
+			defer close(out)
+			start := time.Now()
+			err := ns.step.Run(env, in, emit)
+			st.Duration = time.Since(start)
+			if err != nil && ctx.Err() == nil {
+				fail(fmt.Errorf("%s(line %d): %w", ns.name, ns.line, err))
+			}
+			// 丢弃未读取的数据，避免上游阻塞
+			for range in {
+			}
+		}(in)
+		in = out
+	}
+
+	for r := range in {
+		if sink == nil || ctx.Err() != nil {
+			continue
+		}
+		if err := sink(r); err != nil {
+			fail(err)
+		}
+	}
+	wg.Wait()
+
+	for i := 1; i < len(stats); i++ {
+		stats[i].In = stats[i-1].Out
+	}
+
+	if firstErr == nil && ctx.Err() != nil {
+		// 外部取消
+		firstErr = ctx.Err()
+	}
+	return stats, firstErr
+}
+
+// Params params of step
+type Params map[string]interface{}
+
+// String string param, def if not exists
+func (p Params) String(key string, def string) (string, error) {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s should be string", key)
+	}
+	return s, nil
+}
+
+// Int int param, def if not exists
+func (p Params) Int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%s should be int", key)
+	}
+	return int(i), nil
+}
+
+// Bool bool param, def if not exists
+func (p Params) Bool(key string, def bool) (bool, error) {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s should be bool", key)
+	}
+	return b, nil
+}
+
+// Strings list param, can be comma separated string or list of string
+func (p Params) Strings(key string) ([]string, error) {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	switch value := v.(type) {
+	case string:
+		if len(value) == 0 {
+			return nil, nil
+		}
+		return strings.Split(value, ","), nil
+	case []interface{}:
+		var list []string
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s should be list of string", key)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("%s should be string or list of string", key)
+}
+
+// Map map param, nil if not exists
+func (p Params) Map(key string) (Params, error) {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	m, ok := v.(Params)
+	if !ok {
+		return nil, fmt.Errorf("%s should be map", key)
+	}
+	return m, nil
+}
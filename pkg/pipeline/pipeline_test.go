@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testData = `{"ip":"1.1.1.1","port":"443","host":"https://a.com","title":"A","country":"CN","body":"<link rel=\"icon\" href=\"/a.ico\">"}
+{"ip":"2.2.2.2","port":"80","host":"2.2.2.2","title":"B","country":"US","body":"hello"}
+{"ip":"3.3.3.3","port":"8080","host":"3.3.3.3:8080","title":"C","country":"CN","body":""}
+{"ip":"1.1.1.1","port":"443","host":"https://a.com","title":"A","country":"CN","body":""}
+`
+
+func writeTestData(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	assert.Nil(t, os.WriteFile(filename, []byte(testData), 0644))
+	return filename
+}
+
+func runPipeline(t *testing.T, src string) ([]*Record, []StepStats, error) {
+	p, err := Parse(src)
+	if !assert.Nil(t, err) {
+		return nil, nil, err
+	}
+	var records []*Record
+	stats, err := p.Run(context.Background(), nil, func(r *Record) error {
+		records = append(records, r)
+		return nil
+	})
+	return records, stats, err
+}
+
+func TestParse(t *testing.T) {
+	data, err := os.ReadFile("../../data/dump.fofapipe")
+	assert.Nil(t, err)
+	p, err := Parse(string(data))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"FetchFofa", "AddField", "RemoveField"}, p.Steps())
+	assert.True(t, p.HasStep("FetchFofa"))
+	assert.False(t, p.HasStep("LoadFile"))
+
+	for src, msg := range map[string]string{
+		``:                                      "no step in pipeline",
+		`Unknown(GetRunner(), nil)`:             "line 1: params of Unknown should be map[string]interface{}",
+		"\nUnknown(GetRunner())":                "line 2: unknown step: Unknown",
+		`a := 1`:                                "line 1: statement should be step call",
+		`FetchFofa(GetRunner(), map[`:           "parse pipeline failed: line 2:",
+		`RemoveField(map[string]interface{}{})`: "line 1: RemoveField: fields cannot be empty",
+		`FetchFofa(map[string]interface{}{"query": 1})`:              "line 1: FetchFofa: query should be string",
+		`FetchFofa(map[string]interface{}{"query": "a", "size": x})`: "line 1: unsupported expression: *ast.Ident",
+		`FilterRecord(map[string]interface{}{"query": "a="})`:        "line 1: FilterRecord: missing value at 2",
+		`Output(map[string]interface{}{"format": "html"})`:           "line 1: Output: unknown format: html",
+	} {
+		_, err = Parse(src)
+		if assert.Error(t, err, src) {
+			assert.Contains(t, err.Error(), msg, src)
+		}
+	}
+
+	// 参数类型
+	p, err = Parse(`AddField(GetRunner(), map[string]interface{}{
+		"name": "x",
+		"value": -1.5,
+		"list": []string{"a", ` + "`b`" + `},
+		"flag": true,
+	})`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"AddField"}, p.Steps())
+}
+
+func TestPipeline_Run(t *testing.T) {
+	filename := writeTestData(t)
+	outFile := filepath.Join(t.TempDir(), "out.json")
+	records, stats, err := runPipeline(t, `
+LoadFile(GetRunner(), map[string]interface{}{"file": "`+filename+`"})
+AddField(GetRunner(), map[string]interface{}{
+    "from": map[string]interface{}{
+        "method": "grep",
+        "field": "body",
+        "value": "(?is)<link[^>]*?href=\"([^\"]+)\"",
+    },
+    "name": "icon",
+})
+RemoveField(GetRunner(), map[string]interface{}{"fields": "body,country"})
+DedupRecord(GetRunner(), map[string]interface{}{"fields": []string{"ip", "port"}})
+FilterRecord(GetRunner(), map[string]interface{}{"query": "port!=80"})
+FixUrl(GetRunner(), map[string]interface{}{"name": "url"})
+SortRecord(GetRunner(), map[string]interface{}{"field": "port", "desc": true})
+Output(GetRunner(), map[string]interface{}{"file": "`+outFile+`", "fields": "ip,port,url,icon"})
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, []string{"host", "ip", "port", "title", "icon", "url"}, records[0].Fields())
+	port, _ := records[0].Get("port")
+	assert.Equal(t, int64(8080), port)
+	assert.Equal(t, "http://3.3.3.3:8080", records[0].String("url"))
+	assert.Equal(t, "https://a.com", records[1].String("url"))
+	assert.Equal(t, "/a.ico", records[1].String("icon"))
+
+	var counts [][2]int
+	for _, s := range stats {
+		counts = append(counts, [2]int{s.In, s.Out})
+	}
+	assert.Equal(t, [][2]int{{0, 4}, {4, 4}, {4, 4}, {4, 3}, {3, 2}, {2, 2}, {2, 2}, {2, 2}}, counts)
+	assert.Equal(t, "LoadFile", stats[0].Name)
+	assert.Equal(t, 2, stats[0].Line)
+	assert.Contains(t, stats[0].String(), "LoadFile(line 2): in 0, out 4")
+
+	data, err := os.ReadFile(outFile)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"icon":"","ip":"3.3.3.3","port":"8080","url":"http://3.3.3.3:8080"}
+{"icon":"/a.ico","ip":"1.1.1.1","port":"443","url":"https://a.com"}
+`, string(data))
+
+	// stats
+	records, _, err = runPipeline(t, `
+LoadFile(GetRunner(), map[string]interface{}{"file": "`+filename+`"})
+Stats(GetRunner(), map[string]interface{}{"fields": "country,port", "size": 1})
+`)
+	assert.Nil(t, err)
+	var lines []string
+	for _, r := range records {
+		lines = append(lines, strings.Join(r.Row(r.Fields()), ","))
+	}
+	assert.Equal(t, []string{"country,CN,3", "port,443,2"}, lines)
+
+	// 错误
+	_, stats, err = runPipeline(t, `
+LoadFile(GetRunner(), map[string]interface{}{"file": "`+filename+`"})
+FetchFofa(GetRunner(), map[string]interface{}{"query": "port=80"})
+`)
+	assert.EqualError(t, err, "FetchFofa(line 3): fofa client is required")
+	assert.Equal(t, 2, len(stats))
+
+	_, _, err = runPipeline(t, `LoadFile(GetRunner(), map[string]interface{}{"file": "not_exists.json"})`)
+	assert.Contains(t, err.Error(), "LoadFile(line 1): open not_exists.json")
+}
+
+func TestPipeline_RunOutputXML(t *testing.T) {
+	filename := writeTestData(t)
+	outFile := filepath.Join(t.TempDir(), "out.xml")
+	_, _, err := runPipeline(t, `
+LoadFile(GetRunner(), map[string]interface{}{"file": "`+filename+`"})
+Output(GetRunner(), map[string]interface{}{"file": "`+outFile+`", "fields": "ip,port"})
+`)
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(outFile)
+	assert.Nil(t, err)
+	var doc struct {
+		XMLName xml.Name `xml:"results"`
+		Results []struct {
+			IP   string `xml:"ip"`
+			Port string `xml:"port"`
+		} `xml:"result"`
+	}
+	assert.Nil(t, xml.Unmarshal(data, &doc))
+	assert.Equal(t, 4, len(doc.Results))
+	assert.Equal(t, "1.1.1.1", doc.Results[0].IP)
+	assert.Equal(t, "443", doc.Results[0].Port)
+	assert.True(t, strings.HasSuffix(string(data), "</results>\n"))
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "csv", nil)
+	for i := 0; i < writerBatchSize+1; i++ {
+		r := NewRecordFromRow([]string{"ip", "port"}, []string{"1.1.1.1", "80"})
+		assert.Nil(t, w.Write(r))
+	}
+	assert.Equal(t, writerBatchSize, strings.Count(buf.String(), "\n"))
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, writerBatchSize+1, strings.Count(buf.String(), "\n"))
+	assert.Nil(t, w.Flush())
+
+	// xml文档需要Close结束
+	buf.Reset()
+	w = NewWriter(&buf, "xml", nil)
+	assert.Nil(t, w.Write(NewRecordFromRow([]string{"ip", "port"}, []string{"1.1.1.1", "80"})))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, xml.Header+"<results>\n<result><ip>1.1.1.1</ip><port>80</port></result>\n</results>\n", buf.String())
+
+	// 没有记录时，有fields输出空文档
+	buf.Reset()
+	w = NewWriter(&buf, "xml", []string{"ip"})
+	assert.Nil(t, w.Close())
+	assert.Equal(t, xml.Header+"<results>\n</results>\n", buf.String())
+
+	buf.Reset()
+	w = NewWriter(&buf, "xml", nil)
+	assert.Nil(t, w.Close())
+	assert.Equal(t, "", buf.String())
+}
+
+func TestRecord(t *testing.T) {
+	r := NewRecordFromRow([]string{"ip", "port", "as_number"}, []string{"1.1.1.1", "80", "x"})
+	v, _ := r.Get("as_number")
+	assert.Equal(t, "x", v)
+	r.Set("score", 1.5)
+	r.Set("ok", true)
+	r.Remove("ip")
+	r.Remove("not_exists")
+	assert.Equal(t, []string{"port", "as_number", "score", "ok"}, r.Fields())
+	assert.Equal(t, map[string]string{"port": "80", "as_number": "x", "score": "1.5", "ok": "true"}, r.StringMap())
+	assert.Equal(t, -1, compareValues(int64(9), int64(10)))
+	assert.Equal(t, 1, compareValues("9", "10"))
+	assert.Equal(t, 0, compareValues(1.0, int64(1)))
+}
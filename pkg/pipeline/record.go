@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// intFields fields converted to int64 when rows are loaded
+var intFields = map[string]bool{
+	"port":        true,
+	"as_number":   true,
+	"status_code": true,
+}
+
+// Record one row passed between steps, field order is kept
+// values are typed: string, int64, float64 or bool
+type Record struct {
+	fields []string
+	values map[string]interface{}
+}
+
+// NewRecord create empty record
+func NewRecord() *Record {
+	return &Record{values: make(map[string]interface{})}
+}
+
+// NewRecordFromRow create record from fofa row, known numeric fields are converted to int64
+func NewRecordFromRow(fields []string, row []string) *Record {
+	r := NewRecord()
+	for i, f := range fields {
+		if i < len(row) {
+			r.Set(f, typedValue(f, row[i]))
+		}
+	}
+	return r
+}
+
+// typedValue convert string value of field to its type
+func typedValue(field string, value string) interface{} {
+	if intFields[field] {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return value
+}
+
+// Fields field names in order
+func (r *Record) Fields() []string {
+	return r.fields
+}
+
+// Get value of field
+func (r *Record) Get(field string) (interface{}, bool) {
+	v, ok := r.values[field]
+	return v, ok
+}
+
+// String value of field as string, empty if not exists
+func (r *Record) String(field string) string {
+	v, ok := r.values[field]
+	if !ok || v == nil {
+		return ""
+	}
+	switch value := v.(type) {
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// Set value of field, new field is appended to the end
+func (r *Record) Set(field string, value interface{}) {
+	if _, ok := r.values[field]; !ok {
+		r.fields = append(r.fields, field)
+	}
+	r.values[field] = value
+}
+
+// Remove field
+func (r *Record) Remove(field string) {
+	if _, ok := r.values[field]; !ok {
+		return
+	}
+	delete(r.values, field)
+	for i, f := range r.fields {
+		if f == field {
+			r.fields = append(r.fields[:i:i], r.fields[i+1:]...)
+			break
+		}
+	}
+}
+
+// Row values of fields as strings
+func (r *Record) Row(fields []string) []string {
+	row := make([]string, 0, len(fields))
+	for _, f := range fields {
+		row = append(row, r.String(f))
+	}
+	return row
+}
+
+// StringMap all values as strings
+func (r *Record) StringMap() map[string]string {
+	m := make(map[string]string, len(r.fields))
+	for _, f := range r.fields {
+		m[f] = r.String(f)
+	}
+	return m
+}
+
+// compareValues compare two values, numbers are compared by value, others by string
+func compareValues(a, b interface{}) int {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case int64:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,521 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/fofaquery"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+)
+
+func init() {
+	RegisterStep("FetchFofa", newFetchFofa)
+	RegisterStep("LoadFile", newLoadFile)
+	RegisterStep("AddField", newAddField)
+	RegisterStep("RemoveField", newRemoveField)
+	RegisterStep("FilterRecord", newFilterRecord)
+	RegisterStep("DedupRecord", newDedupRecord)
+	RegisterStep("SortRecord", newSortRecord)
+	RegisterStep("FixUrl", newFixUrl)
+	RegisterStep("Stats", newStats)
+	RegisterStep("Output", newOutput)
+}
+
+// StepFunc adapter of function to Step
+type StepFunc func(env *Env, in <-chan *Record, emit func(*Record) error) error
+
+// Run call f
+func (f StepFunc) Run(env *Env, in <-chan *Record, emit func(*Record) error) error {
+	return f(env, in, emit)
+}
+
+// mapStep call f for each record, record is dropped if f returns nil
+func mapStep(f func(r *Record) *Record) Step {
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		for r := range in {
+			if r = f(r); r == nil {
+				continue
+			}
+			if err := emit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// newFetchFofa fetch data from fofa, params: query, size(default 100, -1 means all), fields(default host,ip,port), full
+func newFetchFofa(params Params) (Step, error) {
+	query, err := params.String("query", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(query) == 0 {
+		return nil, errors.New("query cannot be empty")
+	}
+	size, err := params.Int("size", 100)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		fields = []string{"host", "ip", "port"}
+	}
+	full, err := params.Bool("full", false)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := 1000
+	if size > 0 && size < batchSize {
+		batchSize = size
+	}
+
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		if env.Client == nil {
+			return errors.New("fofa client is required")
+		}
+		return env.Client.DumpSearch(query, size, batchSize, fields, func(res [][]string, allSize int) error {
+			for _, row := range res {
+				if err := emit(NewRecordFromRow(fields, row)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, gofofa.SearchOptions{
+			Full: full,
+		})
+	}), nil
+}
+
+// newLoadFile load json file written by outformats.JSONWriter, params: file
+func newLoadFile(params Params) (Step, error) {
+	file, err := params.String("file", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(file) == 0 {
+		return nil, errors.New("file cannot be empty")
+	}
+
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		reader := outformats.NewJSONReader(f)
+		for {
+			m, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			fields := make([]string, 0, len(m))
+			for k := range m {
+				fields = append(fields, k)
+			}
+			sort.Strings(fields)
+			r := NewRecord()
+			for _, k := range fields {
+				r.Set(k, typedValue(k, m[k]))
+			}
+			if err = emit(r); err != nil {
+				return err
+			}
+		}
+	}), nil
+}
+
+// newAddField add field, params: name, value or from{method: grep, field, value}
+// grep sets the first submatch of regexp, or the whole match if no group, empty if not matched
+func newAddField(params Params) (Step, error) {
+	name, err := params.String("name", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(name) == 0 {
+		return nil, errors.New("name cannot be empty")
+	}
+	from, err := params.Map("from")
+	if err != nil {
+		return nil, err
+	}
+	if from == nil {
+		value := params["value"]
+		return mapStep(func(r *Record) *Record {
+			r.Set(name, value)
+			return r
+		}), nil
+	}
+
+	method, err := from.String("method", "grep")
+	if err != nil {
+		return nil, err
+	}
+	if method != "grep" {
+		return nil, fmt.Errorf("unknown method of from: %s", method)
+	}
+	field, err := from.String("field", "")
+	if err != nil {
+		return nil, err
+	}
+	expr, err := from.String("value", "")
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp: %w", err)
+	}
+
+	return mapStep(func(r *Record) *Record {
+		var value string
+		if m := re.FindStringSubmatch(r.String(field)); len(m) > 1 {
+			value = m[1]
+		} else if len(m) == 1 {
+			value = m[0]
+		}
+		r.Set(name, value)
+		return r
+	}), nil
+}
+
+// newRemoveField remove fields, params: fields
+func newRemoveField(params Params) (Step, error) {
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("fields cannot be empty")
+	}
+	return mapStep(func(r *Record) *Record {
+		for _, f := range fields {
+			r.Remove(f)
+		}
+		return r
+	}), nil
+}
+
+// newFilterRecord keep records matched fofa query syntax, params: query
+func newFilterRecord(params Params) (Step, error) {
+	query, err := params.String("query", "")
+	if err != nil {
+		return nil, err
+	}
+	expr, err := fofaquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return mapStep(func(r *Record) *Record {
+		if expr.Match(r.StringMap()) {
+			return r
+		}
+		return nil
+	}), nil
+}
+
+// newDedupRecord drop records with same values of fields, params: fields(default all fields)
+func newDedupRecord(params Params) (Step, error) {
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		seen := make(map[string]bool)
+		return mapStep(func(r *Record) *Record {
+			keyFields := fields
+			if len(keyFields) == 0 {
+				keyFields = r.Fields()
+			}
+			var sb strings.Builder
+			for _, f := range keyFields {
+				sb.WriteString(f)
+				sb.WriteByte(0)
+				sb.WriteString(r.String(f))
+				sb.WriteByte(0)
+			}
+			key := sb.String()
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			return r
+		}).Run(env, in, emit)
+	}), nil
+}
+
+// newSortRecord sort records by field, all records are buffered, params: field, desc
+func newSortRecord(params Params) (Step, error) {
+	field, err := params.String("field", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(field) == 0 {
+		return nil, errors.New("field cannot be empty")
+	}
+	desc, err := params.Bool("desc", false)
+	if err != nil {
+		return nil, err
+	}
+
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		var records []*Record
+		for r := range in {
+			records = append(records, r)
+		}
+		value := func(r *Record) interface{} {
+			if v, ok := r.Get(field); ok && v != nil {
+				return v
+			}
+			return ""
+		}
+		sort.SliceStable(records, func(i, j int) bool {
+			c := compareValues(value(records[i]), value(records[j]))
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+		for _, r := range records {
+			if err := emit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// newFixUrl convert host field to url, protocol and cert fields are used if exist
+// params: field(default host), name(default same as field), urlPrefix, schemeMap(map of protocol to scheme)
+func newFixUrl(params Params) (Step, error) {
+	field, err := params.String("field", "host")
+	if err != nil {
+		return nil, err
+	}
+	name, err := params.String("name", field)
+	if err != nil {
+		return nil, err
+	}
+	urlPrefix, err := params.String("urlPrefix", "")
+	if err != nil {
+		return nil, err
+	}
+	sm, err := params.Map("schemeMap")
+	if err != nil {
+		return nil, err
+	}
+	custom := gofofa.SchemeMap{}
+	for k, v := range sm {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("scheme of %s should be string", k)
+		}
+		custom[k] = s
+	}
+	schemeMap := gofofa.DefaultSchemeMap.Merge(custom)
+
+	return mapStep(func(r *Record) *Record {
+		host := r.String(field)
+		if len(host) == 0 {
+			return r
+		}
+		if len(urlPrefix) > 0 && !strings.Contains(host, "://") {
+			r.Set(name, urlPrefix+host)
+		} else {
+			r.Set(name, schemeMap.URL(host, r.String("protocol"), r.String("cert")))
+		}
+		return r
+	}), nil
+}
+
+// newStats count values of fields, emits records of field,value,count after all records are read
+// params: fields, size(top values of each field, default 10, -1 means all)
+func newStats(params Params) (Step, error) {
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("fields cannot be empty")
+	}
+	size, err := params.Int("size", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		counts := make([]map[string]int64, len(fields))
+		for i := range counts {
+			counts[i] = make(map[string]int64)
+		}
+		for r := range in {
+			for i, f := range fields {
+				if _, ok := r.Get(f); ok {
+					counts[i][r.String(f)]++
+				}
+			}
+		}
+
+		for i, f := range fields {
+			values := make([]string, 0, len(counts[i]))
+			for v := range counts[i] {
+				values = append(values, v)
+			}
+			sort.Slice(values, func(a, b int) bool {
+				ca, cb := counts[i][values[a]], counts[i][values[b]]
+				if ca != cb {
+					return ca > cb
+				}
+				return values[a] < values[b]
+			})
+			if size >= 0 && len(values) > size {
+				values = values[:size]
+			}
+			for _, v := range values {
+				r := NewRecord()
+				r.Set("field", f)
+				r.Set("value", v)
+				r.Set("count", counts[i][v])
+				if err := emit(r); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}), nil
+}
+
+// newOutput write records to file and pass them to next step
+// params: file(empty means stdout), format(csv/json/xml, default by file extension or csv), fields(default fields of first record)
+func newOutput(params Params) (Step, error) {
+	file, err := params.String("file", "")
+	if err != nil {
+		return nil, err
+	}
+	format, err := params.String("format", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(format) == 0 {
+		format = "csv"
+		for _, ext := range []string{"json", "xml"} {
+			if strings.HasSuffix(strings.ToLower(file), "."+ext) {
+				format = ext
+			}
+		}
+	}
+	if format != "csv" && format != "json" && format != "xml" {
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+
+	return StepFunc(func(env *Env, in <-chan *Record, emit func(*Record) error) error {
+		var w io.Writer = os.Stdout
+		if len(file) > 0 {
+			f, err := os.Create(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		writer := NewWriter(w, format, fields)
+		for r := range in {
+			if err := writer.Write(r); err != nil {
+				return err
+			}
+			if err := emit(r); err != nil {
+				return err
+			}
+		}
+		return writer.Close()
+	}), nil
+}
+
+// writerBatchSize records written in one batch
+const writerBatchSize = 100
+
+// Writer write records with outformats writers, fields are decided by first record if not set
+type Writer struct {
+	w      io.Writer
+	format string
+	fields []string
+	out    outformats.OutWriter
+	rows   [][]string
+}
+
+// NewWriter create records writer, format can be csv/json/xml
+func NewWriter(w io.Writer, format string, fields []string) *Writer {
+	return &Writer{w: w, format: format, fields: fields}
+}
+
+// init create outformats writer of fields
+func (w *Writer) init() {
+	switch w.format {
+	case "json":
+		w.out = outformats.NewJSONWriter(w.w, w.fields)
+	case "xml":
+		w.out = outformats.NewXMLWriter(w.w, w.fields)
+	default:
+		w.out = outformats.NewCSVWriter(w.w)
+	}
+}
+
+// Write buffer one record
+func (w *Writer) Write(r *Record) error {
+	if w.out == nil {
+		if len(w.fields) == 0 {
+			w.fields = r.Fields()
+		}
+		w.init()
+	}
+	w.rows = append(w.rows, r.Row(w.fields))
+	if len(w.rows) >= writerBatchSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush write buffered records
+func (w *Writer) Flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	err := w.out.WriteAll(w.rows)
+	w.rows = w.rows[:0]
+	return err
+}
+
+// Close flush buffered records and end the output, such as root end tag of xml document,
+// an empty document is written if fields are set but there is no record
+func (w *Writer) Close() error {
+	if w.out == nil {
+		if len(w.fields) == 0 {
+			return nil
+		}
+		w.init()
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return outformats.Close(w.out)
+}
@@ -0,0 +1,288 @@
+/*
+Package probe check liveness of fofa results
+
+method of each target is decided by protocol/host/port:
+
+	http/https, web protocols, url host, port 80/443/8443   HTTP GET, status code and title are recorded
+	tls, ssl, ftps, ldaps, imaps, pop3s, smtps              TLS handshake
+	others                                                  TCP connect
+*/
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/stream"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// MethodTCP tcp connect
+	MethodTCP = "tcp"
+	// MethodTLS tls handshake
+	MethodTLS = "tls"
+	// MethodHTTP http get
+	MethodHTTP = "http"
+
+	maxBodySize = 64 * 1024 // body read for title
+)
+
+// Columns names of columns appended by probe
+var Columns = []string{"alive", "live_status_code", "live_title", "latency", "error"}
+
+// tlsProtocols protocols probed by tls handshake
+var tlsProtocols = map[string]bool{
+	"tls":   true,
+	"ssl":   true,
+	"ftps":  true,
+	"ldaps": true,
+	"imaps": true,
+	"pop3s": true,
+	"smtps": true,
+}
+
+var titleRegexp = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// Target one fofa row to probe, Host or IP is required
+type Target struct {
+	Host     string // host field, can be url like https://example.com
+	IP       string
+	Port     string
+	Protocol string
+	Cert     string // has cert means https
+}
+
+// Result result of probe
+type Result struct {
+	Alive      bool
+	StatusCode int    // 0 if not http
+	Title      string // title of html page
+	Latency    time.Duration
+	Err        error
+}
+
+// Row values of Columns
+func (r Result) Row() []string {
+	row := []string{strconv.FormatBool(r.Alive), "", r.Title, "", ""}
+	if r.StatusCode > 0 {
+		row[1] = strconv.Itoa(r.StatusCode)
+	}
+	if r.Alive {
+		row[3] = r.Latency.Round(time.Microsecond).String()
+	}
+	if r.Err != nil {
+		row[4] = r.Err.Error()
+	}
+	return row
+}
+
+// Options of prober
+type Options struct {
+	Concurrency int              // targets probed concurrently of Stream, default 20
+	Timeout     time.Duration    // timeout of each target, default 5s
+	Rate        float64          // max targets per second of Stream, 0 means no limit
+	UserAgent   string           // user agent of http probe
+	SchemeMap   gofofa.SchemeMap // protocol => url scheme, merged with gofofa.DefaultSchemeMap
+}
+
+// Prober check liveness of targets
+type Prober struct {
+	options Options
+	dialer  *net.Dialer
+	client  *http.Client
+}
+
+// New create prober
+func New(options Options) *Prober {
+	if options.Concurrency < 1 {
+		options.Concurrency = 20
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.UserAgent == "" {
+		options.UserAgent = "Mozilla/5.0 (compatible; gofofa)"
+	}
+	options.SchemeMap = gofofa.DefaultSchemeMap.Merge(options.SchemeMap)
+
+	dialer := &net.Dialer{}
+	return &Prober{
+		options: options,
+		dialer:  dialer,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:       dialer.DialContext,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+				Proxy:             http.ProxyFromEnvironment,
+			},
+			// 只关心目标本身是否存活，不跟随跳转
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Method probe method and address of target, address is url for http and host:port for others
+func (p *Prober) Method(t Target) (method string, address string, err error) {
+	protocol := strings.ToLower(t.Protocol)
+
+	// host is url
+	if strings.Contains(t.Host, "://") {
+		u, err := url.Parse(t.Host)
+		if err != nil {
+			return "", "", err
+		}
+		switch scheme := strings.ToLower(u.Scheme); {
+		case scheme == "http" || scheme == "https":
+			return MethodHTTP, u.String(), nil
+		case tlsProtocols[scheme]:
+			return MethodTLS, u.Host, nil
+		default:
+			return MethodTCP, u.Host, nil
+		}
+	}
+
+	hostname, port := t.IP, t.Port
+	if len(t.Host) > 0 {
+		if h, p, err := net.SplitHostPort(t.Host); err == nil {
+			hostname, port = h, p
+		} else {
+			hostname = t.Host
+		}
+	}
+	if len(hostname) == 0 {
+		return "", "", errors.New("no host or ip")
+	}
+	if len(port) == 0 {
+		port = "80"
+	}
+	hostport := net.JoinHostPort(strings.Trim(hostname, "[]"), port)
+
+	switch {
+	case tlsProtocols[protocol]:
+		return MethodTLS, hostport, nil
+	case protocol == "" || protocol == "unknown":
+		if port != "80" && port != "443" && port != "8443" && len(t.Cert) == 0 {
+			return MethodTCP, hostport, nil
+		}
+	default:
+		// 映射为空的协议比如elastic也是web服务
+		if scheme, ok := p.options.SchemeMap[protocol]; !ok || (scheme != "" && scheme != "http" && scheme != "https") {
+			return MethodTCP, hostport, nil
+		}
+	}
+	return MethodHTTP, p.options.SchemeMap.URL(hostport, protocol, t.Cert), nil
+}
+
+// Probe check liveness of target
+func (p *Prober) Probe(ctx context.Context, t Target) (r Result) {
+	method, address, err := p.Method(t)
+	if err != nil {
+		r.Err = err
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	switch method {
+	case MethodHTTP:
+		r.StatusCode, r.Title, r.Err = p.probeHTTP(ctx, address)
+	case MethodTLS:
+		r.Err = p.probeConn(ctx, address, true)
+	default:
+		r.Err = p.probeConn(ctx, address, false)
+	}
+	r.Latency = time.Since(start)
+	r.Alive = r.Err == nil
+	return
+}
+
+// probeConn tcp connect, and tls handshake if needed
+func (p *Prober) probeConn(ctx context.Context, address string, withTLS bool) error {
+	conn, err := p.dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !withTLS {
+		return nil
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: host})
+	return tlsConn.HandshakeContext(ctx)
+}
+
+// probeHTTP http get, returns status code and title
+func (p *Prober) probeHTTP(ctx context.Context, address string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("User-Agent", p.options.UserAgent)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	// 标题读取失败不影响存活
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	return resp.StatusCode, ExtractTitle(body), nil
+}
+
+// ExtractTitle title of html, whitespaces are collapsed
+func ExtractTitle(body []byte) string {
+	m := titleRegexp.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
+
+// Stream probe rows of stream concurrently and append Columns, order of rows is not kept
+// fields of stream should contain host or ip,port, protocol and cert are used if exist
+func (p *Prober) Stream(s *stream.Stream) *stream.Stream {
+	hostIndex, ipIndex, portIndex := s.FieldIndex("host"), s.FieldIndex("ip"), s.FieldIndex("port")
+	protocolIndex, certIndex := s.FieldIndex("protocol"), s.FieldIndex("cert")
+	value := func(row []string, i int) string {
+		if i >= 0 && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	if p.options.Rate > 0 {
+		s = s.Batch(1).Throttle(rate.Limit(p.options.Rate), 1)
+	}
+	fields := append(append([]string{}, s.Fields()...), Columns...)
+	return s.Parallel(p.options.Concurrency, fields, func(ctx context.Context, row []string) ([][]string, error) {
+		r := p.Probe(ctx, Target{
+			Host:     value(row, hostIndex),
+			IP:       value(row, ipIndex),
+			Port:     value(row, portIndex),
+			Protocol: value(row, protocolIndex),
+			Cert:     value(row, certIndex),
+		})
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
+		return [][]string{append(append([]string{}, row...), r.Row()...)}, nil
+	})
+}
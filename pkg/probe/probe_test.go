@@ -0,0 +1,143 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/gofofa/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProber_Method(t *testing.T) {
+	p := New(Options{})
+	tests := []struct {
+		target  Target
+		method  string
+		address string
+	}{
+		{Target{Host: "https://example.com:8443"}, MethodHTTP, "https://example.com:8443"},
+		{Target{Host: "ldaps://1.1.1.1"}, MethodTLS, "1.1.1.1"},
+		{Target{Host: "redis://1.1.1.1:6379"}, MethodTCP, "1.1.1.1:6379"},
+		{Target{IP: "1.1.1.1", Port: "80"}, MethodHTTP, "http://1.1.1.1"},
+		{Target{IP: "1.1.1.1", Port: "443"}, MethodHTTP, "https://1.1.1.1"},
+		{Target{IP: "1.1.1.1", Port: "22"}, MethodTCP, "1.1.1.1:22"},
+		{Target{IP: "1.1.1.1", Port: "9200", Protocol: "elastic"}, MethodHTTP, "http://1.1.1.1:9200"},
+		{Target{IP: "1.1.1.1", Port: "9000", Cert: "x"}, MethodHTTP, "https://1.1.1.1:9000"},
+		{Target{IP: "1.1.1.1", Port: "6379", Protocol: "redis"}, MethodTCP, "1.1.1.1:6379"},
+		{Target{IP: "1.1.1.1", Port: "993", Protocol: "imaps"}, MethodTLS, "1.1.1.1:993"},
+		{Target{Host: "example.com", IP: "1.1.1.1", Port: "8080", Protocol: "http"}, MethodHTTP, "http://example.com:8080"},
+		{Target{Host: "example.com:81", Port: "81"}, MethodTCP, "example.com:81"},
+		{Target{IP: "2001:db8::1", Port: "443", Protocol: "https"}, MethodHTTP, "https://[2001:db8::1]"},
+		{Target{Host: "example.com"}, MethodHTTP, "http://example.com"},
+	}
+	for _, tt := range tests {
+		method, address, err := p.Method(tt.target)
+		assert.Nil(t, err, tt.target)
+		assert.Equal(t, tt.method, method, tt.target)
+		assert.Equal(t, tt.address, address, tt.target)
+	}
+
+	_, _, err := p.Method(Target{Port: "80"})
+	assert.Error(t, err)
+}
+
+func TestExtractTitle(t *testing.T) {
+	assert.Equal(t, "Hello World & Co", ExtractTitle([]byte("<html><TITLE id=1>\n Hello\n  World &amp; Co </TITLE>")))
+	assert.Equal(t, "", ExtractTitle([]byte("<html></html>")))
+}
+
+func TestProber_Probe(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-agent", r.UserAgent())
+		if r.URL.Path == "/login" {
+			w.Write([]byte("<title>Login</title>"))
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer ts.Close()
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	// closed port
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	closedAddr := ln.Addr().String()
+	ln.Close()
+
+	p := New(Options{Timeout: 2 * time.Second, UserAgent: "test-agent"})
+	ctx := context.Background()
+
+	r := p.Probe(ctx, Target{Host: ts.URL})
+	assert.True(t, r.Alive)
+	assert.Nil(t, r.Err)
+	assert.Equal(t, http.StatusFound, r.StatusCode)
+	assert.Equal(t, []string{"true", "302", "", r.Latency.Round(time.Microsecond).String(), ""}, r.Row())
+
+	r = p.Probe(ctx, Target{Host: ts.URL + "/login"})
+	assert.Equal(t, 200, r.StatusCode)
+	assert.Equal(t, "Login", r.Title)
+
+	// tls handshake
+	tlsHost, tlsPort, _ := net.SplitHostPort(tlsServer.Listener.Addr().String())
+	r = p.Probe(ctx, Target{IP: tlsHost, Port: tlsPort, Protocol: "tls"})
+	assert.True(t, r.Alive)
+	assert.Equal(t, 0, r.StatusCode)
+	r = p.Probe(ctx, Target{IP: tlsHost, Port: tlsPort, Protocol: "https"})
+	assert.True(t, r.Alive)
+	assert.Equal(t, 404, r.StatusCode)
+
+	// tcp connect
+	r = p.Probe(ctx, Target{Host: ts.Listener.Addr().String()})
+	assert.True(t, r.Alive)
+	closedHost, closedPort, _ := net.SplitHostPort(closedAddr)
+	r = p.Probe(ctx, Target{IP: closedHost, Port: closedPort})
+	assert.False(t, r.Alive)
+	assert.Error(t, r.Err)
+	row := r.Row()
+	assert.Equal(t, "false", row[0])
+	assert.Equal(t, "", row[3])
+	assert.NotEmpty(t, row[4])
+
+	// tls handshake of plain http fails
+	tsHost, tsPort, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	r = p.Probe(ctx, Target{IP: tsHost, Port: tsPort, Protocol: "tls"})
+	assert.False(t, r.Alive)
+
+	r = p.Probe(ctx, Target{})
+	assert.False(t, r.Alive)
+	assert.Error(t, r.Err)
+}
+
+func TestProber_Stream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	defer ts.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	closedAddr := ln.Addr().String()
+	ln.Close()
+
+	p := New(Options{Concurrency: 2, Timeout: 2 * time.Second, Rate: 100})
+	s := p.Stream(stream.FromRows([]string{"host", "protocol"}, [][]string{
+		{ts.URL, "http"},
+		{closedAddr, ""},
+	}))
+	assert.Equal(t, []string{"host", "protocol", "alive", "live_status_code", "live_title", "latency", "error"}, s.Fields())
+
+	rows, err := s.Collect(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rows))
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i][2] > rows[j][2]
+	})
+	assert.Equal(t, []string{ts.URL, "http", "true", "200", "ok"}, rows[0][:5])
+	assert.Equal(t, []string{closedAddr, "", "false", "", "", ""}, rows[1][:6])
+	assert.NotEmpty(t, rows[1][6])
+}
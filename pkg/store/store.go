@@ -0,0 +1,365 @@
+/*
+Package store local asset inventory backed by an embedded database
+
+assets are keyed by ip+port or configurable fields, each asset tracks
+first seen and last seen time, source queries, user tags and notes.
+*/
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	assetsBucket = []byte("assets")
+	metaBucket   = []byte("meta")
+	keysKey      = []byte("keys")
+)
+
+// ErrNotFound asset not found
+var ErrNotFound = errors.New("asset not found")
+
+// DefaultKeys default key fields of asset
+var DefaultKeys = []string{"ip", "port"}
+
+// Asset one asset in store
+type Asset struct {
+	Key       string            `json:"key"`
+	Fields    map[string]string `json:"fields"`     // latest values of fields
+	FirstSeen time.Time         `json:"first_seen"` // first upsert time
+	LastSeen  time.Time         `json:"last_seen"`  // last upsert time
+	Queries   []string          `json:"queries"`    // source queries
+	Tags      []string          `json:"tags,omitempty"`
+	Note      string            `json:"note,omitempty"`
+}
+
+// HasTag check if asset has tag
+func (a *Asset) HasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Value of field, virtual fields are supported: key, first_seen, last_seen, queries, tags, note
+func (a *Asset) Value(field string) string {
+	switch field {
+	case "key":
+		return a.Key
+	case "first_seen":
+		return a.FirstSeen.Format(time.RFC3339)
+	case "last_seen":
+		return a.LastSeen.Format(time.RFC3339)
+	case "queries":
+		return strings.Join(a.Queries, "\n")
+	case "tags":
+		return strings.Join(a.Tags, ",")
+	case "note":
+		return a.Note
+	}
+	return a.Fields[field]
+}
+
+// Store local asset inventory
+type Store struct {
+	db   *bolt.DB
+	keys []string
+	now  func() time.Time
+}
+
+// Open open or create store file
+// keys are key fields of asset, they are saved when store is created, nil means use saved keys or DefaultKeys
+func Open(filename string, keys []string) (*Store, error) {
+	db, err := bolt.Open(filename, 0644, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{db: db, now: time.Now}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(assetsBucket); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		if v := meta.Get(keysKey); v != nil {
+			savedKeys := strings.Split(string(v), ",")
+			if len(keys) > 0 && strings.Join(keys, ",") != string(v) {
+				return fmt.Errorf("store keys is %s, can not open with %s", v, strings.Join(keys, ","))
+			}
+			s.keys = savedKeys
+			return nil
+		}
+
+		if len(keys) == 0 {
+			keys = DefaultKeys
+		}
+		s.keys = keys
+		return meta.Put(keysKey, []byte(strings.Join(keys, ",")))
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close close store
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Keys key fields of asset
+func (s *Store) Keys() []string {
+	return s.keys
+}
+
+// Key of row, values of key fields joined by comma
+func (s *Store) Key(fields []string, row []string) (string, error) {
+	values := make([]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		index := -1
+		for i, f := range fields {
+			if f == k {
+				index = i
+				break
+			}
+		}
+		if index == -1 || index >= len(row) {
+			return "", fmt.Errorf("key field %s is not in fields", k)
+		}
+		values = append(values, row[index])
+	}
+	return strings.Join(values, ","), nil
+}
+
+func getAsset(b *bolt.Bucket, key string) (*Asset, error) {
+	v := b.Get([]byte(key))
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	var a Asset
+	if err := json.Unmarshal(v, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func putAsset(b *bolt.Bucket, a *Asset) error {
+	v, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(a.Key), v)
+}
+
+// Upsert insert or update rows from search results
+// query is source query of rows, fields are field names of rows
+// returns count of added and updated assets
+func (s *Store) Upsert(query string, fields []string, rows [][]string) (added int, updated int, err error) {
+	now := s.now()
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(assetsBucket)
+		for _, row := range rows {
+			key, err := s.Key(fields, row)
+			if err != nil {
+				return err
+			}
+
+			a, err := getAsset(b, key)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				a = &Asset{
+					Key:       key,
+					Fields:    make(map[string]string),
+					FirstSeen: now,
+				}
+				added++
+			case err != nil:
+				return err
+			default:
+				updated++
+			}
+
+			for i, f := range fields {
+				if i < len(row) {
+					a.Fields[f] = row[i]
+				}
+			}
+			a.LastSeen = now
+			if len(query) > 0 && !containsString(a.Queries, query) {
+				a.Queries = append(a.Queries, query)
+			}
+			if err = putAsset(b, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// Get asset of key
+func (s *Store) Get(key string) (a *Asset, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		a, err = getAsset(tx.Bucket(assetsBucket), key)
+		return err
+	})
+	return
+}
+
+// update asset of key
+func (s *Store) update(key string, f func(a *Asset)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(assetsBucket)
+		a, err := getAsset(b, key)
+		if err != nil {
+			return err
+		}
+		f(a)
+		return putAsset(b, a)
+	})
+}
+
+// Tag add tags to asset
+func (s *Store) Tag(key string, tags ...string) error {
+	return s.update(key, func(a *Asset) {
+		for _, tag := range tags {
+			if !a.HasTag(tag) {
+				a.Tags = append(a.Tags, tag)
+			}
+		}
+		sort.Strings(a.Tags)
+	})
+}
+
+// Untag remove tags from asset
+func (s *Store) Untag(key string, tags ...string) error {
+	return s.update(key, func(a *Asset) {
+		var newTags []string
+		for _, t := range a.Tags {
+			if !containsString(tags, t) {
+				newTags = append(newTags, t)
+			}
+		}
+		a.Tags = newTags
+	})
+}
+
+// SetNote set note of asset
+func (s *Store) SetNote(key string, note string) error {
+	return s.update(key, func(a *Asset) {
+		a.Note = note
+	})
+}
+
+// Delete asset of key
+func (s *Store) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(assetsBucket)
+		if b.Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Filter conditions of query, empty condition is ignored
+type Filter struct {
+	Tags   []string          // has all tags
+	Source string            // source query contains
+	Since  time.Time         // last seen after
+	Before time.Time         // last seen before
+	Where  map[string]string // field equals value, virtual fields are supported
+}
+
+// Match check if asset matches filter
+func (f Filter) Match(a *Asset) bool {
+	for _, tag := range f.Tags {
+		if !a.HasTag(tag) {
+			return false
+		}
+	}
+	if len(f.Source) > 0 {
+		found := false
+		for _, q := range a.Queries {
+			if strings.Contains(q, f.Source) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && a.LastSeen.Before(f.Since) {
+		return false
+	}
+	if !f.Before.IsZero() && !a.LastSeen.Before(f.Before) {
+		return false
+	}
+	for k, v := range f.Where {
+		if a.Value(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Each iterate all assets in key order, stop if fn returns error
+func (s *Store) Each(fn func(a *Asset) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(assetsBucket).ForEach(func(k, v []byte) error {
+			var a Asset
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			return fn(&a)
+		})
+	})
+}
+
+// Query assets matched filter
+func (s *Store) Query(filter Filter) (assets []*Asset, err error) {
+	err = s.Each(func(a *Asset) error {
+		if filter.Match(a) {
+			assets = append(assets, a)
+		}
+		return nil
+	})
+	return
+}
+
+// Records convert assets to records of fields, for outformats writers
+func Records(assets []*Asset, fields []string) [][]string {
+	records := make([][]string, 0, len(assets))
+	for _, a := range assets {
+		record := make([]string, 0, len(fields))
+		for _, f := range fields {
+			record = append(record, a.Value(f))
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "assets.db")
+	s, err := Open(filename, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultKeys, s.Keys())
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// 插入
+	fields := []string{"ip", "port", "title"}
+	added, updated, err := s.Upsert("port=80", fields, [][]string{
+		{"1.1.1.1", "80", "a"},
+		{"2.2.2.2", "80", "b"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 0, updated)
+
+	// 更新
+	now = now.Add(time.Hour)
+	added, updated, err = s.Upsert("title=a", []string{"ip", "port", "title", "server"}, [][]string{
+		{"1.1.1.1", "80", "a2", "nginx"},
+		{"3.3.3.3", "22", "", ""},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 1, updated)
+
+	a, err := s.Get("1.1.1.1,80")
+	assert.Nil(t, err)
+	assert.Equal(t, "a2", a.Fields["title"])
+	assert.Equal(t, "nginx", a.Fields["server"])
+	assert.Equal(t, []string{"port=80", "title=a"}, a.Queries)
+	assert.Equal(t, now.Add(-time.Hour), a.FirstSeen.UTC())
+	assert.Equal(t, now, a.LastSeen.UTC())
+
+	// 缺少key字段
+	_, _, err = s.Upsert("", []string{"ip"}, [][]string{{"1.1.1.1"}})
+	assert.EqualError(t, err, "key field port is not in fields")
+
+	// 标签和备注
+	assert.Nil(t, s.Tag("1.1.1.1,80", "prod", "web", "prod"))
+	assert.Nil(t, s.Tag("2.2.2.2,80", "web"))
+	assert.Nil(t, s.SetNote("1.1.1.1,80", "owner: ops"))
+	assert.True(t, errors.Is(s.Tag("9.9.9.9,80", "web"), ErrNotFound))
+	a, _ = s.Get("1.1.1.1,80")
+	assert.Equal(t, []string{"prod", "web"}, a.Tags)
+	assert.Equal(t, "owner: ops", a.Value("note"))
+	assert.Equal(t, "prod,web", a.Value("tags"))
+	assert.Nil(t, s.Untag("1.1.1.1,80", "prod"))
+	a, _ = s.Get("1.1.1.1,80")
+	assert.Equal(t, []string{"web"}, a.Tags)
+
+	// 查询
+	assets, err := s.Query(Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(assets))
+	assets, _ = s.Query(Filter{Tags: []string{"web"}})
+	assert.Equal(t, 2, len(assets))
+	assets, _ = s.Query(Filter{Source: "title="})
+	assert.Equal(t, 2, len(assets))
+	assets, _ = s.Query(Filter{Since: now})
+	assert.Equal(t, 2, len(assets))
+	assets, _ = s.Query(Filter{Before: now})
+	assert.Equal(t, 1, len(assets))
+	assets, _ = s.Query(Filter{Where: map[string]string{"server": "nginx", "key": "1.1.1.1,80"}})
+	assert.Equal(t, 1, len(assets))
+	assert.Equal(t, [][]string{{"1.1.1.1", "80", "a2", "web", "2022-01-01T00:00:00Z"}},
+		Records(assets, []string{"ip", "port", "title", "tags", "first_seen"}))
+
+	// 删除
+	assert.Nil(t, s.Delete("3.3.3.3,22"))
+	assert.True(t, errors.Is(s.Delete("3.3.3.3,22"), ErrNotFound))
+	_, err = s.Get("3.3.3.3,22")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Nil(t, s.Close())
+
+	// 重新打开使用保存的key
+	s, err = Open(filename, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ip", "port"}, s.Keys())
+	assets, _ = s.Query(Filter{})
+	assert.Equal(t, 2, len(assets))
+	assert.Nil(t, s.Close())
+
+	_, err = Open(filename, []string{"host"})
+	assert.Contains(t, err.Error(), "store keys is ip,port")
+
+	// 自定义key
+	s, err = Open(filepath.Join(t.TempDir(), "hosts.db"), []string{"host"})
+	assert.Nil(t, err)
+	_, _, err = s.Upsert("", []string{"host"}, [][]string{{"a.com"}, {"a.com"}})
+	assert.Nil(t, err)
+	a, err = s.Get("a.com")
+	assert.Nil(t, err)
+	assert.Nil(t, a.Queries)
+	assert.Nil(t, s.Close())
+}
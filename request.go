@@ -3,6 +3,7 @@ package gofofa
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -37,7 +38,7 @@ func readAll(reader io.Reader, size int) ([]byte, error) {
 }
 
 // just fetch fofa body, no need to unmarshal
-func (c *Client) fetchBody(apiURI string, params map[string]string) (body []byte, traceId string, err error) {
+func (c *Client) fetchBody(ctx context.Context, apiURI string, params map[string]string) (body []byte, traceId string, err error) {
 	var req *http.Request
 	var resp *http.Response
 
@@ -45,7 +46,7 @@ func (c *Client) fetchBody(apiURI string, params map[string]string) (body []byte
 	c.logger.Debugf("fetch fofa: %s", apiURI)
 	//c.logger.Debugf("fetch fofa: %s", fullURL)
 
-	req, err = http.NewRequest("GET", fullURL, nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	req.Header.Set("Accept-Encoding", "gzip")
 	//requestDump, _ := httputil.DumpRequestOut(req, false)
 	//log.Println(string(requestDump))
@@ -115,7 +116,15 @@ func (c *Client) fetchBody(apiURI string, params map[string]string) (body []byte
 
 // Fetch http request and parse as json return to v
 func (c *Client) Fetch(apiURI string, params map[string]string, v CommonResp) (err error) {
-	content, traceId, err := c.fetchBody(apiURI, params)
+	return c.FetchContext(context.Background(), apiURI, params, v)
+}
+
+// FetchContext http request with context and parse as json return to v
+func (c *Client) FetchContext(ctx context.Context, apiURI string, params map[string]string, v CommonResp) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	content, traceId, err := c.fetchBody(ctx, apiURI, params)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,213 @@
+package gofofa
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoNewSample no new row is found in MaxTries queries
+var ErrNoNewSample = errors.New("no new sample found")
+
+// SampleOptions options of Sampler
+type SampleOptions struct {
+	Seed       int64         // seed of random timestamps, 0 means random
+	End        time.Time     // timestamps are drawn from the year before End, default is now, or start of today when Seed is set
+	DistinctBy []string      // fields identifying a row, rows seen before are skipped, such as ip
+	MaxTries   int           // queries tried for one new row, default is 10
+	Interval   time.Duration // sleep between queries
+	Search     SearchOptions // options of each search
+}
+
+// SampleStratum sub query of stratified sampling
+type SampleStratum struct {
+	Query  string   // base query with values of stratify fields
+	Values []string // values of stratify fields
+	Count  int      // rows matched by stats
+	Size   int      // rows to draw, set by AllocateSample
+}
+
+// Sampler draw random rows of query, one row each query
+type Sampler struct {
+	c           *Client
+	fields      []string
+	fetchFields []string // fields and missing distinct fields
+	distinct    []int    // indexes of distinct fields in fetchFields
+	options     SampleOptions
+	rand        *rand.Rand
+	seen        map[string]bool
+	queries     int
+}
+
+// NewSampler create sampler of fields, fields of DistinctBy are fetched even if they are not in fields
+func (c *Client) NewSampler(fields []string, options SampleOptions) *Sampler {
+	if options.MaxTries < 1 {
+		options.MaxTries = 10
+	}
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if options.End.IsZero() {
+		options.End = time.Now()
+		if options.Seed != 0 {
+			y, m, d := options.End.Date()
+			options.End = time.Date(y, m, d, 0, 0, 0, 0, options.End.Location())
+		}
+	}
+
+	s := &Sampler{
+		c:           c,
+		fields:      fields,
+		fetchFields: append([]string{}, fields...),
+		options:     options,
+		rand:        rand.New(rand.NewSource(seed)),
+		seen:        make(map[string]bool),
+	}
+	for _, field := range options.DistinctBy {
+		i := fieldIndexOf(s.fetchFields, field)
+		if i < 0 {
+			i = len(s.fetchFields)
+			s.fetchFields = append(s.fetchFields, field)
+		}
+		s.distinct = append(s.distinct, i)
+	}
+	return s
+}
+
+// fieldIndexOf index of field in fields, -1 if not exists
+func fieldIndexOf(fields []string, field string) int {
+	for i, f := range fields {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// Queries queries sent
+func (s *Sampler) Queries() int {
+	return s.queries
+}
+
+// End end of time range of random timestamps
+func (s *Sampler) End() time.Time {
+	return s.options.End
+}
+
+// randomQuery query with random before= timestamp in the year before End, host= and ip= queries are not changed
+func (s *Sampler) randomQuery(query string) string {
+	if strings.HasPrefix(query, "host=") || strings.HasPrefix(query, "ip=") {
+		return query
+	}
+	max := s.options.End
+	min := max.AddDate(-1, 0, 0)
+	sec := s.rand.Int63n(max.Unix()-min.Unix()) + min.Unix()
+	ts := time.Unix(sec, 0).In(max.Location()).Format("2006-01-02 15:04:05")
+	return "(" + query + `) && before="` + ts + `"`
+}
+
+// Next one random row of query which is not seen before
+// ErrNoNewSample is returned when every try gets no row or duplicated row
+func (s *Sampler) Next(ctx context.Context, query string) ([]string, error) {
+	for i := 0; i < s.options.MaxTries; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if s.queries > 0 && s.options.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(s.options.Interval):
+			}
+		}
+
+		s.queries++
+		res, err := s.c.HostSearch(s.randomQuery(query), 1, s.fetchFields, s.options.Search)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == 0 {
+			continue
+		}
+
+		row := res[0]
+		if len(s.distinct) > 0 {
+			var values []string
+			for _, j := range s.distinct {
+				values = append(values, row[j])
+			}
+			key := strings.Join(values, "\x00")
+			if s.seen[key] {
+				s.c.logger.Debugln("sample is duplicated:", values)
+				continue
+			}
+			s.seen[key] = true
+		}
+		return row[:len(s.fields)], nil
+	}
+	return nil, ErrNoNewSample
+}
+
+// SampleStrata strata of query by values of fields, each field splits strata of previous fields with stats
+// only top statsSize values of each field are used, value of stratum is name_code of stats item if there is, or name
+func (c *Client) SampleStrata(query string, fields []string, statsSize int, options ...SearchOptions) ([]SampleStratum, error) {
+	strata := []SampleStratum{{Query: query}}
+	for _, field := range fields {
+		var next []SampleStratum
+		for _, stratum := range strata {
+			res, err := c.Stats(stratum.Query, statsSize, []string{field}, options...)
+			if err != nil {
+				return nil, err
+			}
+			for _, so := range res {
+				for _, item := range so.Items {
+					value := item.Code
+					if len(value) == 0 {
+						value = item.Name
+					}
+					next = append(next, SampleStratum{
+						Query:  "(" + stratum.Query + ") && " + fofaQuote(field, value),
+						Values: append(append([]string{}, stratum.Values...), item.Name),
+						Count:  item.Count,
+					})
+				}
+			}
+		}
+		strata = next
+	}
+	return strata, nil
+}
+
+// AllocateSample split size to strata by proportion of Count, with largest remainder method
+func AllocateSample(strata []SampleStratum, size int) {
+	total := 0
+	for _, s := range strata {
+		total += s.Count
+	}
+	if total == 0 {
+		return
+	}
+
+	left := size
+	remainders := make([]int, len(strata))
+	for i := range strata {
+		n := size * strata[i].Count
+		strata[i].Size = n / total
+		remainders[i] = n % total
+		left -= strata[i].Size
+	}
+	order := make([]int, len(strata))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; i < left; i++ {
+		strata[order[i]].Size++
+	}
+}
@@ -0,0 +1,147 @@
+package gofofa
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllocateSample(t *testing.T) {
+	strata := []SampleStratum{{Count: 50}, {Count: 30}, {Count: 20}}
+	AllocateSample(strata, 10)
+	assert.Equal(t, []int{5, 3, 2}, []int{strata[0].Size, strata[1].Size, strata[2].Size})
+
+	strata = []SampleStratum{{Count: 1}, {Count: 1}, {Count: 1}}
+	AllocateSample(strata, 2)
+	assert.Equal(t, []int{1, 1, 0}, []int{strata[0].Size, strata[1].Size, strata[2].Size})
+
+	strata = []SampleStratum{{Count: 7}, {Count: 2}, {Count: 1}}
+	AllocateSample(strata, 3)
+	assert.Equal(t, []int{2, 1, 0}, []int{strata[0].Size, strata[1].Size, strata[2].Size})
+
+	strata = []SampleStratum{{}}
+	AllocateSample(strata, 3)
+	assert.Equal(t, 0, strata[0].Size)
+}
+
+func TestSampler(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(bindQueryHandle("/api/v1/search/all", func(w http.ResponseWriter, r *http.Request) {
+		q, _ := base64.StdEncoding.DecodeString(r.FormValue("qbase64"))
+		queries = append(queries, string(q))
+		host := map[string]string{"ip": "1.1.1.1", "port": "80"}
+		if len(queries)%2 == 0 {
+			host = map[string]string{"ip": "2.2.2.2", "port": "443"}
+		}
+		var row []string
+		for _, field := range strings.Split(r.FormValue("fields"), ",") {
+			row = append(row, host[field])
+		}
+		results := [][]string{row}
+		if strings.Contains(string(q), "empty") {
+			results = [][]string{}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"error": false, "size": 1, "page": 1, "results": results})
+		w.Write(b)
+	})))
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	// 相同种子，相同时间戳
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := cli.NewSampler([]string{"port"}, SampleOptions{Seed: 1, End: end})
+	row, err := s.Next(ctx, "port=80")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"80"}, row)
+	s = cli.NewSampler([]string{"port"}, SampleOptions{Seed: 1, End: end})
+	_, err = s.Next(ctx, "port=80")
+	assert.Nil(t, err)
+	assert.Equal(t, queries[0], queries[1])
+	assert.Contains(t, queries[0], `(port=80) && before="2023-`)
+	assert.Equal(t, end, s.End())
+
+	// before=对整个查询生效
+	_, err = s.Next(ctx, `app="a" || app="b"`)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(queries[2], `(app="a" || app="b") && before="2023-`), queries[2])
+
+	// 去重，只返回需要的字段
+	queries = nil
+	s = cli.NewSampler([]string{"port"}, SampleOptions{DistinctBy: []string{"ip"}, MaxTries: 3})
+	row, err = s.Next(ctx, "ip=1.1.1.1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"80"}, row)
+	row, err = s.Next(ctx, "ip=1.1.1.1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"443"}, row)
+	_, err = s.Next(ctx, "ip=1.1.1.1")
+	assert.ErrorIs(t, err, ErrNoNewSample)
+	assert.Equal(t, 5, s.Queries())
+	assert.Equal(t, "ip=1.1.1.1", queries[0])
+
+	_, err = s.Next(ctx, "empty=1")
+	assert.ErrorIs(t, err, ErrNoNewSample)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = s.Next(canceled, "port=80")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClient_SampleStrata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(bindQueryHandle("/api/v1/search/stats", func(w http.ResponseWriter, r *http.Request) {
+		var aggs map[string]interface{}
+		switch r.FormValue("fields") {
+		case "country":
+			// code不是完整查询，不使用
+			aggs = map[string]interface{}{"countries": []map[string]interface{}{
+				{"name": "China", "name_code": "CN", "count": 30, "code": "Q049"},
+				{"name": "Japan", "name_code": "JP", "count": 10},
+			}}
+		case "protocol":
+			// 没有name_code时使用name
+			aggs = map[string]interface{}{"protocol": []map[string]interface{}{
+				{"name": "http", "count": 6},
+				{"name": "https", "count": 4},
+			}}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"error": false, "aggs": aggs})
+		w.Write(b)
+	})))
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+
+	strata, err := cli.SampleStrata("port=80", []string{"country", "protocol"}, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []SampleStratum{
+		{Query: `((port=80) && country="CN") && protocol="http"`, Values: []string{"China", "http"}, Count: 6},
+		{Query: `((port=80) && country="CN") && protocol="https"`, Values: []string{"China", "https"}, Count: 4},
+		{Query: `((port=80) && country="JP") && protocol="http"`, Values: []string{"Japan", "http"}, Count: 6},
+		{Query: `((port=80) && country="JP") && protocol="https"`, Values: []string{"Japan", "https"}, Count: 4},
+	}, strata)
+
+	// 查询语句有||时，分层条件对整个查询生效
+	strata, err = cli.SampleStrata(`app="a" || app="b"`, []string{"country"}, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []SampleStratum{
+		{Query: `(app="a" || app="b") && country="CN"`, Values: []string{"China"}, Count: 30},
+		{Query: `(app="a" || app="b") && country="JP"`, Values: []string{"Japan"}, Count: 10},
+	}, strata)
+
+	strata, err = cli.SampleStrata("port=80", nil, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []SampleStratum{{Query: "port=80"}}, strata)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // StatsResults /search/stats api results
@@ -24,21 +25,79 @@ func (s *StatsResults) SetTraceId(traceId string) {
 
 // StatsItem one stats item
 type StatsItem struct {
-	Name  string
-	Count int
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Code  string `json:"name_code,omitempty"` // value to query of the item if name is not, such as CN of country China
 }
 
 // StatsObject one stats object
 type StatsObject struct {
-	Name  string
-	Items []StatsItem
+	Name  string      `json:"name"`
+	Items []StatsItem `json:"items"`
 }
 
-// Stats aggs fofa host data
+// StatsResult typed /search/stats api results
+type StatsResult struct {
+	Aggs           []StatsObject  `json:"aggs"`           // aggregations of fields
+	Distinct       map[string]int `json:"distinct"`       // distinct counts, such as ip, domain
+	LastUpdateTime FofaTime       `json:"lastupdatetime"` // last update time of data
+}
+
+// statsFieldAliases some fields are renamed in aggs
+var statsFieldAliases = map[string][]string{
+	"country": {"countries"},
+	"asn":     {"as_number"},
+	"org":     {"as_organization"},
+}
+
+// parseStatsObject parse one aggs field
+func parseStatsObject(name string, v interface{}) (so StatsObject, err error) {
+	so.Name = name
+	objArray, ok := v.([]interface{})
+	if !ok {
+		err = fmt.Errorf("malformed stats aggs of %s: %v", name, v)
+		return
+	}
+	for _, obj := range objArray {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			err = fmt.Errorf("malformed stats item of %s: %v", name, obj)
+			return
+		}
+		var item StatsItem
+		switch n := m["name"].(type) {
+		case string:
+			item.Name = n
+		case float64:
+			item.Name = strconv.FormatFloat(n, 'f', -1, 64)
+		default:
+			err = fmt.Errorf("malformed stats item name of %s: %v", name, m["name"])
+			return
+		}
+		count, ok := m["count"].(float64)
+		if !ok {
+			err = fmt.Errorf("malformed stats item count of %s: %v", name, m["count"])
+			return
+		}
+		item.Count = int(count)
+		if code, ok := m["name_code"].(string); ok {
+			item.Code = code
+		}
+		so.Items = append(so.Items, item)
+	}
+	return
+}
+
+// StatsDetail aggs fofa host data, with distinct counts and last update time
 // query fofa query string
 // size data size
 // fields' field of fofa host struct
-func (c *Client) Stats(query string, size int, fields []string) (res []StatsObject, err error) {
+// options for search, only Full is used
+func (c *Client) StatsDetail(query string, size int, fields []string, options ...SearchOptions) (res StatsResult, err error) {
+	var full bool
+	if len(options) > 0 {
+		full = options[0].Full
+	}
 	if len(fields) == 0 {
 		fields = []string{"title", "country"}
 	}
@@ -49,7 +108,7 @@ func (c *Client) Stats(query string, size int, fields []string) (res []StatsObje
 			"qbase64": base64.StdEncoding.EncodeToString([]byte(query)),
 			"size":    strconv.Itoa(size),
 			"fields":  strings.Join(fields, ","),
-			"full":    "false", // 是否全部数据，非一年内
+			"full":    strconv.FormatBool(full), // 是否全部数据，非一年内
 		},
 		&sr)
 	if err != nil {
@@ -70,29 +129,58 @@ func (c *Client) Stats(query string, size int, fields []string) (res []StatsObje
 	}
 
 	for _, rawField := range fields {
-		field := rawField
 		// 有些字段要进行改名
-		switch rawField {
-		case "country":
-			field = "countries"
+		v, ok := sr.Aggs[rawField]
+		for _, alias := range statsFieldAliases[rawField] {
+			if ok {
+				break
+			}
+			v, ok = sr.Aggs[alias]
+		}
+		if !ok || v == nil {
+			continue
 		}
 
-		if v, ok := sr.Aggs[field]; ok {
-			if objArray, ok := v.([]interface{}); ok && len(objArray) > 0 {
-				so := StatsObject{
-					Name: rawField,
-				}
-				for _, obj := range objArray {
-					obj := obj.(map[string]interface{})
-					so.Items = append(so.Items, StatsItem{
-						Name:  obj["name"].(string),
-						Count: int(obj["count"].(float64)),
-					})
-				}
-				res = append(res, so)
-			}
+		var so StatsObject
+		so, err = parseStatsObject(rawField, v)
+		if err != nil {
+			return
+		}
+		if len(so.Items) > 0 {
+			res.Aggs = append(res.Aggs, so)
+		}
+	}
+
+	res.Distinct = make(map[string]int)
+	for k, v := range sr.Distinct {
+		count, ok := v.(float64)
+		if !ok {
+			err = fmt.Errorf("malformed stats distinct of %s: %v", k, v)
+			return
+		}
+		res.Distinct[k] = int(count)
+	}
+
+	if len(sr.LastUpdateTime) > 0 {
+		if res.LastUpdateTime.Time, err = time.Parse(fofaTimeLayout, sr.LastUpdateTime); err != nil {
+			err = fmt.Errorf("malformed stats lastupdatetime: %w", err)
+			return
 		}
 	}
 
 	return
 }
+
+// Stats aggs fofa host data
+// query fofa query string
+// size data size
+// fields' field of fofa host struct
+// options for search, only Full is used
+func (c *Client) Stats(query string, size int, fields []string, options ...SearchOptions) (res []StatsObject, err error) {
+	var sr StatsResult
+	sr, err = c.StatsDetail(query, size, fields, options...)
+	if err != nil {
+		return
+	}
+	return sr.Aggs, nil
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestClient_Stats(t *testing.T) {
@@ -54,3 +55,53 @@ func TestClient_Stats(t *testing.T) {
 	res, err = cli.Stats("port=80", 5, nil)
 	assert.Error(t, err)
 }
+
+func TestClient_StatsDetail(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(bindQueryHandle("/api/v1/search/stats", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("fields") {
+		case "port,asn":
+			if r.FormValue("full") != "true" {
+				w.Write([]byte(`{"error":true,"errmsg":"full not set"}`))
+				return
+			}
+			w.Write([]byte(`{"error":false,"distinct":{"ip":10,"domain":2},"aggs":{"port":[{"count":8,"name":80},{"count":2,"name":"443"}],"as_number":[{"count":10,"name":"4134"}]},"lastupdatetime":"2022-05-18 20:00:00"}`))
+		case "title":
+			w.Write([]byte(`{"error":false,"distinct":{"ip":10},"aggs":{"title":["abc"]}}`))
+		case "server":
+			w.Write([]byte(`{"error":false,"distinct":{"ip":10},"aggs":{"server":[{"count":"1","name":"nginx"}]}}`))
+		case "os":
+			w.Write([]byte(`{"error":false,"distinct":{"ip":10},"aggs":{"os":{"name":"linux"}}}`))
+		case "domain":
+			w.Write([]byte(`{"error":false,"distinct":{"ip":"10"},"aggs":{}}`))
+		case "icp":
+			w.Write([]byte(`{"error":false,"aggs":{"icp":[{"count":1,"name":null}]},"lastupdatetime":"abc"}`))
+		case "fid":
+			w.Write([]byte(`{"error":false,"aggs":{},"lastupdatetime":"abc"}`))
+		}
+	})))
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+
+	var res StatsResult
+	_, err = cli.StatsDetail("port=80", 5, []string{"port", "asn"})
+	assert.EqualError(t, err, "full not set")
+
+	res, err = cli.StatsDetail("port=80", 5, []string{"port", "asn"}, SearchOptions{Full: true})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"ip": 10, "domain": 2}, res.Distinct)
+	assert.Equal(t, time.Date(2022, 5, 18, 20, 0, 0, 0, time.UTC), res.LastUpdateTime.Time)
+	assert.Equal(t, 2, len(res.Aggs))
+	assert.Equal(t, "port", res.Aggs[0].Name)
+	assert.Equal(t, StatsItem{Name: "80", Count: 8}, res.Aggs[0].Items[0])
+	assert.Equal(t, "asn", res.Aggs[1].Name)
+	assert.Equal(t, "4134", res.Aggs[1].Items[0].Name)
+
+	// 异常数据不panic
+	for _, field := range []string{"title", "server", "os", "domain", "icp", "fid"} {
+		_, err = cli.StatsDetail("port=80", 5, []string{field})
+		assert.Contains(t, err.Error(), "malformed", field)
+	}
+}
@@ -0,0 +1,210 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+)
+
+// readBatchSize rows of one batch when reading json/csv
+const readBatchSize = 1000
+
+// FromRows stream of rows in memory
+func FromRows(fields []string, rows [][]string) *Stream {
+	return New(fields, func(ctx context.Context, emit EmitFunc) error {
+		return emit(rows)
+	})
+}
+
+// FromHostSearch stream of HostSearch results, all results are emitted as one batch
+// partial results are emitted before budget error is returned
+func FromHostSearch(client *gofofa.Client, query string, size int, fields []string, options ...gofofa.SearchOptions) *Stream {
+	return New(fields, func(ctx context.Context, emit EmitFunc) error {
+		res, err := client.HostSearch(query, size, fields, options...)
+		if err != nil && !errors.Is(err, gofofa.ErrBudgetExceeded) {
+			return err
+		}
+		if errEmit := emit(res); errEmit != nil {
+			return errEmit
+		}
+		return err
+	})
+}
+
+// ProgressFunc called after each page of DumpSearch with count of fetched rows and total rows of query
+type ProgressFunc func(fetched int, total int)
+
+// FromDumpSearch stream of DumpSearch results, each page is emitted as one batch, progress can be nil
+func FromDumpSearch(client *gofofa.Client, query string, size int, batchSize int, fields []string, progress ProgressFunc, options ...gofofa.SearchOptions) *Stream {
+	return New(fields, func(ctx context.Context, emit EmitFunc) error {
+		fetched := 0
+		return client.DumpSearch(query, size, batchSize, fields, func(res [][]string, allSize int) error {
+			fetched += len(res)
+			if progress != nil {
+				progress(fetched, allSize)
+			}
+			return emit(res)
+		}, options...)
+	})
+}
+
+// FromLines stream of non-empty lines of reader, each line is a row of one field, such as queries from stdin
+// each line is emitted as soon as it is read, the stream can be run only once
+func FromLines(r io.Reader, field string) *Stream {
+	return New([]string{field}, func(ctx context.Context, emit EmitFunc) error {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if len(line) == 0 {
+				continue
+			}
+			if err := emit([][]string{{line}}); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	})
+}
+
+// FromJSON stream of json records written by outformats.JSONWriter
+// if fields is empty, fields of first record in alphabetical order are used, so the first record is read immediately
+// the stream can be run only once
+func FromJSON(r io.Reader, fields []string) (*Stream, error) {
+	reader := outformats.NewJSONReader(r)
+
+	var first map[string]string
+	if len(fields) == 0 {
+		var err error
+		first, err = reader.Read()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		for k := range first {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+	}
+
+	toRow := func(record map[string]string) []string {
+		row := make([]string, 0, len(fields))
+		for _, f := range fields {
+			row = append(row, record[f])
+		}
+		return row
+	}
+
+	return New(fields, func(ctx context.Context, emit EmitFunc) error {
+		var batch [][]string
+		if first != nil {
+			batch = append(batch, toRow(first))
+			first = nil
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			batch = append(batch, toRow(record))
+			if len(batch) == readBatchSize {
+				if err = emit(batch); err != nil {
+					return err
+				}
+				batch = nil
+			}
+		}
+		return emit(batch)
+	}), nil
+}
+
+// FromJSONFile stream of json file, - means stdin, file is closed when stream is finished
+// see FromJSON for fields
+func FromJSONFile(filename string, fields []string) (*Stream, error) {
+	return fromFile(filename, func(r io.Reader) (*Stream, error) {
+		return FromJSON(r, fields)
+	})
+}
+
+// FromCSV stream of csv rows without header, such as written by outformats.CSVWriter
+// rows are padded or truncated to fields, the stream can be run only once
+func FromCSV(r io.Reader, fields []string) *Stream {
+	return New(fields, func(ctx context.Context, emit EmitFunc) error {
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		var batch [][]string
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			row := make([]string, len(fields))
+			copy(row, record)
+			batch = append(batch, row)
+			if len(batch) == readBatchSize {
+				if err = emit(batch); err != nil {
+					return err
+				}
+				batch = nil
+			}
+		}
+		return emit(batch)
+	})
+}
+
+// FromCSVFile stream of csv file, - means stdin, file is closed when stream is finished
+func FromCSVFile(filename string, fields []string) (*Stream, error) {
+	return fromFile(filename, func(r io.Reader) (*Stream, error) {
+		return FromCSV(r, fields), nil
+	})
+}
+
+// fromFile open file and create stream of it, - means stdin
+func fromFile(filename string, open func(r io.Reader) (*Stream, error)) (*Stream, error) {
+	if filename == "-" {
+		return open(os.Stdin)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	s, err := open(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return New(s.fields, func(ctx context.Context, emit EmitFunc) error {
+		defer f.Close()
+		return s.run(ctx, emit)
+	}), nil
+}
+
+// Concat streams one by one, fields of first stream are used
+func Concat(streams ...*Stream) *Stream {
+	var fields []string
+	if len(streams) > 0 {
+		fields = streams[0].fields
+	}
+	return New(fields, func(ctx context.Context, emit EmitFunc) error {
+		for _, s := range streams {
+			if err := s.Run(ctx, emit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,308 @@
+/*
+Package stream composable operators over fofa rows
+
+a Stream is lazy, sources and operators run only when a sink is called,
+rows are pushed downstream in batches, every step is context-aware
+and the first error stops the whole stream:
+
+	n, err := stream.FromDumpSearch(client, `port=80`, -1, 1000, fields, nil).
+		Filter(func(row []string) bool { return row[1] != "" }).
+		Uniq("ip").
+		WriteTo(ctx, outformats.NewCSVWriter(os.Stdout))
+*/
+package stream
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"golang.org/x/time/rate"
+)
+
+// EmitFunc send a batch of rows to downstream
+type EmitFunc func(rows [][]string) error
+
+// RunFunc run stream, push batches to emit until done or error
+type RunFunc func(ctx context.Context, emit EmitFunc) error
+
+// Stream lazy stream of rows with same fields
+type Stream struct {
+	fields []string
+	run    RunFunc
+}
+
+// New create stream from run function, fields are field names of rows
+func New(fields []string, run RunFunc) *Stream {
+	return &Stream{fields: fields, run: run}
+}
+
+// Fields field names of rows
+func (s *Stream) Fields() []string {
+	return s.fields
+}
+
+// FieldIndex index of field, -1 if not exists
+func (s *Stream) FieldIndex(field string) int {
+	for i, f := range s.fields {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// Run run stream, f is called with each batch
+func (s *Stream) Run(ctx context.Context, f func(rows [][]string) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return s.run(ctx, func(rows [][]string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return f(rows)
+	})
+}
+
+// through create stream of same fields with operator run function
+func (s *Stream) through(f func(ctx context.Context, emit EmitFunc) EmitFunc) *Stream {
+	return &Stream{
+		fields: s.fields,
+		run: func(ctx context.Context, emit EmitFunc) error {
+			return s.Run(ctx, f(ctx, emit))
+		},
+	}
+}
+
+// Map convert each row, row is dropped if f returns nil
+func (s *Stream) Map(f func(row []string) ([]string, error)) *Stream {
+	return s.through(func(ctx context.Context, emit EmitFunc) EmitFunc {
+		return func(rows [][]string) error {
+			out := make([][]string, 0, len(rows))
+			for _, row := range rows {
+				newRow, err := f(row)
+				if err != nil {
+					return err
+				}
+				if newRow != nil {
+					out = append(out, newRow)
+				}
+			}
+			return emit(out)
+		}
+	})
+}
+
+// Filter keep rows f returns true
+func (s *Stream) Filter(f func(row []string) bool) *Stream {
+	return s.Map(func(row []string) ([]string, error) {
+		if f(row) {
+			return row, nil
+		}
+		return nil, nil
+	})
+}
+
+// Uniq drop rows with same values of key fields, all fields if keys is empty
+// unknown key fields are treated as empty values
+func (s *Stream) Uniq(keys ...string) *Stream {
+	indexes := make([]int, 0, len(keys))
+	for _, k := range keys {
+		indexes = append(indexes, s.FieldIndex(k))
+	}
+	return s.through(func(ctx context.Context, emit EmitFunc) EmitFunc {
+		seen := make(map[string]bool)
+		return func(rows [][]string) error {
+			out := make([][]string, 0, len(rows))
+			for _, row := range rows {
+				var key string
+				if len(indexes) == 0 {
+					key = strings.Join(row, "\x00")
+				} else {
+					values := make([]string, 0, len(indexes))
+					for _, i := range indexes {
+						if i >= 0 && i < len(row) {
+							values = append(values, row[i])
+						} else {
+							values = append(values, "")
+						}
+					}
+					key = strings.Join(values, "\x00")
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				out = append(out, row)
+			}
+			return emit(out)
+		}
+	})
+}
+
+// Batch regroup rows into batches of size, the last batch may be smaller
+func (s *Stream) Batch(size int) *Stream {
+	if size < 1 {
+		size = 1
+	}
+	return &Stream{
+		fields: s.fields,
+		run: func(ctx context.Context, emit EmitFunc) error {
+			var batch [][]string
+			err := s.Run(ctx, func(rows [][]string) error {
+				for _, row := range rows {
+					batch = append(batch, row)
+					if len(batch) == size {
+						if err := emit(batch); err != nil {
+							return err
+						}
+						batch = nil
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(batch) > 0 {
+				return emit(batch)
+			}
+			return nil
+		},
+	}
+}
+
+// Throttle limit batches per second, use Batch(1) before it to limit rows
+func (s *Stream) Throttle(limit rate.Limit, burst int) *Stream {
+	return s.through(func(ctx context.Context, emit EmitFunc) EmitFunc {
+		limiter := rate.NewLimiter(limit, burst)
+		return func(rows [][]string) error {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			return emit(rows)
+		}
+	})
+}
+
+// Tee write each batch to writers, and pass it to downstream
+func (s *Stream) Tee(writers ...outformats.OutWriter) *Stream {
+	return s.through(func(ctx context.Context, emit EmitFunc) EmitFunc {
+		return func(rows [][]string) error {
+			for _, w := range writers {
+				if err := w.WriteAll(rows); err != nil {
+					return err
+				}
+			}
+			return emit(rows)
+		}
+	})
+}
+
+// Parallel call f for each row with bounded workers, order of rows is not kept
+// f returns rows of fields, fields nil means same as input, no row is fine
+func (s *Stream) Parallel(workers int, fields []string, f func(ctx context.Context, row []string) ([][]string, error)) *Stream {
+	if workers < 1 {
+		workers = 1
+	}
+	if fields == nil {
+		fields = s.fields
+	}
+	return &Stream{
+		fields: fields,
+		run: func(ctx context.Context, emit EmitFunc) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			var (
+				firstErr error
+				errOnce  sync.Once
+				wg       sync.WaitGroup
+			)
+			fail := func(err error) {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+
+			inputs := make(chan []string)
+			results := make(chan [][]string)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for row := range inputs {
+						out, err := f(ctx, row)
+						if err != nil {
+							fail(err)
+							continue
+						}
+						if len(out) == 0 {
+							continue
+						}
+						select {
+						case results <- out:
+						case <-ctx.Done():
+						}
+					}
+				}()
+			}
+
+			go func() {
+				err := s.Run(ctx, func(rows [][]string) error {
+					for _, row := range rows {
+						select {
+						case inputs <- row:
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					fail(err)
+				}
+				close(inputs)
+				wg.Wait()
+				close(results)
+			}()
+
+			for rows := range results {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := emit(rows); err != nil {
+					fail(err)
+				}
+			}
+			return firstErr
+		},
+	}
+}
+
+// WriteTo write all rows to writer, returns count of rows
+func (s *Stream) WriteTo(ctx context.Context, w outformats.OutWriter) (n int, err error) {
+	err = s.Run(ctx, func(rows [][]string) error {
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		n += len(rows)
+		return nil
+	})
+	return
+}
+
+// Collect all rows
+func (s *Stream) Collect(ctx context.Context) (res [][]string, err error) {
+	err = s.Run(ctx, func(rows [][]string) error {
+		res = append(res, rows...)
+		return nil
+	})
+	return
+}
@@ -0,0 +1,249 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/gofofa"
+	"github.com/LubyRuffy/gofofa/pkg/outformats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func newTestClient(t *testing.T) *gofofa.Client {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/info/my":
+			w.Write([]byte(`{"error":false,"email":"d@d.com","isvip":true,"vip_level":3}`))
+		case "/api/v1/search/all":
+			w.Write([]byte(`{"error":false,"size":2,"results":[["1.1.1.1","80"],["2.2.2.2","443"]]}`))
+		case "/api/v1/search/next":
+			i, _ := strconv.Atoi(r.URL.Query().Get("next"))
+			next := strconv.Itoa(i + 1)
+			if i == 2 {
+				next = ""
+			}
+			b, _ := json.Marshal(map[string]interface{}{
+				"error":   false,
+				"size":    6,
+				"next":    next,
+				"results": [][]string{{strconv.Itoa(i), "80"}, {strconv.Itoa(i), "443"}},
+			})
+			w.Write(b)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := gofofa.NewClient(gofofa.WithURL(ts.URL + "?email=d@d.com&key=44444&version=v1"))
+	assert.Nil(t, err)
+	return client
+}
+
+var testRows = [][]string{
+	{"1.1.1.1", "80"},
+	{"1.1.1.1", "443"},
+	{"2.2.2.2", "80"},
+	{"1.1.1.1", "80"},
+}
+
+func TestStream_Operators(t *testing.T) {
+	ctx := context.Background()
+	fields := []string{"ip", "port"}
+	s := FromRows(fields, testRows)
+	assert.Equal(t, fields, s.Fields())
+	assert.Equal(t, 1, s.FieldIndex("port"))
+	assert.Equal(t, -1, s.FieldIndex("host"))
+
+	// map & filter
+	res, err := s.Filter(func(row []string) bool {
+		return row[1] == "80"
+	}).Map(func(row []string) ([]string, error) {
+		return []string{row[0], "8080"}, nil
+	}).Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1.1.1.1", "8080"}, {"2.2.2.2", "8080"}, {"1.1.1.1", "8080"}}, res)
+
+	_, err = s.Map(func(row []string) ([]string, error) {
+		return nil, errors.New("map failed")
+	}).Collect(ctx)
+	assert.EqualError(t, err, "map failed")
+
+	// uniq
+	res, err = s.Uniq().Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, testRows[:3], res)
+	res, err = s.Uniq("ip").Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1.1.1.1", "80"}, {"2.2.2.2", "80"}}, res)
+	res, err = s.Uniq("host").Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+
+	// batch
+	var sizes []int
+	err = s.Batch(3).Run(ctx, func(rows [][]string) error {
+		sizes = append(sizes, len(rows))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 1}, sizes)
+
+	// tee
+	var buf bytes.Buffer
+	n, err := s.Tee(outformats.NewCSVWriter(&buf)).Uniq().WriteTo(ctx, outformats.NewJSONWriter(&buf, fields))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, 4+3, strings.Count(buf.String(), "\n"))
+
+	// throttle
+	start := time.Now()
+	res, err = s.Batch(1).Throttle(rate.Every(20*time.Millisecond), 1).Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, testRows, res)
+	assert.True(t, time.Since(start) >= 60*time.Millisecond)
+
+	// sink error
+	err = s.Batch(1).Run(ctx, func(rows [][]string) error {
+		return errors.New("sink failed")
+	})
+	assert.EqualError(t, err, "sink failed")
+
+	// context
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = s.Collect(cctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestStream_Parallel(t *testing.T) {
+	ctx := context.Background()
+	s := FromRows([]string{"query"}, [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}})
+
+	var running, maxRunning int32
+	res, err := s.Parallel(2, []string{"query", "n"}, func(ctx context.Context, row []string) ([][]string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if row[0] == "c" {
+			return nil, nil
+		}
+		return [][]string{{row[0], "1"}, {row[0], "2"}}, nil
+	}).Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, len(res))
+	assert.Equal(t, int32(2), maxRunning)
+	var queries []string
+	for _, row := range res {
+		queries = append(queries, row[0])
+	}
+	sort.Strings(queries)
+	assert.Equal(t, []string{"a", "a", "b", "b", "d", "d", "e", "e"}, queries)
+
+	// error stops stream
+	var called int32
+	_, err = s.Batch(1).Parallel(1, nil, func(ctx context.Context, row []string) ([][]string, error) {
+		atomic.AddInt32(&called, 1)
+		if row[0] == "b" {
+			return nil, errors.New("worker failed")
+		}
+		return [][]string{row}, nil
+	}).Collect(ctx)
+	assert.EqualError(t, err, "worker failed")
+	assert.True(t, atomic.LoadInt32(&called) < 5)
+
+	err = s.Parallel(2, nil, func(ctx context.Context, row []string) ([][]string, error) {
+		return [][]string{row}, nil
+	}).Run(ctx, func(rows [][]string) error {
+		return errors.New("sink failed")
+	})
+	assert.EqualError(t, err, "sink failed")
+}
+
+func TestSources(t *testing.T) {
+	ctx := context.Background()
+
+	// lines
+	res, err := FromLines(strings.NewReader("a\n\n b \nc"), "query").Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"a"}, {"b"}, {"c"}}, res)
+
+	// json
+	data := `{"ip":"1.1.1.1","port":"80"}
+{"ip":"2.2.2.2","port":443,"title":"x"}
+`
+	s, err := FromJSON(strings.NewReader(data), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ip", "port"}, s.Fields())
+	res, err = s.Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1.1.1.1", "80"}, {"2.2.2.2", "443"}}, res)
+
+	s, err = FromJSON(strings.NewReader(data), []string{"title"})
+	assert.Nil(t, err)
+	res, err = s.Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{""}, {"x"}}, res)
+
+	s, err = FromJSON(strings.NewReader(""), nil)
+	assert.Nil(t, err)
+	res, err = s.Collect(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, res)
+
+	_, err = FromJSON(strings.NewReader("{"), nil)
+	assert.Error(t, err)
+	_, err = FromJSONFile("not_exists.json", nil)
+	assert.Error(t, err)
+
+	// csv
+	res, err = FromCSV(strings.NewReader("1.1.1.1,80,\"a,b\"\n2.2.2.2\n"), []string{"ip", "port"}).Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1.1.1.1", "80"}, {"2.2.2.2", ""}}, res)
+	_, err = FromCSV(strings.NewReader("a,\"b\n"), []string{"ip"}).Collect(ctx)
+	assert.Error(t, err)
+	_, err = FromCSVFile("not_exists.csv", nil)
+	assert.Error(t, err)
+
+	// concat
+	res, err = Concat(FromRows([]string{"a"}, [][]string{{"1"}}), FromRows([]string{"a"}, [][]string{{"2"}})).Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}, {"2"}}, res)
+
+	// fofa
+	client := newTestClient(t)
+	res, err = FromHostSearch(client, "port=80", 10, []string{"ip", "port"}).Collect(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1.1.1.1", "80"}, {"2.2.2.2", "443"}}, res)
+
+	var batches int
+	var progress []int
+	err = FromDumpSearch(client, "port=80", -1, 2, []string{"ip", "port"}, func(fetched int, total int) {
+		progress = append(progress, fetched)
+	}).Run(ctx, func(rows [][]string) error {
+		batches++
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, batches)
+	assert.Equal(t, []int{2, 4, 6}, progress)
+
+	n, err := FromDumpSearch(client, "port=80", -1, 2, []string{"ip", "port"}, nil).Uniq("ip").WriteTo(ctx, outformats.NewCSVWriter(&bytes.Buffer{}))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+}
@@ -0,0 +1,191 @@
+package gofofa
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+// SubdomainFields fields read by SubdomainCollector.Add, in order
+var SubdomainFields = []string{"host", "domain", "certs_domains", "lastupdatetime"}
+
+// Subdomain hostname found under a domain
+type Subdomain struct {
+	Name     string   `json:"name"`      // lower case hostname, wildcard is kept as *.example.com
+	Wildcard bool     `json:"wildcard"`  // name of wildcard certificate
+	Count    int      `json:"count"`     // rows containing the name
+	LastSeen string   `json:"last_seen"` // latest lastupdatetime of rows
+	Sources  []string `json:"sources"`   // fields the name is found in: host, domain, certs_domains
+}
+
+// SubdomainsOptions options of Subdomains
+type SubdomainsOptions struct {
+	Size      int  // rows to fetch, default is 1000, -1 means all when Dump is set
+	Dump      bool // fetch with DumpSearch, for large results
+	BatchSize int  // rows of each dump query, default is 1000
+	Full      bool // search result for over a year
+}
+
+// SubdomainQuery fofa query of hostnames under domain, matches the domain field and certificates
+// host is used instead of domain when domain is not registrable, such as a.example.com
+func SubdomainQuery(domain string) string {
+	field := "host"
+	if registrableDomain(domain) == domain {
+		field = "domain"
+	}
+	return fofaQuote(field, domain) + " || " + fofaQuote("cert", domain)
+}
+
+// SubdomainCollector collects hostnames under a domain from rows of SubdomainFields
+type SubdomainCollector struct {
+	domain string
+	index  map[string]int
+	names  []Subdomain
+}
+
+// NewSubdomainCollector create collector of hostnames under domain
+func NewSubdomainCollector(domain string) *SubdomainCollector {
+	return &SubdomainCollector{
+		domain: strings.ToLower(strings.TrimSpace(domain)),
+		index:  make(map[string]int),
+	}
+}
+
+// normalizeHostname hostname of host field value, such as https://a.example.com:8443, empty if it is ip
+func normalizeHostname(host string) string {
+	host = strings.TrimSpace(host)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	hostname, _ := splitHostPort(host)
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	if net.ParseIP(hostname) != nil {
+		return ""
+	}
+	return hostname
+}
+
+// under whether name is the domain or under it
+func (s *SubdomainCollector) under(name string) bool {
+	return name == s.domain || strings.HasSuffix(name, "."+s.domain)
+}
+
+// Add collect names of one row, every name is counted once a row
+func (s *SubdomainCollector) Add(row []string) {
+	var lastSeen string
+	if len(row) > 3 {
+		lastSeen = row[3]
+	}
+
+	seen := make(map[string]bool)
+	add := func(name string, source string) {
+		name = normalizeHostname(name)
+		if len(name) == 0 || !s.under(strings.TrimPrefix(name, "*.")) {
+			return
+		}
+
+		i, ok := s.index[name]
+		if !ok {
+			i = len(s.names)
+			s.index[name] = i
+			s.names = append(s.names, Subdomain{
+				Name:     name,
+				Wildcard: strings.HasPrefix(name, "*."),
+			})
+		}
+		sub := &s.names[i]
+		if !seen[name] {
+			seen[name] = true
+			sub.Count++
+			if lastSeen > sub.LastSeen {
+				sub.LastSeen = lastSeen
+			}
+		}
+		for _, src := range sub.Sources {
+			if src == source {
+				return
+			}
+		}
+		sub.Sources = append(sub.Sources, source)
+	}
+
+	for i, source := range SubdomainFields[:3] {
+		if i >= len(row) {
+			break
+		}
+		for _, name := range strings.Split(row[i], ",") {
+			add(name, source)
+		}
+	}
+}
+
+// subdomainKey sort key of name, labels are reversed so that names of the same parent are together
+func subdomainKey(name string) string {
+	labels := strings.Split(name, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// Subdomains names collected, sorted by reversed labels, such as example.com, a.example.com, b.a.example.com
+func (s *SubdomainCollector) Subdomains() []Subdomain {
+	names := make([]Subdomain, len(s.names))
+	copy(names, s.names)
+	sort.Slice(names, func(i, j int) bool {
+		return subdomainKey(names[i].Name) < subdomainKey(names[j].Name)
+	})
+	return names
+}
+
+// Subdomains find full hostnames under domain from host, domain and certs_domains of search results
+// wildcard names of certificates are kept, such as *.example.com
+func (c *Client) Subdomains(ctx context.Context, domain string, options SubdomainsOptions) ([]Subdomain, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if len(domain) == 0 {
+		return nil, errors.New("domain cannot be empty")
+	}
+	if options.Size == 0 {
+		options.Size = 1000
+	}
+	if options.BatchSize < 1 {
+		options.BatchSize = 1000
+	}
+
+	collector := NewSubdomainCollector(domain)
+	query := SubdomainQuery(domain)
+	c.logger.Debugln("subdomains:", query)
+	searchOptions := SearchOptions{Full: options.Full}
+	if options.Dump {
+		err := c.DumpSearch(query, options.Size, options.BatchSize, SubdomainFields, func(rows [][]string, total int) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for _, row := range rows {
+				collector.Add(row)
+			}
+			return nil
+		}, searchOptions)
+		if err != nil {
+			return nil, err
+		}
+		return collector.Subdomains(), nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := c.HostSearch(query, options.Size, SubdomainFields, searchOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		collector.Add(row)
+	}
+	return collector.Subdomains(), nil
+}
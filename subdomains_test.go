@@ -0,0 +1,74 @@
+package gofofa
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSubdomainQuery(t *testing.T) {
+	assert.Equal(t, `domain="example.com" || cert="example.com"`, SubdomainQuery("example.com"))
+	assert.Equal(t, `host="a.example.com" || cert="a.example.com"`, SubdomainQuery("a.example.com"))
+}
+
+func TestNormalizeHostname(t *testing.T) {
+	assert.Equal(t, "a.example.com", normalizeHostname("https://A.Example.com:8443/path"))
+	assert.Equal(t, "a.example.com", normalizeHostname("a.example.com:80"))
+	assert.Equal(t, "a.example.com", normalizeHostname(" a.example.com. "))
+	assert.Equal(t, "*.example.com", normalizeHostname("*.example.com"))
+	assert.Equal(t, "", normalizeHostname("1.1.1.1:80"))
+	assert.Equal(t, "", normalizeHostname("http://[::1]:80"))
+	assert.Equal(t, "", normalizeHostname(""))
+}
+
+func TestSubdomainCollector(t *testing.T) {
+	c := NewSubdomainCollector("Example.com")
+	c.Add([]string{"https://a.example.com", "example.com", "a.example.com,*.example.com,b.example.org", "2024-01-02 00:00:00"})
+	c.Add([]string{"b.a.example.com:8080", "example.com", "", "2024-01-03 00:00:00"})
+	c.Add([]string{"1.1.1.1", "", "", "2024-01-04 00:00:00"})
+	c.Add([]string{"notexample.com"})
+	assert.Equal(t, []Subdomain{
+		{Name: "example.com", Count: 2, LastSeen: "2024-01-03 00:00:00", Sources: []string{"domain"}},
+		{Name: "*.example.com", Wildcard: true, Count: 1, LastSeen: "2024-01-02 00:00:00", Sources: []string{"certs_domains"}},
+		{Name: "a.example.com", Count: 1, LastSeen: "2024-01-02 00:00:00", Sources: []string{"host", "certs_domains"}},
+		{Name: "b.a.example.com", Count: 1, LastSeen: "2024-01-03 00:00:00", Sources: []string{"host"}},
+	}, c.Subdomains())
+}
+
+func TestClient_Subdomains(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(bindSearchAllQueryHandle(`domain="example.com" || cert="example.com"`,
+		"host,domain,certs_domains,lastupdatetime",
+		`{"error":false,"size":2,"page":1,"results":[["a.example.com","example.com","*.example.com","2024-01-02 00:00:00"],["https://c.example.com","example.com","","2024-01-01 00:00:00"]]}`)))
+	defer ts.Close()
+
+	account := validAccounts[1]
+	cli, err := NewClient(WithURL(ts.URL + "?email=" + account.Email + "&key=" + account.Key))
+	assert.Nil(t, err)
+
+	names, err := cli.Subdomains(context.Background(), " example.com", SubdomainsOptions{})
+	assert.Nil(t, err)
+	var got []string
+	for _, n := range names {
+		got = append(got, n.Name)
+	}
+	assert.Equal(t, []string{"example.com", "*.example.com", "a.example.com", "c.example.com"}, got)
+	assert.Equal(t, 2, names[0].Count)
+	assert.True(t, names[1].Wildcard)
+
+	_, err = cli.Subdomains(context.Background(), "", SubdomainsOptions{})
+	assert.Error(t, err)
+
+	// dump
+	names, err = cli.Subdomains(context.Background(), "example.com", SubdomainsOptions{Dump: true, Size: 10, BatchSize: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(names))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cli.Subdomains(canceled, "example.com", SubdomainsOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = cli.Subdomains(canceled, "example.com", SubdomainsOptions{Dump: true})
+	assert.ErrorIs(t, err, context.Canceled)
+}